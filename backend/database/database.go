@@ -1,66 +1,573 @@
 package database
 
 // NOTA: Questo file usa SQLite con CGO
-// Per Windows senza GCC, usa json_database.go invece
+// Per Windows senza GCC, usa json_database.go invece (DB_DRIVER=json)
 // Vedi WINDOWS_SETUP.md per istruzioni
 
 import (
 	"bloodone/models"
+	"errors"
 	"log"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-var SQLDB *gorm.DB
+// SQLDatabase - Store su SQLite tramite GORM
+type SQLDatabase struct {
+	db *gorm.DB
+}
 
-func ConnectSQL() {
-	var err error
-	SQLDB, err = gorm.Open(sqlite.Open("bloodone.db"), &gorm.Config{})
+func ConnectSQL(path string) *SQLDatabase {
+	// BEGIN IMMEDIATE: le transazioni prendono subito il lock in scrittura,
+	// così due read-modify-write concorrenti vengono serializzati invece di fallire
+	dsn := path + "?_busy_timeout=5000&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	log.Println("SQL Database connected successfully")
+	log.Println("SQL Database connected successfully (file:", path, ")")
+	return &SQLDatabase{db: db}
 }
 
-func MigrateSQL() {
-	err := SQLDB.AutoMigrate(
+func (s *SQLDatabase) Migrate() {
+	err := s.db.AutoMigrate(
 		&models.User{},
 		&models.Donation{},
 		&models.Appointment{},
 		&models.Suspension{},
+		&models.RegistrationRequest{},
 		&models.DonationSchedule{},
 		&models.ExcludedDate{},
 		&models.SpecialCapacity{},
+		&models.TimeSlotRule{},
+		&models.AppointmentEvent{},
+		&models.AdminNotification{},
+		&models.JobRun{},
+		&models.DeferralReason{},
+		&models.DonationInterval{},
+		&models.Questionnaire{},
+		&models.QuestionnaireResponse{},
+		&models.Notification{},
+		&models.Recall{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
-
-	log.Println("Database migrated successfully")
+	if err := s.migrateLegacySuspended(); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
 
 	// Crea configurazione schedule di default se non esiste
-	var schedule models.DonationSchedule
-	result := SQLDB.First(&schedule)
-	if result.Error == gorm.ErrRecordNotFound {
-		defaultSchedule := models.DonationSchedule{
-			Monday:            true,
-			Tuesday:           true,
-			Wednesday:         false,
-			Thursday:          false,
-			Friday:            true,
-			Saturday:          false,
-			Sunday:            false,
-			MondayCapacity:    10,
-			TuesdayCapacity:   9,
-			WednesdayCapacity: 10,
-			ThursdayCapacity:  10,
-			FridayCapacity:    10,
-			SaturdayCapacity:  10,
-			SundayCapacity:    10,
-		}
-		SQLDB.Create(&defaultSchedule)
+	if _, err := s.GetSchedule(); errors.Is(err, ErrNotFound) {
+		schedule := defaultSchedule()
+		schedule.CreatedAt = time.Now()
+		schedule.UpdatedAt = time.Now()
+		s.db.Create(schedule)
 		log.Println("Created default donation schedule")
 	}
+
+	// Crea il catalogo dei motivi di sospensione se vuoto
+	if reasons, err := s.ListDeferralReasons(); err == nil && len(reasons) == 0 {
+		for _, reason := range defaultDeferralReasons() {
+			reason.IsActive = true
+			reason.CreatedAt = time.Now()
+			reason.UpdatedAt = time.Now()
+			s.db.Create(&reason)
+		}
+		log.Println("Created default deferral reasons")
+	}
+
+	// Crea la matrice degli intervalli tra donazioni se vuota
+	if intervals, err := s.ListDonationIntervals(); err == nil && len(intervals) == 0 {
+		for _, interval := range defaultDonationIntervals() {
+			interval.CreatedAt = time.Now()
+			interval.UpdatedAt = time.Now()
+			s.db.Create(&interval)
+		}
+		log.Println("Created default donation intervals")
+	}
+
+	// Pubblica il questionario anamnestico di default se non ce n'è nessuno
+	if questionnaires, err := s.ListQuestionnaires(); err == nil && len(questionnaires) == 0 {
+		reasonIDs := map[string]uint{}
+		if reasons, err := s.ListDeferralReasons(); err == nil {
+			for _, reason := range reasons {
+				reasonIDs[reason.Code] = reason.ID
+			}
+		}
+		questionnaire, missing := defaultQuestionnaire(reasonIDs)
+		logMissingReasons(missing)
+		now := time.Now()
+		questionnaire.CreatedAt = now
+		questionnaire.UpdatedAt = now
+		questionnaire.PublishedAt = &now
+		s.db.Create(&questionnaire)
+		log.Println("Created default questionnaire")
+	}
+}
+
+// migrateLegacySuspended sostituisce la colonna users.is_suspended, che
+// AutoMigrate non rimuove: ogni utente marcato sospeso senza una sospensione
+// in corso riceve una legacySuspension da revisionare, poi la colonna viene
+// eliminata così il passo non si ripete
+func (s *SQLDatabase) migrateLegacySuspended() error {
+	if !s.db.Migrator().HasColumn(&models.User{}, "is_suspended") {
+		return nil
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var donorIDs []uint
+		if err := tx.Table("users").Where("is_suspended = ? AND deleted_at IS NULL", true).Pluck("id", &donorIDs).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, donorID := range donorIDs {
+			var suspensions []models.Suspension
+			if err := tx.Where("donor_id = ? AND is_active = ?", donorID, true).Find(&suspensions).Error; err != nil {
+				return err
+			}
+			current := false
+			for _, suspension := range suspensions {
+				if suspension.Permanent || now.Before(suspension.EndDate) {
+					current = true
+				}
+			}
+			if current {
+				continue
+			}
+			suspension := legacySuspension(donorID, now)
+			if err := tx.Create(&suspension).Error; err != nil {
+				return err
+			}
+			log.Printf("ATTENZIONE: utente %d marcato sospeso senza sospensioni in corso: creata la sospensione %d da revisionare", donorID, suspension.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.Exec("ALTER TABLE users DROP COLUMN is_suspended").Error
+}
+
+// WithTx esegue fn in una transazione SQL; se già dentro una transazione
+// GORM usa un savepoint
+func (s *SQLDatabase) WithTx(fn func(tx Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&SQLDatabase{db: tx})
+	})
+}
+
+// notFound converte l'errore GORM nel sentinel dello store
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// first carica un singolo record che soddisfa le condizioni
+func first[T any](db *gorm.DB, conds ...interface{}) (*T, error) {
+	var item T
+	if err := db.First(&item, conds...).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &item, nil
+}
+
+// list carica tutti i record ordinati per ID
+func list[T any](db *gorm.DB, conds ...interface{}) ([]T, error) {
+	items := []T{}
+	err := db.Order("id").Find(&items, conds...).Error
+	return items, err
+}
+
+// create inserisce il record senza toccare le associazioni
+func (s *SQLDatabase) create(value interface{}) error {
+	return s.db.Omit(clause.Associations).Create(value).Error
+}
+
+// update salva tutti i campi del record, che deve già esistere
+func (s *SQLDatabase) update(model interface{}, id uint, value interface{}) error {
+	result := s.db.Model(model).Where("id = ?", id).Omit(clause.Associations).Select("*").Updates(value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// remove elimina definitivamente il record con l'ID indicato, come fa lo store JSON
+func (s *SQLDatabase) remove(model interface{}, id uint) error {
+	result := s.db.Unscoped().Delete(model, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Utenti
+
+func (s *SQLDatabase) ListUsers() ([]models.User, error) {
+	return list[models.User](s.db)
+}
+
+func (s *SQLDatabase) GetUser(id uint) (*models.User, error) {
+	return first[models.User](s.db, id)
+}
+
+func (s *SQLDatabase) FindUserByEmail(email string) (*models.User, error) {
+	return first[models.User](s.db, "email = ?", email)
+}
+
+func (s *SQLDatabase) FindUserByGoogleID(googleID string) (*models.User, error) {
+	if googleID == "" {
+		return nil, ErrNotFound
+	}
+	return first[models.User](s.db, "google_id = ?", googleID)
+}
+
+func (s *SQLDatabase) CreateUser(user *models.User) error {
+	return s.create(user)
+}
+
+func (s *SQLDatabase) UpdateUser(user *models.User) error {
+	return s.update(&models.User{}, user.ID, user)
+}
+
+func (s *SQLDatabase) DeleteUser(id uint) error {
+	return s.remove(&models.User{}, id)
+}
+
+// Donazioni
+
+func (s *SQLDatabase) ListDonations() ([]models.Donation, error) {
+	return list[models.Donation](s.db)
+}
+
+func (s *SQLDatabase) ListDonationsByDonor(donorID uint) ([]models.Donation, error) {
+	return list[models.Donation](s.db, "donor_id = ?", donorID)
+}
+
+func (s *SQLDatabase) GetDonation(id uint) (*models.Donation, error) {
+	return first[models.Donation](s.db, id)
+}
+
+func (s *SQLDatabase) CreateDonation(donation *models.Donation) error {
+	return s.create(donation)
+}
+
+func (s *SQLDatabase) UpdateDonation(donation *models.Donation) error {
+	return s.update(&models.Donation{}, donation.ID, donation)
+}
+
+func (s *SQLDatabase) DeleteDonation(id uint) error {
+	return s.remove(&models.Donation{}, id)
+}
+
+// Appuntamenti
+
+func (s *SQLDatabase) ListAppointments() ([]models.Appointment, error) {
+	return list[models.Appointment](s.db)
+}
+
+func (s *SQLDatabase) ListAppointmentsByDonor(donorID uint) ([]models.Appointment, error) {
+	return list[models.Appointment](s.db, "donor_id = ?", donorID)
+}
+
+func (s *SQLDatabase) GetAppointment(id uint) (*models.Appointment, error) {
+	return first[models.Appointment](s.db, id)
+}
+
+func (s *SQLDatabase) CreateAppointment(appointment *models.Appointment) error {
+	return s.create(appointment)
+}
+
+func (s *SQLDatabase) UpdateAppointment(appointment *models.Appointment) error {
+	return s.update(&models.Appointment{}, appointment.ID, appointment)
+}
+
+func (s *SQLDatabase) DeleteAppointment(id uint) error {
+	return s.remove(&models.Appointment{}, id)
+}
+
+// Sospensioni
+
+func (s *SQLDatabase) ListSuspensions() ([]models.Suspension, error) {
+	return list[models.Suspension](s.db)
+}
+
+func (s *SQLDatabase) ListSuspensionsByDonor(donorID uint) ([]models.Suspension, error) {
+	return list[models.Suspension](s.db, "donor_id = ?", donorID)
+}
+
+func (s *SQLDatabase) GetSuspension(id uint) (*models.Suspension, error) {
+	return first[models.Suspension](s.db, id)
+}
+
+func (s *SQLDatabase) CreateSuspension(suspension *models.Suspension) error {
+	return s.create(suspension)
+}
+
+func (s *SQLDatabase) UpdateSuspension(suspension *models.Suspension) error {
+	return s.update(&models.Suspension{}, suspension.ID, suspension)
+}
+
+// Richieste di registrazione
+
+func (s *SQLDatabase) ListRegistrationRequests() ([]models.RegistrationRequest, error) {
+	return list[models.RegistrationRequest](s.db)
+}
+
+func (s *SQLDatabase) GetRegistrationRequest(id uint) (*models.RegistrationRequest, error) {
+	return first[models.RegistrationRequest](s.db, id)
+}
+
+func (s *SQLDatabase) CreateRegistrationRequest(request *models.RegistrationRequest) error {
+	return s.create(request)
+}
+
+func (s *SQLDatabase) UpdateRegistrationRequest(request *models.RegistrationRequest) error {
+	return s.update(&models.RegistrationRequest{}, request.ID, request)
+}
+
+func (s *SQLDatabase) DeleteRegistrationRequest(id uint) error {
+	return s.remove(&models.RegistrationRequest{}, id)
+}
+
+// Schedule, date escluse e capacità speciali
+
+func (s *SQLDatabase) GetSchedule() (*models.DonationSchedule, error) {
+	var schedule models.DonationSchedule
+	if err := s.db.Order("id").First(&schedule).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &schedule, nil
+}
+
+func (s *SQLDatabase) SaveSchedule(schedule *models.DonationSchedule) error {
+	return s.db.Save(schedule).Error
+}
+
+func (s *SQLDatabase) ListExcludedDates() ([]models.ExcludedDate, error) {
+	return list[models.ExcludedDate](s.db)
+}
+
+func (s *SQLDatabase) CreateExcludedDate(date *models.ExcludedDate) error {
+	return s.create(date)
+}
+
+func (s *SQLDatabase) DeleteExcludedDate(id uint) error {
+	return s.remove(&models.ExcludedDate{}, id)
+}
+
+func (s *SQLDatabase) ListSpecialCapacities() ([]models.SpecialCapacity, error) {
+	return list[models.SpecialCapacity](s.db)
+}
+
+func (s *SQLDatabase) CreateSpecialCapacity(capacity *models.SpecialCapacity) error {
+	return s.create(capacity)
+}
+
+func (s *SQLDatabase) DeleteSpecialCapacity(id uint) error {
+	return s.remove(&models.SpecialCapacity{}, id)
+}
+
+// Fasce orarie
+
+func (s *SQLDatabase) ListTimeSlotRules() ([]models.TimeSlotRule, error) {
+	return list[models.TimeSlotRule](s.db)
+}
+
+func (s *SQLDatabase) CreateTimeSlotRule(rule *models.TimeSlotRule) error {
+	return s.create(rule)
+}
+
+func (s *SQLDatabase) UpdateTimeSlotRule(rule *models.TimeSlotRule) error {
+	return s.update(&models.TimeSlotRule{}, rule.ID, rule)
+}
+
+func (s *SQLDatabase) DeleteTimeSlotRule(id uint) error {
+	return s.remove(&models.TimeSlotRule{}, id)
+}
+
+// Storico appuntamenti
+
+func (s *SQLDatabase) ListAppointmentEvents() ([]models.AppointmentEvent, error) {
+	return list[models.AppointmentEvent](s.db)
+}
+
+func (s *SQLDatabase) ListAppointmentEventsByAppointment(appointmentID uint) ([]models.AppointmentEvent, error) {
+	return list[models.AppointmentEvent](s.db, "appointment_id = ?", appointmentID)
+}
+
+func (s *SQLDatabase) CreateAppointmentEvent(event *models.AppointmentEvent) error {
+	return s.create(event)
+}
+
+// Notifiche admin
+
+func (s *SQLDatabase) ListAdminNotifications() ([]models.AdminNotification, error) {
+	return list[models.AdminNotification](s.db)
+}
+
+func (s *SQLDatabase) GetAdminNotification(id uint) (*models.AdminNotification, error) {
+	return first[models.AdminNotification](s.db, id)
+}
+
+func (s *SQLDatabase) CreateAdminNotification(notification *models.AdminNotification) error {
+	return s.create(notification)
+}
+
+func (s *SQLDatabase) UpdateAdminNotification(notification *models.AdminNotification) error {
+	return s.update(&models.AdminNotification{}, notification.ID, notification)
+}
+
+// Job pianificati
+
+func (s *SQLDatabase) ListJobRuns() ([]models.JobRun, error) {
+	return list[models.JobRun](s.db)
+}
+
+func (s *SQLDatabase) FindJobRunByName(name string) (*models.JobRun, error) {
+	return first[models.JobRun](s.db, "name = ?", name)
+}
+
+func (s *SQLDatabase) GetJobRun(id uint) (*models.JobRun, error) {
+	return first[models.JobRun](s.db, id)
+}
+
+func (s *SQLDatabase) CreateJobRun(run *models.JobRun) error {
+	return s.create(run)
+}
+
+func (s *SQLDatabase) UpdateJobRun(run *models.JobRun) error {
+	return s.update(&models.JobRun{}, run.ID, run)
+}
+
+// Motivi di sospensione
+
+func (s *SQLDatabase) ListDeferralReasons() ([]models.DeferralReason, error) {
+	return list[models.DeferralReason](s.db)
+}
+
+func (s *SQLDatabase) GetDeferralReason(id uint) (*models.DeferralReason, error) {
+	return first[models.DeferralReason](s.db, id)
+}
+
+func (s *SQLDatabase) CreateDeferralReason(reason *models.DeferralReason) error {
+	return s.create(reason)
+}
+
+func (s *SQLDatabase) UpdateDeferralReason(reason *models.DeferralReason) error {
+	return s.update(&models.DeferralReason{}, reason.ID, reason)
+}
+
+// Intervalli tra donazioni
+
+func (s *SQLDatabase) ListDonationIntervals() ([]models.DonationInterval, error) {
+	return list[models.DonationInterval](s.db)
+}
+
+func (s *SQLDatabase) GetDonationInterval(id uint) (*models.DonationInterval, error) {
+	return first[models.DonationInterval](s.db, id)
+}
+
+func (s *SQLDatabase) CreateDonationInterval(interval *models.DonationInterval) error {
+	return s.create(interval)
+}
+
+func (s *SQLDatabase) UpdateDonationInterval(interval *models.DonationInterval) error {
+	return s.update(&models.DonationInterval{}, interval.ID, interval)
+}
+
+func (s *SQLDatabase) DeleteDonationInterval(id uint) error {
+	return s.remove(&models.DonationInterval{}, id)
+}
+
+// Questionari anamnestici
+
+func (s *SQLDatabase) ListQuestionnaires() ([]models.Questionnaire, error) {
+	return list[models.Questionnaire](s.db)
+}
+
+func (s *SQLDatabase) GetQuestionnaire(id uint) (*models.Questionnaire, error) {
+	return first[models.Questionnaire](s.db, id)
+}
+
+func (s *SQLDatabase) CreateQuestionnaire(questionnaire *models.Questionnaire) error {
+	return s.create(questionnaire)
+}
+
+func (s *SQLDatabase) UpdateQuestionnaire(questionnaire *models.Questionnaire) error {
+	return s.update(&models.Questionnaire{}, questionnaire.ID, questionnaire)
+}
+
+// Risposte ai questionari
+
+func (s *SQLDatabase) ListQuestionnaireResponsesByAppointment(appointmentID uint) ([]models.QuestionnaireResponse, error) {
+	return list[models.QuestionnaireResponse](s.db, "appointment_id = ?", appointmentID)
+}
+
+func (s *SQLDatabase) GetQuestionnaireResponse(id uint) (*models.QuestionnaireResponse, error) {
+	return first[models.QuestionnaireResponse](s.db, id)
+}
+
+func (s *SQLDatabase) CreateQuestionnaireResponse(response *models.QuestionnaireResponse) error {
+	return s.create(response)
+}
+
+// Notifiche ai donatori
+
+func (s *SQLDatabase) ListNotifications() ([]models.Notification, error) {
+	return list[models.Notification](s.db)
+}
+
+func (s *SQLDatabase) ListNotificationsByUser(userID uint) ([]models.Notification, error) {
+	return list[models.Notification](s.db, "user_id = ?", userID)
+}
+
+func (s *SQLDatabase) ListNotificationsByStatus(status string) ([]models.Notification, error) {
+	return list[models.Notification](s.db, "status = ?", status)
+}
+
+func (s *SQLDatabase) GetNotification(id uint) (*models.Notification, error) {
+	return first[models.Notification](s.db, id)
+}
+
+func (s *SQLDatabase) CreateNotification(notification *models.Notification) error {
+	return s.create(notification)
+}
+
+func (s *SQLDatabase) UpdateNotification(notification *models.Notification) error {
+	return s.update(&models.Notification{}, notification.ID, notification)
+}
+
+// Richiami
+
+func (s *SQLDatabase) ListRecalls() ([]models.Recall, error) {
+	return list[models.Recall](s.db)
+}
+
+func (s *SQLDatabase) ListRecallsByDonor(donorID uint) ([]models.Recall, error) {
+	return list[models.Recall](s.db, "donor_id = ?", donorID)
+}
+
+func (s *SQLDatabase) GetRecall(id uint) (*models.Recall, error) {
+	return first[models.Recall](s.db, id)
+}
+
+func (s *SQLDatabase) CreateRecall(recall *models.Recall) error {
+	return s.create(recall)
+}
+
+func (s *SQLDatabase) UpdateRecall(recall *models.Recall) error {
+	return s.update(&models.Recall{}, recall.ID, recall)
 }
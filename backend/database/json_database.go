@@ -4,146 +4,552 @@ import (
 	"bloodone/models"
 	"encoding/json"
 	"log"
-	"os"
 	"sync"
-	"time"
-)
-
-var (
-	DB     *JSONDatabase
-	dbLock sync.RWMutex
 )
 
+// JSONDatabase - Store su singolo file JSON (nessun CGO richiesto - funziona su Windows).
+// Tutti gli accessi passano da mu: le letture in parallelo, le modifiche una
+// alla volta tramite WithTx, che applica e salva su file come un'unica unità.
 type JSONDatabase struct {
-	Users                []models.User                `json:"users"`
-	Donations            []models.Donation            `json:"donations"`
-	Appointments         []models.Appointment         `json:"appointments"`
-	Suspensions          []models.Suspension          `json:"suspensions"`
-	RegistrationRequests []models.RegistrationRequest `json:"registration_requests"`
-	Schedule             *models.DonationSchedule     `json:"schedule"`
-	ExcludedDates        []models.ExcludedDate        `json:"excluded_dates"`
-	SpecialCapacities    []models.SpecialCapacity     `json:"special_capacities"`
-	filename             string
-}
-
-func Connect() {
-	DB = &JSONDatabase{
-		Users:                []models.User{},
-		Donations:            []models.Donation{},
-		Appointments:         []models.Appointment{},
-		Suspensions:          []models.Suspension{},
-		RegistrationRequests: []models.RegistrationRequest{},
-		ExcludedDates:        []models.ExcludedDate{},
-		SpecialCapacities:    []models.SpecialCapacity{},
-		filename:             "bloodone_data.json",
+	// Versione dello schema del file, aggiornata dalle migrazioni
+	SchemaVersion int `json:"schema_version"`
+
+	Users                  []models.User                  `json:"users"`
+	Donations              []models.Donation              `json:"donations"`
+	Appointments           []models.Appointment           `json:"appointments"`
+	Suspensions            []models.Suspension            `json:"suspensions"`
+	RegistrationRequests   []models.RegistrationRequest   `json:"registration_requests"`
+	Schedule               *models.DonationSchedule       `json:"schedule"`
+	ExcludedDates          []models.ExcludedDate          `json:"excluded_dates"`
+	SpecialCapacities      []models.SpecialCapacity       `json:"special_capacities"`
+	TimeSlotRules          []models.TimeSlotRule          `json:"time_slot_rules"`
+	AppointmentEvents      []models.AppointmentEvent      `json:"appointment_events"`
+	AdminNotifications     []models.AdminNotification     `json:"admin_notifications"`
+	JobRuns                []models.JobRun                `json:"job_runs"`
+	DeferralReasons        []models.DeferralReason        `json:"deferral_reasons"`
+	DonationIntervals      []models.DonationInterval      `json:"donation_intervals"`
+	Questionnaires         []models.Questionnaire         `json:"questionnaires"`
+	QuestionnaireResponses []models.QuestionnaireResponse `json:"questionnaire_responses"`
+	Notifications          []models.Notification          `json:"notifications"`
+	Recalls                []models.Recall                `json:"recalls"`
+
+	// Ultimo ID assegnato per ogni tabella
+	Sequences map[string]uint `json:"sequences"`
+
+	filename string
+	mu       sync.RWMutex
+}
+
+func newJSONDatabase(filename string) *JSONDatabase {
+	return &JSONDatabase{
+		Users:                  []models.User{},
+		Donations:              []models.Donation{},
+		Appointments:           []models.Appointment{},
+		Suspensions:            []models.Suspension{},
+		RegistrationRequests:   []models.RegistrationRequest{},
+		ExcludedDates:          []models.ExcludedDate{},
+		SpecialCapacities:      []models.SpecialCapacity{},
+		TimeSlotRules:          []models.TimeSlotRule{},
+		AppointmentEvents:      []models.AppointmentEvent{},
+		AdminNotifications:     []models.AdminNotification{},
+		JobRuns:                []models.JobRun{},
+		DeferralReasons:        []models.DeferralReason{},
+		DonationIntervals:      []models.DonationInterval{},
+		Questionnaires:         []models.Questionnaire{},
+		QuestionnaireResponses: []models.QuestionnaireResponse{},
+		Notifications:          []models.Notification{},
+		Recalls:                []models.Recall{},
+		Sequences:              map[string]uint{},
+		filename:               filename,
 	}
+}
 
-	// Prova a caricare dati esistenti
-	if _, err := os.Stat(DB.filename); err == nil {
-		data, err := os.ReadFile(DB.filename)
-		if err == nil {
-			json.Unmarshal(data, DB)
-		}
+func ConnectJSON(filename string) *JSONDatabase {
+	// Carica dati esistenti: un file illeggibile blocca l'avvio invece di
+	// ripartire vuoti e sovrascrivere tutti i donatori al primo salvataggio
+	db, err := loadJSONFile(filename)
+	if err != nil {
+		log.Fatal("Failed to load JSON database: ", err)
 	}
 
-	log.Println("JSON Database connected successfully (file:", DB.filename, ")")
-}
-
-func Migrate() {
-	// Crea configurazione schedule di default se non esiste
-	if DB.Schedule == nil {
-		DB.Schedule = &models.DonationSchedule{
-			ID:                1,
-			CreatedAt:         time.Now(),
-			UpdatedAt:         time.Now(),
-			Monday:            true,
-			Tuesday:           true,
-			Wednesday:         false,
-			Thursday:          false,
-			Friday:            true,
-			Saturday:          false,
-			Sunday:            false,
-			MondayCapacity:    10,
-			TuesdayCapacity:   9,
-			WednesdayCapacity: 10,
-			ThursdayCapacity:  10,
-			FridayCapacity:    10,
-			SaturdayCapacity:  10,
-			SundayCapacity:    10,
-		}
-		DB.Save()
+	log.Println("JSON Database connected successfully (file:", db.filename, ")")
+	return db
+}
+
+// Migrate applica le migrazioni in sospeso del file dati (vedi json_migrations.go)
+func (db *JSONDatabase) Migrate() {
+	if err := db.migrate(false); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
 	}
-	log.Println("Database migrated successfully")
 }
 
+// WithTx esegue fn con accesso esclusivo ai dati e salva il file una sola
+// volta alla fine. Se fn o il salvataggio falliscono, i dati in memoria
+// tornano allo stato precedente e nessuna modifica viene scritta.
+func (db *JSONDatabase) WithTx(fn func(tx Store) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	backup := db.snapshot()
+	if err := fn(&jsonTx{db: db}); err != nil {
+		db.restore(backup)
+		return err
+	}
+	if err := db.save(); err != nil {
+		db.restore(backup)
+		return err
+	}
+	return nil
+}
+
+// read esegue una lettura con il lock condiviso
+func read[T any](db *JSONDatabase, fn func(tx *jsonTx) (T, error)) (T, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fn(&jsonTx{db: db})
+}
+
+// Save scrive l'intero database su file
 func (db *JSONDatabase) Save() error {
-	dbLock.Lock()
-	defer dbLock.Unlock()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.save()
+}
 
+// save presuppone che il chiamante detenga mu
+func (db *JSONDatabase) save() error {
 	data, err := json.MarshalIndent(db, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(db.filename, data, 0644)
+	return writeFileAtomic(db.filename, data)
 }
 
-// Helper per generare ID
-func (db *JSONDatabase) NextUserID() uint {
-	maxID := uint(0)
-	for _, u := range db.Users {
-		if u.ID > maxID {
-			maxID = u.ID
-		}
-	}
-	return maxID + 1
+// jsonData - Copia dei dati usata per annullare una transazione fallita
+type jsonData struct {
+	users                  []models.User
+	donations              []models.Donation
+	appointments           []models.Appointment
+	suspensions            []models.Suspension
+	registrationRequests   []models.RegistrationRequest
+	schedule               *models.DonationSchedule
+	excludedDates          []models.ExcludedDate
+	specialCapacities      []models.SpecialCapacity
+	timeSlotRules          []models.TimeSlotRule
+	appointmentEvents      []models.AppointmentEvent
+	adminNotifications     []models.AdminNotification
+	jobRuns                []models.JobRun
+	deferralReasons        []models.DeferralReason
+	donationIntervals      []models.DonationInterval
+	questionnaires         []models.Questionnaire
+	questionnaireResponses []models.QuestionnaireResponse
+	notifications          []models.Notification
+	recalls                []models.Recall
+	sequences              map[string]uint
 }
 
-func (db *JSONDatabase) NextDonationID() uint {
-	maxID := uint(0)
-	for _, d := range db.Donations {
-		if d.ID > maxID {
-			maxID = d.ID
-		}
+func (db *JSONDatabase) snapshot() jsonData {
+	data := jsonData{
+		users:                  append([]models.User{}, db.Users...),
+		donations:              append([]models.Donation{}, db.Donations...),
+		appointments:           append([]models.Appointment{}, db.Appointments...),
+		suspensions:            append([]models.Suspension{}, db.Suspensions...),
+		registrationRequests:   append([]models.RegistrationRequest{}, db.RegistrationRequests...),
+		schedule:               db.Schedule,
+		excludedDates:          append([]models.ExcludedDate{}, db.ExcludedDates...),
+		specialCapacities:      append([]models.SpecialCapacity{}, db.SpecialCapacities...),
+		timeSlotRules:          append([]models.TimeSlotRule{}, db.TimeSlotRules...),
+		appointmentEvents:      append([]models.AppointmentEvent{}, db.AppointmentEvents...),
+		adminNotifications:     append([]models.AdminNotification{}, db.AdminNotifications...),
+		jobRuns:                append([]models.JobRun{}, db.JobRuns...),
+		deferralReasons:        append([]models.DeferralReason{}, db.DeferralReasons...),
+		donationIntervals:      append([]models.DonationInterval{}, db.DonationIntervals...),
+		questionnaires:         append([]models.Questionnaire{}, db.Questionnaires...),
+		questionnaireResponses: append([]models.QuestionnaireResponse{}, db.QuestionnaireResponses...),
+		notifications:          append([]models.Notification{}, db.Notifications...),
+		recalls:                append([]models.Recall{}, db.Recalls...),
+		sequences:              map[string]uint{},
 	}
-	return maxID + 1
+	for k, v := range db.Sequences {
+		data.sequences[k] = v
+	}
+	return data
 }
 
-func (db *JSONDatabase) NextAppointmentID() uint {
-	maxID := uint(0)
-	for _, a := range db.Appointments {
-		if a.ID > maxID {
-			maxID = a.ID
-		}
-	}
-	return maxID + 1
+func (db *JSONDatabase) restore(data jsonData) {
+	db.Users = data.users
+	db.Donations = data.donations
+	db.Appointments = data.appointments
+	db.Suspensions = data.suspensions
+	db.RegistrationRequests = data.registrationRequests
+	db.Schedule = data.schedule
+	db.ExcludedDates = data.excludedDates
+	db.SpecialCapacities = data.specialCapacities
+	db.TimeSlotRules = data.timeSlotRules
+	db.AppointmentEvents = data.appointmentEvents
+	db.AdminNotifications = data.adminNotifications
+	db.JobRuns = data.jobRuns
+	db.DeferralReasons = data.deferralReasons
+	db.DonationIntervals = data.donationIntervals
+	db.Questionnaires = data.questionnaires
+	db.QuestionnaireResponses = data.questionnaireResponses
+	db.Notifications = data.notifications
+	db.Recalls = data.recalls
+	db.Sequences = data.sequences
 }
 
-func (db *JSONDatabase) NextSuspensionID() uint {
-	maxID := uint(0)
-	for _, s := range db.Suspensions {
-		if s.ID > maxID {
-			maxID = s.ID
-		}
-	}
-	return maxID + 1
+// Utenti
+
+func (db *JSONDatabase) ListUsers() ([]models.User, error) {
+	return read(db, (*jsonTx).ListUsers)
 }
 
-func (db *JSONDatabase) NextExcludedDateID() uint {
-	maxID := uint(0)
-	for _, e := range db.ExcludedDates {
-		if e.ID > maxID {
-			maxID = e.ID
-		}
-	}
-	return maxID + 1
+func (db *JSONDatabase) GetUser(id uint) (*models.User, error) {
+	return read(db, func(tx *jsonTx) (*models.User, error) { return tx.GetUser(id) })
 }
 
-func (db *JSONDatabase) NextRegistrationRequestID() uint {
-	maxID := uint(0)
-	for _, r := range db.RegistrationRequests {
-		if r.ID > maxID {
-			maxID = r.ID
-		}
-	}
-	return maxID + 1
+func (db *JSONDatabase) FindUserByEmail(email string) (*models.User, error) {
+	return read(db, func(tx *jsonTx) (*models.User, error) { return tx.FindUserByEmail(email) })
+}
+
+func (db *JSONDatabase) FindUserByGoogleID(googleID string) (*models.User, error) {
+	return read(db, func(tx *jsonTx) (*models.User, error) { return tx.FindUserByGoogleID(googleID) })
+}
+
+func (db *JSONDatabase) CreateUser(user *models.User) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateUser(user) })
+}
+
+func (db *JSONDatabase) UpdateUser(user *models.User) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateUser(user) })
+}
+
+func (db *JSONDatabase) DeleteUser(id uint) error {
+	return db.WithTx(func(tx Store) error { return tx.DeleteUser(id) })
+}
+
+// Donazioni
+
+func (db *JSONDatabase) ListDonations() ([]models.Donation, error) {
+	return read(db, (*jsonTx).ListDonations)
+}
+
+func (db *JSONDatabase) ListDonationsByDonor(donorID uint) ([]models.Donation, error) {
+	return read(db, func(tx *jsonTx) ([]models.Donation, error) { return tx.ListDonationsByDonor(donorID) })
+}
+
+func (db *JSONDatabase) GetDonation(id uint) (*models.Donation, error) {
+	return read(db, func(tx *jsonTx) (*models.Donation, error) { return tx.GetDonation(id) })
+}
+
+func (db *JSONDatabase) CreateDonation(donation *models.Donation) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateDonation(donation) })
+}
+
+func (db *JSONDatabase) UpdateDonation(donation *models.Donation) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateDonation(donation) })
+}
+
+func (db *JSONDatabase) DeleteDonation(id uint) error {
+	return db.WithTx(func(tx Store) error { return tx.DeleteDonation(id) })
+}
+
+// Appuntamenti
+
+func (db *JSONDatabase) ListAppointments() ([]models.Appointment, error) {
+	return read(db, (*jsonTx).ListAppointments)
+}
+
+func (db *JSONDatabase) ListAppointmentsByDonor(donorID uint) ([]models.Appointment, error) {
+	return read(db, func(tx *jsonTx) ([]models.Appointment, error) { return tx.ListAppointmentsByDonor(donorID) })
+}
+
+func (db *JSONDatabase) GetAppointment(id uint) (*models.Appointment, error) {
+	return read(db, func(tx *jsonTx) (*models.Appointment, error) { return tx.GetAppointment(id) })
+}
+
+func (db *JSONDatabase) CreateAppointment(appointment *models.Appointment) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateAppointment(appointment) })
+}
+
+func (db *JSONDatabase) UpdateAppointment(appointment *models.Appointment) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateAppointment(appointment) })
+}
+
+func (db *JSONDatabase) DeleteAppointment(id uint) error {
+	return db.WithTx(func(tx Store) error { return tx.DeleteAppointment(id) })
+}
+
+// Sospensioni
+
+func (db *JSONDatabase) ListSuspensions() ([]models.Suspension, error) {
+	return read(db, (*jsonTx).ListSuspensions)
+}
+
+func (db *JSONDatabase) ListSuspensionsByDonor(donorID uint) ([]models.Suspension, error) {
+	return read(db, func(tx *jsonTx) ([]models.Suspension, error) { return tx.ListSuspensionsByDonor(donorID) })
+}
+
+func (db *JSONDatabase) GetSuspension(id uint) (*models.Suspension, error) {
+	return read(db, func(tx *jsonTx) (*models.Suspension, error) { return tx.GetSuspension(id) })
+}
+
+func (db *JSONDatabase) CreateSuspension(suspension *models.Suspension) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateSuspension(suspension) })
+}
+
+func (db *JSONDatabase) UpdateSuspension(suspension *models.Suspension) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateSuspension(suspension) })
+}
+
+// Richieste di registrazione
+
+func (db *JSONDatabase) ListRegistrationRequests() ([]models.RegistrationRequest, error) {
+	return read(db, (*jsonTx).ListRegistrationRequests)
+}
+
+func (db *JSONDatabase) GetRegistrationRequest(id uint) (*models.RegistrationRequest, error) {
+	return read(db, func(tx *jsonTx) (*models.RegistrationRequest, error) { return tx.GetRegistrationRequest(id) })
+}
+
+func (db *JSONDatabase) CreateRegistrationRequest(request *models.RegistrationRequest) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateRegistrationRequest(request) })
+}
+
+func (db *JSONDatabase) UpdateRegistrationRequest(request *models.RegistrationRequest) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateRegistrationRequest(request) })
+}
+
+func (db *JSONDatabase) DeleteRegistrationRequest(id uint) error {
+	return db.WithTx(func(tx Store) error { return tx.DeleteRegistrationRequest(id) })
+}
+
+// Schedule, date escluse e capacità speciali
+
+func (db *JSONDatabase) GetSchedule() (*models.DonationSchedule, error) {
+	return read(db, (*jsonTx).GetSchedule)
+}
+
+func (db *JSONDatabase) SaveSchedule(schedule *models.DonationSchedule) error {
+	return db.WithTx(func(tx Store) error { return tx.SaveSchedule(schedule) })
+}
+
+func (db *JSONDatabase) ListExcludedDates() ([]models.ExcludedDate, error) {
+	return read(db, (*jsonTx).ListExcludedDates)
+}
+
+func (db *JSONDatabase) CreateExcludedDate(date *models.ExcludedDate) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateExcludedDate(date) })
+}
+
+func (db *JSONDatabase) DeleteExcludedDate(id uint) error {
+	return db.WithTx(func(tx Store) error { return tx.DeleteExcludedDate(id) })
+}
+
+func (db *JSONDatabase) ListSpecialCapacities() ([]models.SpecialCapacity, error) {
+	return read(db, (*jsonTx).ListSpecialCapacities)
+}
+
+func (db *JSONDatabase) CreateSpecialCapacity(capacity *models.SpecialCapacity) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateSpecialCapacity(capacity) })
+}
+
+func (db *JSONDatabase) DeleteSpecialCapacity(id uint) error {
+	return db.WithTx(func(tx Store) error { return tx.DeleteSpecialCapacity(id) })
+}
+
+// Fasce orarie
+
+func (db *JSONDatabase) ListTimeSlotRules() ([]models.TimeSlotRule, error) {
+	return read(db, (*jsonTx).ListTimeSlotRules)
+}
+
+func (db *JSONDatabase) CreateTimeSlotRule(rule *models.TimeSlotRule) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateTimeSlotRule(rule) })
+}
+
+func (db *JSONDatabase) UpdateTimeSlotRule(rule *models.TimeSlotRule) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateTimeSlotRule(rule) })
+}
+
+func (db *JSONDatabase) DeleteTimeSlotRule(id uint) error {
+	return db.WithTx(func(tx Store) error { return tx.DeleteTimeSlotRule(id) })
+}
+
+// Storico appuntamenti
+
+func (db *JSONDatabase) ListAppointmentEvents() ([]models.AppointmentEvent, error) {
+	return read(db, (*jsonTx).ListAppointmentEvents)
+}
+
+func (db *JSONDatabase) ListAppointmentEventsByAppointment(appointmentID uint) ([]models.AppointmentEvent, error) {
+	return read(db, func(tx *jsonTx) ([]models.AppointmentEvent, error) {
+		return tx.ListAppointmentEventsByAppointment(appointmentID)
+	})
+}
+
+func (db *JSONDatabase) CreateAppointmentEvent(event *models.AppointmentEvent) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateAppointmentEvent(event) })
+}
+
+// Notifiche admin
+
+func (db *JSONDatabase) ListAdminNotifications() ([]models.AdminNotification, error) {
+	return read(db, (*jsonTx).ListAdminNotifications)
+}
+
+func (db *JSONDatabase) GetAdminNotification(id uint) (*models.AdminNotification, error) {
+	return read(db, func(tx *jsonTx) (*models.AdminNotification, error) { return tx.GetAdminNotification(id) })
+}
+
+func (db *JSONDatabase) CreateAdminNotification(notification *models.AdminNotification) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateAdminNotification(notification) })
+}
+
+func (db *JSONDatabase) UpdateAdminNotification(notification *models.AdminNotification) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateAdminNotification(notification) })
+}
+
+// Job pianificati
+
+func (db *JSONDatabase) ListJobRuns() ([]models.JobRun, error) {
+	return read(db, (*jsonTx).ListJobRuns)
+}
+
+func (db *JSONDatabase) FindJobRunByName(name string) (*models.JobRun, error) {
+	return read(db, func(tx *jsonTx) (*models.JobRun, error) { return tx.FindJobRunByName(name) })
+}
+
+func (db *JSONDatabase) GetJobRun(id uint) (*models.JobRun, error) {
+	return read(db, func(tx *jsonTx) (*models.JobRun, error) { return tx.GetJobRun(id) })
+}
+
+func (db *JSONDatabase) CreateJobRun(run *models.JobRun) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateJobRun(run) })
+}
+
+func (db *JSONDatabase) UpdateJobRun(run *models.JobRun) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateJobRun(run) })
+}
+
+// Motivi di sospensione
+
+func (db *JSONDatabase) ListDeferralReasons() ([]models.DeferralReason, error) {
+	return read(db, (*jsonTx).ListDeferralReasons)
+}
+
+func (db *JSONDatabase) GetDeferralReason(id uint) (*models.DeferralReason, error) {
+	return read(db, func(tx *jsonTx) (*models.DeferralReason, error) { return tx.GetDeferralReason(id) })
+}
+
+func (db *JSONDatabase) CreateDeferralReason(reason *models.DeferralReason) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateDeferralReason(reason) })
+}
+
+func (db *JSONDatabase) UpdateDeferralReason(reason *models.DeferralReason) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateDeferralReason(reason) })
+}
+
+// Intervalli tra donazioni
+
+func (db *JSONDatabase) ListDonationIntervals() ([]models.DonationInterval, error) {
+	return read(db, (*jsonTx).ListDonationIntervals)
+}
+
+func (db *JSONDatabase) GetDonationInterval(id uint) (*models.DonationInterval, error) {
+	return read(db, func(tx *jsonTx) (*models.DonationInterval, error) { return tx.GetDonationInterval(id) })
+}
+
+func (db *JSONDatabase) CreateDonationInterval(interval *models.DonationInterval) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateDonationInterval(interval) })
+}
+
+func (db *JSONDatabase) UpdateDonationInterval(interval *models.DonationInterval) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateDonationInterval(interval) })
+}
+
+func (db *JSONDatabase) DeleteDonationInterval(id uint) error {
+	return db.WithTx(func(tx Store) error { return tx.DeleteDonationInterval(id) })
+}
+
+// Questionari anamnestici
+
+func (db *JSONDatabase) ListQuestionnaires() ([]models.Questionnaire, error) {
+	return read(db, (*jsonTx).ListQuestionnaires)
+}
+
+func (db *JSONDatabase) GetQuestionnaire(id uint) (*models.Questionnaire, error) {
+	return read(db, func(tx *jsonTx) (*models.Questionnaire, error) { return tx.GetQuestionnaire(id) })
+}
+
+func (db *JSONDatabase) CreateQuestionnaire(questionnaire *models.Questionnaire) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateQuestionnaire(questionnaire) })
+}
+
+func (db *JSONDatabase) UpdateQuestionnaire(questionnaire *models.Questionnaire) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateQuestionnaire(questionnaire) })
+}
+
+// Risposte ai questionari
+
+func (db *JSONDatabase) ListQuestionnaireResponsesByAppointment(appointmentID uint) ([]models.QuestionnaireResponse, error) {
+	return read(db, func(tx *jsonTx) ([]models.QuestionnaireResponse, error) {
+		return tx.ListQuestionnaireResponsesByAppointment(appointmentID)
+	})
+}
+
+func (db *JSONDatabase) GetQuestionnaireResponse(id uint) (*models.QuestionnaireResponse, error) {
+	return read(db, func(tx *jsonTx) (*models.QuestionnaireResponse, error) { return tx.GetQuestionnaireResponse(id) })
+}
+
+func (db *JSONDatabase) CreateQuestionnaireResponse(response *models.QuestionnaireResponse) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateQuestionnaireResponse(response) })
+}
+
+// Notifiche ai donatori
+
+func (db *JSONDatabase) ListNotifications() ([]models.Notification, error) {
+	return read(db, (*jsonTx).ListNotifications)
+}
+
+func (db *JSONDatabase) ListNotificationsByUser(userID uint) ([]models.Notification, error) {
+	return read(db, func(tx *jsonTx) ([]models.Notification, error) { return tx.ListNotificationsByUser(userID) })
+}
+
+func (db *JSONDatabase) ListNotificationsByStatus(status string) ([]models.Notification, error) {
+	return read(db, func(tx *jsonTx) ([]models.Notification, error) { return tx.ListNotificationsByStatus(status) })
+}
+
+func (db *JSONDatabase) GetNotification(id uint) (*models.Notification, error) {
+	return read(db, func(tx *jsonTx) (*models.Notification, error) { return tx.GetNotification(id) })
+}
+
+func (db *JSONDatabase) CreateNotification(notification *models.Notification) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateNotification(notification) })
+}
+
+func (db *JSONDatabase) UpdateNotification(notification *models.Notification) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateNotification(notification) })
+}
+
+// Richiami
+
+func (db *JSONDatabase) ListRecalls() ([]models.Recall, error) {
+	return read(db, (*jsonTx).ListRecalls)
+}
+
+func (db *JSONDatabase) ListRecallsByDonor(donorID uint) ([]models.Recall, error) {
+	return read(db, func(tx *jsonTx) ([]models.Recall, error) { return tx.ListRecallsByDonor(donorID) })
+}
+
+func (db *JSONDatabase) GetRecall(id uint) (*models.Recall, error) {
+	return read(db, func(tx *jsonTx) (*models.Recall, error) { return tx.GetRecall(id) })
+}
+
+func (db *JSONDatabase) CreateRecall(recall *models.Recall) error {
+	return db.WithTx(func(tx Store) error { return tx.CreateRecall(recall) })
+}
+
+func (db *JSONDatabase) UpdateRecall(recall *models.Recall) error {
+	return db.WithTx(func(tx Store) error { return tx.UpdateRecall(recall) })
 }
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Numero di snapshot precedenti conservati accanto al file dati (file.1 = più recente)
+const defaultSnapshotCount = 5
+
+func snapshotCount() int {
+	if v := os.Getenv("DB_SNAPSHOTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return defaultSnapshotCount
+}
+
+func snapshotName(filename string, n int) string {
+	return fmt.Sprintf("%s.%d", filename, n)
+}
+
+// writeFileAtomic scrive data in un file temporaneo nella stessa cartella,
+// lo sincronizza su disco e lo rinomina sopra filename: un crash a metà
+// scrittura lascia intatto il file precedente.
+func writeFileAtomic(filename string, data []byte) error {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op dopo il rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		return err
+	}
+
+	if err := rotateSnapshots(filename, snapshotCount()); err != nil {
+		log.Println("Impossibile ruotare gli snapshot del database:", err)
+	}
+
+	if err := os.Rename(tmpName, filename); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// rotateSnapshots sposta file.1 -> file.2 ... e copia il file corrente in file.1
+func rotateSnapshots(filename string, count int) error {
+	if count <= 0 {
+		return nil
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+
+	os.Remove(snapshotName(filename, count))
+	for i := count - 1; i >= 1; i-- {
+		from := snapshotName(filename, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, snapshotName(filename, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Hard link quando possibile, altrimenti copia
+	if err := os.Link(filename, snapshotName(filename, 1)); err == nil {
+		return nil
+	}
+	return copyFile(filename, snapshotName(filename, 1))
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// syncDir rende persistente il rename sulla cartella (non supportato su Windows)
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// loadJSONFile legge il file dati. Se il file esiste ma non è leggibile prova
+// gli snapshot dal più recente; se DB_SNAPSHOT_FALLBACK=false, o nessuno
+// snapshot è valido, restituisce errore invece di partire con un database vuoto.
+func loadJSONFile(filename string) (*JSONDatabase, error) {
+	db, err := decodeJSONFile(filename)
+	if err == nil {
+		return db, nil
+	}
+	if os.IsNotExist(err) {
+		return newJSONDatabase(filename), nil
+	}
+	log.Printf("File dati %s illeggibile: %v", filename, err)
+
+	if os.Getenv("DB_SNAPSHOT_FALLBACK") == "false" {
+		return nil, fmt.Errorf("file dati %s illeggibile: %w", filename, err)
+	}
+
+	for i := 1; i <= snapshotCount(); i++ {
+		name := snapshotName(filename, i)
+		recovered, err := decodeJSONFile(name)
+		if err != nil {
+			continue
+		}
+
+		// Conserva il file corrotto per analisi prima che venga sovrascritto
+		corrupt := fmt.Sprintf("%s.corrupt-%s", filename, time.Now().Format("20060102-150405"))
+		if err := os.Rename(filename, corrupt); err != nil {
+			return nil, fmt.Errorf("impossibile mettere da parte il file corrotto: %w", err)
+		}
+		if err := copyFile(name, filename); err != nil {
+			return nil, fmt.Errorf("impossibile ripristinare lo snapshot %s: %w", name, err)
+		}
+
+		recovered.filename = filename
+		log.Printf("ATTENZIONE: database ripristinato dallo snapshot %s (file corrotto salvato in %s)", name, corrupt)
+		return recovered, nil
+	}
+
+	return nil, fmt.Errorf("file dati %s illeggibile e nessuno snapshot valido disponibile", filename)
+}
+
+func decodeJSONFile(filename string) (*JSONDatabase, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	db := newJSONDatabase(filename)
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,517 @@
+package database
+
+import (
+	"bloodone/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonDocument - Contenuto grezzo del file dati. Le migrazioni lavorano su
+// questa forma e non sui modelli, così possono leggere campi rinominati o
+// rimossi che i modelli attuali non conoscono più.
+type jsonDocument map[string]any
+
+// jsonMigration porta il documento dalla versione Version-1 alla versione Version
+type jsonMigration struct {
+	Version     int
+	Description string
+	Up          func(doc jsonDocument) error
+}
+
+// jsonMigrations - Elenco ordinato dei passi di migrazione. I passi già
+// rilasciati non vanno mai modificati: ogni cambiamento ai dati è un nuovo
+// passo con la versione successiva.
+var jsonMigrations = []jsonMigration{
+	{
+		Version:     1,
+		Description: "Inizializza le collezioni mancanti e i contatori degli ID",
+		Up:          migrateInitCollections,
+	},
+	{
+		Version:     2,
+		Description: "Crea la configurazione di default dello schedule",
+		Up:          migrateDefaultSchedule,
+	},
+	{
+		Version:     3,
+		Description: "Aggiunge la collezione time_slot_rules",
+		Up:          addCollection("time_slot_rules"),
+	},
+	{
+		Version:     4,
+		Description: "Aggiunge la collezione appointment_events",
+		Up:          addCollection("appointment_events"),
+	},
+	{
+		Version:     5,
+		Description: "Aggiunge la collezione admin_notifications",
+		Up:          addCollection("admin_notifications"),
+	},
+	{
+		Version:     6,
+		Description: "Aggiunge la collezione job_runs",
+		Up:          addCollection("job_runs"),
+	},
+	{
+		Version:     7,
+		Description: "Rimuove il flag is_suspended dagli utenti: lo stato deriva dalle sospensioni",
+		Up:          migrateDerivedSuspension,
+	},
+	{
+		Version:     8,
+		Description: "Aggiunge il catalogo dei motivi di sospensione (deferral_reasons)",
+		Up:          migrateDeferralReasons,
+	},
+	{
+		Version:     9,
+		Description: "Aggiunge i tipi di donazione e la matrice degli intervalli (donation_intervals)",
+		Up:          migrateDonationTypes,
+	},
+	{
+		Version:     10,
+		Description: "Aggiunge il questionario anamnestico (questionnaires) e le risposte (questionnaire_responses)",
+		Up:          migrateQuestionnaires,
+	},
+	{
+		Version:     11,
+		Description: "Aggiunge la collezione notifications",
+		Up:          addCollection("notifications"),
+	},
+	{
+		Version:     12,
+		Description: "Aggiunge la collezione recalls",
+		Up:          addCollection("recalls"),
+	},
+	{
+		Version:     13,
+		Description: "Collega ai motivi del catalogo le domande del questionario di default rimaste senza motivo suggerito",
+		Up:          migrateQuestionnaireReasons,
+	},
+	{
+		Version:     14,
+		Description: "Mette in revisione le sospensioni permanenti create dal flag is_suspended",
+		Up:          migrateLegacySuspensionsToReview,
+	},
+}
+
+// jsonCollections - Collezioni presenti nella versione 1 del file dati, con
+// il nome della relativa sequenza. Le collezioni successive sono aggiunte da
+// passi dedicati (addCollection).
+var jsonCollections = []string{
+	"users",
+	"donations",
+	"appointments",
+	"suspensions",
+	"registration_requests",
+	"excluded_dates",
+	"special_capacities",
+}
+
+func latestSchemaVersion() int {
+	return jsonMigrations[len(jsonMigrations)-1].Version
+}
+
+// addCollection - Passo che aggiunge una collezione vuota (se assente) e la sua sequenza
+func addCollection(name string) func(doc jsonDocument) error {
+	return func(doc jsonDocument) error {
+		if items, _ := doc[name].([]any); items == nil {
+			doc[name] = []any{}
+		}
+		sequences, _ := doc["sequences"].(map[string]any)
+		if sequences == nil {
+			sequences = map[string]any{}
+			doc["sequences"] = sequences
+		}
+		if _, ok := sequences[name]; !ok {
+			sequences[name] = 0
+		}
+		return nil
+	}
+}
+
+func migrateInitCollections(doc jsonDocument) error {
+	sequences, _ := doc["sequences"].(map[string]any)
+	if sequences == nil {
+		sequences = map[string]any{}
+	}
+
+	for _, name := range jsonCollections {
+		items, _ := doc[name].([]any)
+		if items == nil {
+			items = []any{}
+		}
+		doc[name] = items
+
+		// Il contatore parte dall'ID più alto già presente
+		var maxID int64
+		for _, item := range items {
+			record, _ := item.(map[string]any)
+			if id, ok := record["id"].(json.Number); ok {
+				if n, err := id.Int64(); err == nil && n > maxID {
+					maxID = n
+				}
+			}
+		}
+		if current, ok := sequences[name].(json.Number); ok {
+			if n, err := current.Int64(); err == nil && n > maxID {
+				maxID = n
+			}
+		}
+		sequences[name] = maxID
+	}
+
+	doc["sequences"] = sequences
+	return nil
+}
+
+func migrateDefaultSchedule(doc jsonDocument) error {
+	if doc["schedule"] != nil {
+		return nil
+	}
+
+	schedule := defaultSchedule()
+	schedule.ID = 1
+	schedule.CreatedAt = time.Now()
+	schedule.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+	var value map[string]any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	doc["schedule"] = value
+	return nil
+}
+
+// migrateDerivedSuspension elimina users[].is_suspended. Un utente marcato
+// sospeso senza una sospensione attiva riceve una sospensione permanente, così
+// resta sospeso finché un admin non la termina o la annulla.
+func migrateDerivedSuspension(doc jsonDocument) error {
+	now := time.Now()
+	activeByDonor := map[string]bool{}
+	suspensions, _ := doc["suspensions"].([]any)
+	for _, item := range suspensions {
+		record, _ := item.(map[string]any)
+		if active, _ := record["is_active"].(bool); !active {
+			continue
+		}
+		end, _ := time.Parse(time.RFC3339, fmt.Sprint(record["end_date"]))
+		if permanent, _ := record["permanent"].(bool); permanent || now.Before(end) {
+			activeByDonor[fmt.Sprint(record["donor_id"])] = true
+		}
+	}
+
+	sequences, _ := doc["sequences"].(map[string]any)
+	if sequences == nil {
+		sequences = map[string]any{}
+		doc["sequences"] = sequences
+	}
+	lastID, _ := strconv.ParseInt(fmt.Sprint(sequences["suspensions"]), 10, 64)
+
+	users, _ := doc["users"].([]any)
+	for _, item := range users {
+		record, _ := item.(map[string]any)
+		if record == nil {
+			continue
+		}
+		suspended, _ := record["is_suspended"].(bool)
+		delete(record, "is_suspended")
+		if !suspended || activeByDonor[fmt.Sprint(record["id"])] {
+			continue
+		}
+
+		lastID++
+		log.Printf("Utente %v marcato sospeso senza sospensioni attive: creata la sospensione permanente %d", record["id"], lastID)
+		suspensions = append(suspensions, map[string]any{
+			"id":              lastID,
+			"created_at":      now,
+			"updated_at":      now,
+			"donor_id":        record["id"],
+			"start_date":      now,
+			"duration_months": 0,
+			"end_date":        time.Time{},
+			"reason":          "Sospensione senza data di fine (dal flag is_suspended)",
+			"permanent":       true,
+			"is_active":       true,
+		})
+	}
+	doc["suspensions"] = suspensions
+	sequences["suspensions"] = lastID
+	return nil
+}
+
+func migrateDeferralReasons(doc jsonDocument) error {
+	if err := addCollection("deferral_reasons")(doc); err != nil {
+		return err
+	}
+	if items, _ := doc["deferral_reasons"].([]any); len(items) > 0 {
+		return nil
+	}
+
+	reasons := defaultDeferralReasons()
+	for i := range reasons {
+		reasons[i].ID = uint(i + 1)
+		reasons[i].IsActive = true
+		reasons[i].CreatedAt = time.Now()
+		reasons[i].UpdatedAt = time.Now()
+	}
+	return seedCollection(doc, "deferral_reasons", reasons, len(reasons))
+}
+
+// seedCollection scrive nel documento i record iniziali di una collezione
+// vuota e porta la sua sequenza all'ultimo ID usato
+func seedCollection(doc jsonDocument, name string, records any, lastID int) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	var value []any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	doc[name] = value
+	doc["sequences"].(map[string]any)[name] = lastID
+	return nil
+}
+
+// migrateDonationTypes aggiunge la matrice degli intervalli tra tipi di
+// donazione e assegna il tipo sangue intero a donazioni e appuntamenti esistenti
+func migrateDonationTypes(doc jsonDocument) error {
+	for _, name := range []string{"donations", "appointments"} {
+		items, _ := doc[name].([]any)
+		for _, item := range items {
+			record, _ := item.(map[string]any)
+			if record != nil && (record["donation_type"] == nil || record["donation_type"] == "") {
+				record["donation_type"] = string(models.DonationTypeWholeBlood)
+			}
+		}
+	}
+
+	if err := addCollection("donation_intervals")(doc); err != nil {
+		return err
+	}
+	if items, _ := doc["donation_intervals"].([]any); len(items) > 0 {
+		return nil
+	}
+	intervals := defaultDonationIntervals()
+	for i := range intervals {
+		intervals[i].ID = uint(i + 1)
+		intervals[i].CreatedAt = time.Now()
+		intervals[i].UpdatedAt = time.Now()
+	}
+	return seedCollection(doc, "donation_intervals", intervals, len(intervals))
+}
+
+// migrateQuestionnaires aggiunge le collezioni del questionario e pubblica
+// la versione 1 di default, collegata ai motivi del catalogo per codice
+func migrateQuestionnaires(doc jsonDocument) error {
+	for _, name := range []string{"questionnaires", "questionnaire_responses"} {
+		if err := addCollection(name)(doc); err != nil {
+			return err
+		}
+	}
+	if items, _ := doc["questionnaires"].([]any); len(items) > 0 {
+		return nil
+	}
+
+	reasonIDs := map[string]uint{}
+	reasons, _ := doc["deferral_reasons"].([]any)
+	for _, item := range reasons {
+		record, _ := item.(map[string]any)
+		code, _ := record["code"].(string)
+		if id, ok := record["id"].(float64); ok && code != "" {
+			reasonIDs[code] = uint(id)
+		}
+	}
+	questionnaire, _ := defaultQuestionnaire(reasonIDs)
+	now := time.Now()
+	questionnaire.ID = 1
+	questionnaire.CreatedAt = now
+	questionnaire.UpdatedAt = now
+	questionnaire.PublishedAt = &now
+	return seedCollection(doc, "questionnaires", []models.Questionnaire{questionnaire}, 1)
+}
+
+// recordID - ID di un record del documento: json.Number se letto dal file,
+// float64 se aggiunto da seedCollection nella stessa esecuzione
+func recordID(record map[string]any) (uint, bool) {
+	switch id := record["id"].(type) {
+	case json.Number:
+		n, err := id.Int64()
+		return uint(n), err == nil && n > 0
+	case float64:
+		return uint(id), id > 0
+	}
+	return 0, false
+}
+
+// migrateQuestionnaireReasons collega ai motivi del catalogo le domande del
+// questionario di default rimaste senza motivo suggerito: il passo 10 leggeva
+// solo gli ID float64, mentre quelli letti dal file sono json.Number, quindi
+// su un file già alla versione 9 non ne trovava nessuno
+func migrateQuestionnaireReasons(doc jsonDocument) error {
+	reasonIDs := map[string]uint{}
+	reasons, _ := doc["deferral_reasons"].([]any)
+	for _, item := range reasons {
+		record, _ := item.(map[string]any)
+		code, _ := record["code"].(string)
+		if id, ok := recordID(record); ok && code != "" {
+			reasonIDs[code] = id
+		}
+	}
+	defaults, _ := defaultQuestionnaire(reasonIDs)
+	suggested := map[string]uint{}
+	for _, question := range defaults.Questions {
+		if question.DeferralReasonID != nil {
+			suggested[question.Code] = *question.DeferralReasonID
+		}
+	}
+
+	var missing []string
+	questionnaires, _ := doc["questionnaires"].([]any)
+	for _, item := range questionnaires {
+		record, _ := item.(map[string]any)
+		if record == nil || fmt.Sprint(record["version"]) != "1" {
+			continue
+		}
+		questions, _ := record["questions"].([]any)
+		for _, q := range questions {
+			question, _ := q.(map[string]any)
+			if question == nil || question["deferral_reason_id"] != nil {
+				continue
+			}
+			code, _ := question["code"].(string)
+			if id, ok := suggested[code]; ok {
+				question["deferral_reason_id"] = id
+			} else if question["answer_type"] == string(models.AnswerTypeYesNo) {
+				missing = append(missing, code)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		log.Printf("ATTENZIONE: domande del questionario di default ancora senza motivo suggerito (%s): collegale dal questionario", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// migrateLegacySuspensionsToReview - Il passo 7 escludeva in modo permanente
+// chi aveva il flag is_suspended, anche se era sospeso solo temporaneamente:
+// quelle sospensioni diventano legacySuspension, ancora attive ma da
+// revisionare, e i donatori coinvolti vengono segnalati nel log
+func migrateLegacySuspensionsToReview(doc jsonDocument) error {
+	now := time.Now()
+	suspensions, _ := doc["suspensions"].([]any)
+	for _, item := range suspensions {
+		record, _ := item.(map[string]any)
+		if record == nil || record["reason"] != "Sospensione senza data di fine (dal flag is_suspended)" {
+			continue
+		}
+		permanent, _ := record["permanent"].(bool)
+		active, _ := record["is_active"].(bool)
+		if !permanent || !active || record["reviewed_at"] != nil || record["cancelled_at"] != nil {
+			continue
+		}
+		record["reason"] = legacySuspensionReason
+		record["pending_review"] = true
+		record["updated_at"] = now
+		log.Printf("ATTENZIONE: la sospensione %v del donatore %v (dal flag is_suspended) è da revisionare: fissane durata e motivo, oppure terminala", record["id"], record["donor_id"])
+	}
+	return nil
+}
+
+// migrate applica i passi in sospeso al file dati. Prima di scrivere il file
+// migrato ne conserva una copia (file.pre-v<N>-<data>); con dryRun elenca i
+// passi e verifica che il risultato sia leggibile senza modificare nulla.
+func (db *JSONDatabase) migrate(dryRun bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Si parte dal file su disco, che può contenere campi ignorati dai modelli
+	data, err := os.ReadFile(db.filename)
+	exists := err == nil
+	if os.IsNotExist(err) {
+		data, err = json.Marshal(db)
+	}
+	if err != nil {
+		return err
+	}
+
+	doc := jsonDocument{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("file dati non valido: %w", err)
+	}
+
+	from := 0
+	if v, ok := doc["schema_version"].(json.Number); ok {
+		n, err := v.Int64()
+		if err != nil {
+			return fmt.Errorf("schema_version non valida: %s", v)
+		}
+		from = int(n)
+	}
+	latest := latestSchemaVersion()
+	if from > latest {
+		return fmt.Errorf("il file dati è alla versione %d, questa versione del server supporta fino alla %d", from, latest)
+	}
+	if from == latest {
+		log.Printf("Schema del file dati aggiornato (versione %d)", from)
+		return nil
+	}
+
+	for _, m := range jsonMigrations {
+		if m.Version <= from {
+			continue
+		}
+		if dryRun {
+			log.Printf("[dry-run] Migrazione %d: %s", m.Version, m.Description)
+		} else {
+			log.Printf("Migrazione %d: %s", m.Version, m.Description)
+		}
+		if err := m.Up(doc); err != nil {
+			return fmt.Errorf("migrazione %d fallita: %w", m.Version, err)
+		}
+		doc["schema_version"] = m.Version
+	}
+
+	// Il risultato deve essere leggibile dai modelli attuali
+	migratedData, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	migrated := newJSONDatabase(db.filename)
+	if err := json.Unmarshal(migratedData, migrated); err != nil {
+		return fmt.Errorf("il file migrato non è leggibile: %w", err)
+	}
+
+	if dryRun {
+		log.Printf("[dry-run] Il file dati passerebbe dalla versione %d alla %d: nessuna modifica scritta", from, latest)
+		return nil
+	}
+
+	if exists {
+		backup := fmt.Sprintf("%s.pre-v%d-%s", db.filename, latest, time.Now().Format("20060102-150405"))
+		if err := copyFile(db.filename, backup); err != nil {
+			return fmt.Errorf("impossibile salvare la copia pre-migrazione: %w", err)
+		}
+		log.Println("Copia del file dati prima della migrazione:", backup)
+	}
+
+	db.restore(migrated.snapshot())
+	db.SchemaVersion = migrated.SchemaVersion
+	if err := db.save(); err != nil {
+		return err
+	}
+	log.Printf("File dati migrato dalla versione %d alla %d", from, latest)
+	return nil
+}
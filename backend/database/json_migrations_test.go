@@ -0,0 +1,138 @@
+package database
+
+import (
+	"bloodone/models"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMigrateQuestionnaireReasonsFromV9 - Un file già alla versione 9 ha i
+// motivi del catalogo con ID letti dal file (json.Number): dopo la
+// migrazione le domande del questionario di default devono esservi collegate
+func TestMigrateQuestionnaireReasonsFromV9(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.json")
+	ConnectJSON(filename).Migrate()
+
+	// Riporta il file alla versione 9, prima del questionario
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := jsonDocument{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	doc["schema_version"] = 9
+	delete(doc, "questionnaires")
+	delete(doc, "questionnaire_responses")
+	if data, err = json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := ConnectJSON(filename)
+	db.Migrate()
+	reasons, err := db.ListDeferralReasons()
+	if err != nil || len(reasons) == 0 {
+		t.Fatalf("ListDeferralReasons = %d, %v", len(reasons), err)
+	}
+	codes := map[uint]string{}
+	for _, reason := range reasons {
+		codes[reason.ID] = reason.Code
+	}
+	questionnaire, err := db.GetQuestionnaire(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"tattoo": "tattoo", "fever": "fever", "malaria_area": "malaria-area", "feeling_well": "fever"}
+	for _, question := range questionnaire.Questions {
+		code, ok := want[question.Code]
+		if !ok {
+			continue
+		}
+		if question.DeferralReasonID == nil || codes[*question.DeferralReasonID] != code {
+			t.Errorf("domanda %s: motivo %v, want %s", question.Code, question.DeferralReasonID, code)
+		}
+	}
+}
+
+// assertLegacySuspension - Dopo la migrazione il donatore 1, marcato con il
+// vecchio flag is_suspended, ha una sola sospensione attiva da revisionare
+func assertLegacySuspension(t *testing.T, store Store) {
+	t.Helper()
+	suspensions, err := store.ListSuspensionsByDonor(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suspensions) != 1 {
+		t.Fatalf("sospensioni = %d, want 1", len(suspensions))
+	}
+	s := suspensions[0]
+	if !s.IsActive || !s.PendingReview || s.Reason != legacySuspensionReason {
+		t.Errorf("sospensione = attiva %v, da revisionare %v, motivo %q", s.IsActive, s.PendingReview, s.Reason)
+	}
+	// Il donatore già sospeso con una sospensione in corso non ne riceve un'altra
+	if others, _ := store.ListSuspensionsByDonor(2); len(others) != 1 || others[0].PendingReview {
+		t.Errorf("sospensioni del donatore 2 = %+v", others)
+	}
+}
+
+func TestMigrateLegacySuspendedJSON(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.json")
+	end := time.Now().AddDate(0, 1, 0).UTC().Format(time.RFC3339)
+	data := `{
+		"users": [
+			{"id": 1, "email": "uno@example.com", "is_active": true, "is_suspended": true},
+			{"id": 2, "email": "due@example.com", "is_active": true, "is_suspended": true}
+		],
+		"suspensions": [
+			{"id": 1, "donor_id": 2, "reason": "Tatuaggio", "start_date": "2026-01-01T00:00:00Z", "end_date": "` + end + `", "is_active": true}
+		]
+	}`
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := ConnectJSON(filename)
+	db.Migrate()
+	assertLegacySuspension(t, db)
+}
+
+func TestMigrateLegacySuspendedSQL(t *testing.T) {
+	db := ConnectSQL(filepath.Join(t.TempDir(), "data.db"))
+	db.Migrate()
+
+	// Database di una versione precedente, con la colonna is_suspended
+	end := time.Now().AddDate(0, 1, 0)
+	for _, sql := range []string{
+		"ALTER TABLE users ADD COLUMN is_suspended numeric DEFAULT false",
+		"INSERT INTO users (id, email, google_id, first_name, last_name, is_active, is_suspended) VALUES (1, 'uno@example.com', 'g1', 'Uno', 'Rossi', true, true)",
+		"INSERT INTO users (id, email, google_id, first_name, last_name, is_active, is_suspended) VALUES (2, 'due@example.com', 'g2', 'Due', 'Rossi', true, true)",
+	} {
+		if err := db.db.Exec(sql).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.db.Create(&models.Suspension{DonorID: 2, Reason: "Tatuaggio", StartDate: time.Now(), EndDate: end, IsActive: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	db.Migrate()
+	assertLegacySuspension(t, db)
+	if db.db.Migrator().HasColumn(&models.User{}, "is_suspended") {
+		t.Error("la colonna is_suspended deve essere eliminata")
+	}
+	// Il passo non si ripete
+	db.Migrate()
+	if suspensions, _ := db.ListSuspensionsByDonor(1); len(suspensions) != 1 {
+		t.Errorf("sospensioni dopo una seconda migrazione = %d, want 1", len(suspensions))
+	}
+}
@@ -0,0 +1,674 @@
+package database
+
+import (
+	"bloodone/models"
+)
+
+// jsonTx - Operazioni sui dati in memoria del JSONDatabase. Non prende lock e
+// non salva: è usato solo all'interno di read e WithTx, che se ne occupano.
+type jsonTx struct {
+	db *JSONDatabase
+}
+
+// WithTx all'interno di una transazione partecipa a quella già aperta
+func (tx *jsonTx) WithTx(fn func(tx Store) error) error {
+	return fn(tx)
+}
+
+// Helper generici sulle slice in memoria
+
+// nextID restituisce il prossimo valore della sequenza name. La sequenza è
+// salvata nel file e non torna mai indietro, anche dopo una cancellazione;
+// il massimo ID esistente copre i file scritti prima delle sequenze.
+func nextID[T any](tx *jsonTx, name string, items []T, getID func(*T) uint) uint {
+	if tx.db.Sequences == nil {
+		tx.db.Sequences = map[string]uint{}
+	}
+	next := tx.db.Sequences[name]
+	for i := range items {
+		if id := getID(&items[i]); id > next {
+			next = id
+		}
+	}
+	next++
+	tx.db.Sequences[name] = next
+	return next
+}
+
+func indexByID[T any](items []T, id uint, getID func(*T) uint) int {
+	for i := range items {
+		if getID(&items[i]) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func filter[T any](items []T, keep func(*T) bool) []T {
+	result := []T{}
+	for i := range items {
+		if keep(&items[i]) {
+			result = append(result, items[i])
+		}
+	}
+	return result
+}
+
+func userID(u *models.User) uint                                   { return u.ID }
+func donationID(d *models.Donation) uint                           { return d.ID }
+func appointmentID(a *models.Appointment) uint                     { return a.ID }
+func suspensionID(s *models.Suspension) uint                       { return s.ID }
+func registrationRequestID(r *models.RegistrationRequest) uint     { return r.ID }
+func excludedDateID(e *models.ExcludedDate) uint                   { return e.ID }
+func specialCapacityID(s *models.SpecialCapacity) uint             { return s.ID }
+func timeSlotRuleID(r *models.TimeSlotRule) uint                   { return r.ID }
+func appointmentEventID(a *models.AppointmentEvent) uint           { return a.ID }
+func adminNotificationID(a *models.AdminNotification) uint         { return a.ID }
+func jobRunID(j *models.JobRun) uint                               { return j.ID }
+func deferralReasonID(d *models.DeferralReason) uint               { return d.ID }
+func donationIntervalID(d *models.DonationInterval) uint           { return d.ID }
+func questionnaireID(q *models.Questionnaire) uint                 { return q.ID }
+func questionnaireResponseID(q *models.QuestionnaireResponse) uint { return q.ID }
+func notificationID(n *models.Notification) uint                   { return n.ID }
+func recallID(r *models.Recall) uint                               { return r.ID }
+
+// Utenti
+
+func (tx *jsonTx) ListUsers() ([]models.User, error) {
+	return append([]models.User{}, tx.db.Users...), nil
+}
+
+func (tx *jsonTx) GetUser(id uint) (*models.User, error) {
+	if i := indexByID(tx.db.Users, id, userID); i >= 0 {
+		user := tx.db.Users[i]
+		return &user, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) FindUserByEmail(email string) (*models.User, error) {
+	for _, u := range tx.db.Users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) FindUserByGoogleID(googleID string) (*models.User, error) {
+	for _, u := range tx.db.Users {
+		if googleID != "" && u.GoogleID == googleID {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateUser(user *models.User) error {
+	if user.ID == 0 {
+		user.ID = nextID(tx, "users", tx.db.Users, userID)
+	}
+	tx.db.Users = append(tx.db.Users, *user)
+	return nil
+}
+
+func (tx *jsonTx) UpdateUser(user *models.User) error {
+	i := indexByID(tx.db.Users, user.ID, userID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Users[i] = *user
+	return nil
+}
+
+func (tx *jsonTx) DeleteUser(id uint) error {
+	i := indexByID(tx.db.Users, id, userID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Users = append(tx.db.Users[:i], tx.db.Users[i+1:]...)
+	return nil
+}
+
+// Donazioni
+
+func (tx *jsonTx) ListDonations() ([]models.Donation, error) {
+	return append([]models.Donation{}, tx.db.Donations...), nil
+}
+
+func (tx *jsonTx) ListDonationsByDonor(donorID uint) ([]models.Donation, error) {
+	return filter(tx.db.Donations, func(d *models.Donation) bool { return d.DonorID == donorID }), nil
+}
+
+func (tx *jsonTx) GetDonation(id uint) (*models.Donation, error) {
+	if i := indexByID(tx.db.Donations, id, donationID); i >= 0 {
+		donation := tx.db.Donations[i]
+		return &donation, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateDonation(donation *models.Donation) error {
+	if donation.ID == 0 {
+		donation.ID = nextID(tx, "donations", tx.db.Donations, donationID)
+	}
+	tx.db.Donations = append(tx.db.Donations, *donation)
+	return nil
+}
+
+func (tx *jsonTx) UpdateDonation(donation *models.Donation) error {
+	i := indexByID(tx.db.Donations, donation.ID, donationID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Donations[i] = *donation
+	return nil
+}
+
+func (tx *jsonTx) DeleteDonation(id uint) error {
+	i := indexByID(tx.db.Donations, id, donationID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Donations = append(tx.db.Donations[:i], tx.db.Donations[i+1:]...)
+	return nil
+}
+
+// Appuntamenti
+
+func (tx *jsonTx) ListAppointments() ([]models.Appointment, error) {
+	return append([]models.Appointment{}, tx.db.Appointments...), nil
+}
+
+func (tx *jsonTx) ListAppointmentsByDonor(donorID uint) ([]models.Appointment, error) {
+	return filter(tx.db.Appointments, func(a *models.Appointment) bool { return a.DonorID == donorID }), nil
+}
+
+func (tx *jsonTx) GetAppointment(id uint) (*models.Appointment, error) {
+	if i := indexByID(tx.db.Appointments, id, appointmentID); i >= 0 {
+		appointment := tx.db.Appointments[i]
+		return &appointment, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateAppointment(appointment *models.Appointment) error {
+	if appointment.ID == 0 {
+		appointment.ID = nextID(tx, "appointments", tx.db.Appointments, appointmentID)
+	}
+	tx.db.Appointments = append(tx.db.Appointments, *appointment)
+	return nil
+}
+
+func (tx *jsonTx) UpdateAppointment(appointment *models.Appointment) error {
+	i := indexByID(tx.db.Appointments, appointment.ID, appointmentID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Appointments[i] = *appointment
+	return nil
+}
+
+func (tx *jsonTx) DeleteAppointment(id uint) error {
+	i := indexByID(tx.db.Appointments, id, appointmentID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Appointments = append(tx.db.Appointments[:i], tx.db.Appointments[i+1:]...)
+	return nil
+}
+
+// Sospensioni
+
+func (tx *jsonTx) ListSuspensions() ([]models.Suspension, error) {
+	return append([]models.Suspension{}, tx.db.Suspensions...), nil
+}
+
+func (tx *jsonTx) ListSuspensionsByDonor(donorID uint) ([]models.Suspension, error) {
+	return filter(tx.db.Suspensions, func(s *models.Suspension) bool { return s.DonorID == donorID }), nil
+}
+
+func (tx *jsonTx) GetSuspension(id uint) (*models.Suspension, error) {
+	if i := indexByID(tx.db.Suspensions, id, suspensionID); i >= 0 {
+		suspension := tx.db.Suspensions[i]
+		return &suspension, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateSuspension(suspension *models.Suspension) error {
+	if suspension.ID == 0 {
+		suspension.ID = nextID(tx, "suspensions", tx.db.Suspensions, suspensionID)
+	}
+	tx.db.Suspensions = append(tx.db.Suspensions, *suspension)
+	return nil
+}
+
+func (tx *jsonTx) UpdateSuspension(suspension *models.Suspension) error {
+	i := indexByID(tx.db.Suspensions, suspension.ID, suspensionID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Suspensions[i] = *suspension
+	return nil
+}
+
+// Richieste di registrazione
+
+func (tx *jsonTx) ListRegistrationRequests() ([]models.RegistrationRequest, error) {
+	return append([]models.RegistrationRequest{}, tx.db.RegistrationRequests...), nil
+}
+
+func (tx *jsonTx) GetRegistrationRequest(id uint) (*models.RegistrationRequest, error) {
+	if i := indexByID(tx.db.RegistrationRequests, id, registrationRequestID); i >= 0 {
+		request := tx.db.RegistrationRequests[i]
+		return &request, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateRegistrationRequest(request *models.RegistrationRequest) error {
+	if request.ID == 0 {
+		request.ID = nextID(tx, "registration_requests", tx.db.RegistrationRequests, registrationRequestID)
+	}
+	tx.db.RegistrationRequests = append(tx.db.RegistrationRequests, *request)
+	return nil
+}
+
+func (tx *jsonTx) UpdateRegistrationRequest(request *models.RegistrationRequest) error {
+	i := indexByID(tx.db.RegistrationRequests, request.ID, registrationRequestID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.RegistrationRequests[i] = *request
+	return nil
+}
+
+func (tx *jsonTx) DeleteRegistrationRequest(id uint) error {
+	i := indexByID(tx.db.RegistrationRequests, id, registrationRequestID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.RegistrationRequests = append(tx.db.RegistrationRequests[:i], tx.db.RegistrationRequests[i+1:]...)
+	return nil
+}
+
+// Schedule, date escluse e capacità speciali
+
+func (tx *jsonTx) GetSchedule() (*models.DonationSchedule, error) {
+	if tx.db.Schedule == nil {
+		return nil, ErrNotFound
+	}
+	schedule := *tx.db.Schedule
+	return &schedule, nil
+}
+
+func (tx *jsonTx) SaveSchedule(schedule *models.DonationSchedule) error {
+	if schedule.ID == 0 {
+		schedule.ID = 1
+	}
+	saved := *schedule
+	tx.db.Schedule = &saved
+	return nil
+}
+
+func (tx *jsonTx) ListExcludedDates() ([]models.ExcludedDate, error) {
+	return append([]models.ExcludedDate{}, tx.db.ExcludedDates...), nil
+}
+
+func (tx *jsonTx) CreateExcludedDate(date *models.ExcludedDate) error {
+	if date.ID == 0 {
+		date.ID = nextID(tx, "excluded_dates", tx.db.ExcludedDates, excludedDateID)
+	}
+	tx.db.ExcludedDates = append(tx.db.ExcludedDates, *date)
+	return nil
+}
+
+func (tx *jsonTx) DeleteExcludedDate(id uint) error {
+	i := indexByID(tx.db.ExcludedDates, id, excludedDateID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.ExcludedDates = append(tx.db.ExcludedDates[:i], tx.db.ExcludedDates[i+1:]...)
+	return nil
+}
+
+func (tx *jsonTx) ListSpecialCapacities() ([]models.SpecialCapacity, error) {
+	return append([]models.SpecialCapacity{}, tx.db.SpecialCapacities...), nil
+}
+
+func (tx *jsonTx) CreateSpecialCapacity(capacity *models.SpecialCapacity) error {
+	if capacity.ID == 0 {
+		capacity.ID = nextID(tx, "special_capacities", tx.db.SpecialCapacities, specialCapacityID)
+	}
+	tx.db.SpecialCapacities = append(tx.db.SpecialCapacities, *capacity)
+	return nil
+}
+
+func (tx *jsonTx) DeleteSpecialCapacity(id uint) error {
+	i := indexByID(tx.db.SpecialCapacities, id, specialCapacityID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.SpecialCapacities = append(tx.db.SpecialCapacities[:i], tx.db.SpecialCapacities[i+1:]...)
+	return nil
+}
+
+// Fasce orarie
+
+func (tx *jsonTx) ListTimeSlotRules() ([]models.TimeSlotRule, error) {
+	return append([]models.TimeSlotRule{}, tx.db.TimeSlotRules...), nil
+}
+
+func (tx *jsonTx) CreateTimeSlotRule(rule *models.TimeSlotRule) error {
+	if rule.ID == 0 {
+		rule.ID = nextID(tx, "time_slot_rules", tx.db.TimeSlotRules, timeSlotRuleID)
+	}
+	tx.db.TimeSlotRules = append(tx.db.TimeSlotRules, *rule)
+	return nil
+}
+
+func (tx *jsonTx) UpdateTimeSlotRule(rule *models.TimeSlotRule) error {
+	i := indexByID(tx.db.TimeSlotRules, rule.ID, timeSlotRuleID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.TimeSlotRules[i] = *rule
+	return nil
+}
+
+func (tx *jsonTx) DeleteTimeSlotRule(id uint) error {
+	i := indexByID(tx.db.TimeSlotRules, id, timeSlotRuleID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.TimeSlotRules = append(tx.db.TimeSlotRules[:i], tx.db.TimeSlotRules[i+1:]...)
+	return nil
+}
+
+// Storico appuntamenti
+
+func (tx *jsonTx) ListAppointmentEvents() ([]models.AppointmentEvent, error) {
+	return append([]models.AppointmentEvent{}, tx.db.AppointmentEvents...), nil
+}
+
+func (tx *jsonTx) ListAppointmentEventsByAppointment(appointmentID uint) ([]models.AppointmentEvent, error) {
+	return filter(tx.db.AppointmentEvents, func(a *models.AppointmentEvent) bool { return a.AppointmentID == appointmentID }), nil
+}
+
+func (tx *jsonTx) CreateAppointmentEvent(event *models.AppointmentEvent) error {
+	if event.ID == 0 {
+		event.ID = nextID(tx, "appointment_events", tx.db.AppointmentEvents, appointmentEventID)
+	}
+	tx.db.AppointmentEvents = append(tx.db.AppointmentEvents, *event)
+	return nil
+}
+
+// Notifiche admin
+
+func (tx *jsonTx) ListAdminNotifications() ([]models.AdminNotification, error) {
+	return append([]models.AdminNotification{}, tx.db.AdminNotifications...), nil
+}
+
+func (tx *jsonTx) GetAdminNotification(id uint) (*models.AdminNotification, error) {
+	if i := indexByID(tx.db.AdminNotifications, id, adminNotificationID); i >= 0 {
+		notification := tx.db.AdminNotifications[i]
+		return &notification, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateAdminNotification(notification *models.AdminNotification) error {
+	if notification.ID == 0 {
+		notification.ID = nextID(tx, "admin_notifications", tx.db.AdminNotifications, adminNotificationID)
+	}
+	tx.db.AdminNotifications = append(tx.db.AdminNotifications, *notification)
+	return nil
+}
+
+func (tx *jsonTx) UpdateAdminNotification(notification *models.AdminNotification) error {
+	i := indexByID(tx.db.AdminNotifications, notification.ID, adminNotificationID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.AdminNotifications[i] = *notification
+	return nil
+}
+
+// Job pianificati
+
+func (tx *jsonTx) ListJobRuns() ([]models.JobRun, error) {
+	return append([]models.JobRun{}, tx.db.JobRuns...), nil
+}
+
+func (tx *jsonTx) FindJobRunByName(name string) (*models.JobRun, error) {
+	for _, r := range tx.db.JobRuns {
+		if r.Name == name {
+			return &r, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) GetJobRun(id uint) (*models.JobRun, error) {
+	if i := indexByID(tx.db.JobRuns, id, jobRunID); i >= 0 {
+		run := tx.db.JobRuns[i]
+		return &run, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateJobRun(run *models.JobRun) error {
+	if run.ID == 0 {
+		run.ID = nextID(tx, "job_runs", tx.db.JobRuns, jobRunID)
+	}
+	tx.db.JobRuns = append(tx.db.JobRuns, *run)
+	return nil
+}
+
+func (tx *jsonTx) UpdateJobRun(run *models.JobRun) error {
+	i := indexByID(tx.db.JobRuns, run.ID, jobRunID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.JobRuns[i] = *run
+	return nil
+}
+
+// Motivi di sospensione
+
+func (tx *jsonTx) ListDeferralReasons() ([]models.DeferralReason, error) {
+	return append([]models.DeferralReason{}, tx.db.DeferralReasons...), nil
+}
+
+func (tx *jsonTx) GetDeferralReason(id uint) (*models.DeferralReason, error) {
+	if i := indexByID(tx.db.DeferralReasons, id, deferralReasonID); i >= 0 {
+		reason := tx.db.DeferralReasons[i]
+		return &reason, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateDeferralReason(reason *models.DeferralReason) error {
+	if reason.ID == 0 {
+		reason.ID = nextID(tx, "deferral_reasons", tx.db.DeferralReasons, deferralReasonID)
+	}
+	tx.db.DeferralReasons = append(tx.db.DeferralReasons, *reason)
+	return nil
+}
+
+func (tx *jsonTx) UpdateDeferralReason(reason *models.DeferralReason) error {
+	i := indexByID(tx.db.DeferralReasons, reason.ID, deferralReasonID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.DeferralReasons[i] = *reason
+	return nil
+}
+
+// Intervalli tra donazioni
+
+func (tx *jsonTx) ListDonationIntervals() ([]models.DonationInterval, error) {
+	return append([]models.DonationInterval{}, tx.db.DonationIntervals...), nil
+}
+
+func (tx *jsonTx) GetDonationInterval(id uint) (*models.DonationInterval, error) {
+	if i := indexByID(tx.db.DonationIntervals, id, donationIntervalID); i >= 0 {
+		interval := tx.db.DonationIntervals[i]
+		return &interval, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateDonationInterval(interval *models.DonationInterval) error {
+	if interval.ID == 0 {
+		interval.ID = nextID(tx, "donation_intervals", tx.db.DonationIntervals, donationIntervalID)
+	}
+	tx.db.DonationIntervals = append(tx.db.DonationIntervals, *interval)
+	return nil
+}
+
+func (tx *jsonTx) UpdateDonationInterval(interval *models.DonationInterval) error {
+	i := indexByID(tx.db.DonationIntervals, interval.ID, donationIntervalID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.DonationIntervals[i] = *interval
+	return nil
+}
+
+func (tx *jsonTx) DeleteDonationInterval(id uint) error {
+	i := indexByID(tx.db.DonationIntervals, id, donationIntervalID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.DonationIntervals = append(tx.db.DonationIntervals[:i], tx.db.DonationIntervals[i+1:]...)
+	return nil
+}
+
+// Questionari anamnestici
+
+func (tx *jsonTx) ListQuestionnaires() ([]models.Questionnaire, error) {
+	return append([]models.Questionnaire{}, tx.db.Questionnaires...), nil
+}
+
+func (tx *jsonTx) GetQuestionnaire(id uint) (*models.Questionnaire, error) {
+	if i := indexByID(tx.db.Questionnaires, id, questionnaireID); i >= 0 {
+		questionnaire := tx.db.Questionnaires[i]
+		return &questionnaire, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateQuestionnaire(questionnaire *models.Questionnaire) error {
+	if questionnaire.ID == 0 {
+		questionnaire.ID = nextID(tx, "questionnaires", tx.db.Questionnaires, questionnaireID)
+	}
+	tx.db.Questionnaires = append(tx.db.Questionnaires, *questionnaire)
+	return nil
+}
+
+func (tx *jsonTx) UpdateQuestionnaire(questionnaire *models.Questionnaire) error {
+	i := indexByID(tx.db.Questionnaires, questionnaire.ID, questionnaireID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Questionnaires[i] = *questionnaire
+	return nil
+}
+
+// Risposte ai questionari
+
+func (tx *jsonTx) ListQuestionnaireResponsesByAppointment(appointmentID uint) ([]models.QuestionnaireResponse, error) {
+	return filter(tx.db.QuestionnaireResponses, func(q *models.QuestionnaireResponse) bool { return q.AppointmentID == appointmentID }), nil
+}
+
+func (tx *jsonTx) GetQuestionnaireResponse(id uint) (*models.QuestionnaireResponse, error) {
+	if i := indexByID(tx.db.QuestionnaireResponses, id, questionnaireResponseID); i >= 0 {
+		response := tx.db.QuestionnaireResponses[i]
+		return &response, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateQuestionnaireResponse(response *models.QuestionnaireResponse) error {
+	if response.ID == 0 {
+		response.ID = nextID(tx, "questionnaire_responses", tx.db.QuestionnaireResponses, questionnaireResponseID)
+	}
+	tx.db.QuestionnaireResponses = append(tx.db.QuestionnaireResponses, *response)
+	return nil
+}
+
+// Notifiche ai donatori
+
+func (tx *jsonTx) ListNotifications() ([]models.Notification, error) {
+	return append([]models.Notification{}, tx.db.Notifications...), nil
+}
+
+func (tx *jsonTx) ListNotificationsByUser(userID uint) ([]models.Notification, error) {
+	return filter(tx.db.Notifications, func(n *models.Notification) bool { return n.UserID != nil && *n.UserID == userID }), nil
+}
+
+func (tx *jsonTx) ListNotificationsByStatus(status string) ([]models.Notification, error) {
+	return filter(tx.db.Notifications, func(n *models.Notification) bool { return n.Status == status }), nil
+}
+
+func (tx *jsonTx) GetNotification(id uint) (*models.Notification, error) {
+	if i := indexByID(tx.db.Notifications, id, notificationID); i >= 0 {
+		notification := tx.db.Notifications[i]
+		return &notification, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateNotification(notification *models.Notification) error {
+	if notification.ID == 0 {
+		notification.ID = nextID(tx, "notifications", tx.db.Notifications, notificationID)
+	}
+	tx.db.Notifications = append(tx.db.Notifications, *notification)
+	return nil
+}
+
+func (tx *jsonTx) UpdateNotification(notification *models.Notification) error {
+	i := indexByID(tx.db.Notifications, notification.ID, notificationID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Notifications[i] = *notification
+	return nil
+}
+
+// Richiami
+
+func (tx *jsonTx) ListRecalls() ([]models.Recall, error) {
+	return append([]models.Recall{}, tx.db.Recalls...), nil
+}
+
+func (tx *jsonTx) ListRecallsByDonor(donorID uint) ([]models.Recall, error) {
+	return filter(tx.db.Recalls, func(r *models.Recall) bool { return r.DonorID == donorID }), nil
+}
+
+func (tx *jsonTx) GetRecall(id uint) (*models.Recall, error) {
+	if i := indexByID(tx.db.Recalls, id, recallID); i >= 0 {
+		recall := tx.db.Recalls[i]
+		return &recall, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (tx *jsonTx) CreateRecall(recall *models.Recall) error {
+	if recall.ID == 0 {
+		recall.ID = nextID(tx, "recalls", tx.db.Recalls, recallID)
+	}
+	tx.db.Recalls = append(tx.db.Recalls, *recall)
+	return nil
+}
+
+func (tx *jsonTx) UpdateRecall(recall *models.Recall) error {
+	i := indexByID(tx.db.Recalls, recall.ID, recallID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tx.db.Recalls[i] = *recall
+	return nil
+}
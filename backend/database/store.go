@@ -0,0 +1,353 @@
+package database
+
+import (
+	"bloodone/models"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound viene restituito quando il record richiesto non esiste
+var ErrNotFound = errors.New("record non trovato")
+
+// DB è lo store attivo, scelto da Connect in base alla configurazione
+var DB Store
+
+// Store - Accesso ai dati indipendente dal backend di persistenza.
+// Gli handler usano solo questa interfaccia: JSONDatabase e SQLDatabase
+// sono le due implementazioni disponibili.
+//
+// Ogni metodo è atomico preso da solo. Le operazioni che leggono e poi
+// modificano (o che toccano più record) vanno eseguite dentro WithTx, così
+// due richieste concorrenti non possono sovrascriversi a vicenda.
+type Store interface {
+	// WithTx esegue fn in una transazione: se fn restituisce errore nessuna
+	// modifica viene applicata. Chiamato su un tx partecipa alla stessa transazione.
+	WithTx(fn func(tx Store) error) error
+
+	UserStore
+	DonationStore
+	AppointmentStore
+	SuspensionStore
+	RegistrationRequestStore
+	ScheduleStore
+	TimeSlotRuleStore
+	AppointmentEventStore
+	AdminNotificationStore
+	JobRunStore
+	DeferralReasonStore
+	DonationIntervalStore
+	QuestionnaireStore
+	QuestionnaireResponseStore
+	NotificationStore
+	RecallStore
+}
+
+type UserStore interface {
+	ListUsers() ([]models.User, error)
+	GetUser(id uint) (*models.User, error)
+	FindUserByEmail(email string) (*models.User, error)
+	FindUserByGoogleID(googleID string) (*models.User, error)
+	CreateUser(user *models.User) error
+	UpdateUser(user *models.User) error
+	DeleteUser(id uint) error
+}
+
+type DonationStore interface {
+	ListDonations() ([]models.Donation, error)
+	ListDonationsByDonor(donorID uint) ([]models.Donation, error)
+	GetDonation(id uint) (*models.Donation, error)
+	CreateDonation(donation *models.Donation) error
+	UpdateDonation(donation *models.Donation) error
+	DeleteDonation(id uint) error
+}
+
+type AppointmentStore interface {
+	ListAppointments() ([]models.Appointment, error)
+	ListAppointmentsByDonor(donorID uint) ([]models.Appointment, error)
+	GetAppointment(id uint) (*models.Appointment, error)
+	CreateAppointment(appointment *models.Appointment) error
+	UpdateAppointment(appointment *models.Appointment) error
+	DeleteAppointment(id uint) error
+}
+
+type SuspensionStore interface {
+	ListSuspensions() ([]models.Suspension, error)
+	ListSuspensionsByDonor(donorID uint) ([]models.Suspension, error)
+	GetSuspension(id uint) (*models.Suspension, error)
+	CreateSuspension(suspension *models.Suspension) error
+	UpdateSuspension(suspension *models.Suspension) error
+}
+
+type RegistrationRequestStore interface {
+	ListRegistrationRequests() ([]models.RegistrationRequest, error)
+	GetRegistrationRequest(id uint) (*models.RegistrationRequest, error)
+	CreateRegistrationRequest(request *models.RegistrationRequest) error
+	UpdateRegistrationRequest(request *models.RegistrationRequest) error
+	DeleteRegistrationRequest(id uint) error
+}
+
+type ScheduleStore interface {
+	// GetSchedule restituisce ErrNotFound se la configurazione non è ancora stata creata
+	GetSchedule() (*models.DonationSchedule, error)
+	SaveSchedule(schedule *models.DonationSchedule) error
+
+	ListExcludedDates() ([]models.ExcludedDate, error)
+	CreateExcludedDate(date *models.ExcludedDate) error
+	DeleteExcludedDate(id uint) error
+
+	ListSpecialCapacities() ([]models.SpecialCapacity, error)
+	CreateSpecialCapacity(capacity *models.SpecialCapacity) error
+	DeleteSpecialCapacity(id uint) error
+}
+
+type TimeSlotRuleStore interface {
+	ListTimeSlotRules() ([]models.TimeSlotRule, error)
+	CreateTimeSlotRule(rule *models.TimeSlotRule) error
+	UpdateTimeSlotRule(rule *models.TimeSlotRule) error
+	DeleteTimeSlotRule(id uint) error
+}
+
+type AppointmentEventStore interface {
+	ListAppointmentEvents() ([]models.AppointmentEvent, error)
+	ListAppointmentEventsByAppointment(appointmentID uint) ([]models.AppointmentEvent, error)
+	CreateAppointmentEvent(event *models.AppointmentEvent) error
+}
+
+type AdminNotificationStore interface {
+	ListAdminNotifications() ([]models.AdminNotification, error)
+	GetAdminNotification(id uint) (*models.AdminNotification, error)
+	CreateAdminNotification(notification *models.AdminNotification) error
+	UpdateAdminNotification(notification *models.AdminNotification) error
+}
+
+type JobRunStore interface {
+	ListJobRuns() ([]models.JobRun, error)
+	FindJobRunByName(name string) (*models.JobRun, error)
+	GetJobRun(id uint) (*models.JobRun, error)
+	CreateJobRun(run *models.JobRun) error
+	UpdateJobRun(run *models.JobRun) error
+}
+
+type DeferralReasonStore interface {
+	ListDeferralReasons() ([]models.DeferralReason, error)
+	GetDeferralReason(id uint) (*models.DeferralReason, error)
+	CreateDeferralReason(reason *models.DeferralReason) error
+	UpdateDeferralReason(reason *models.DeferralReason) error
+}
+
+type DonationIntervalStore interface {
+	ListDonationIntervals() ([]models.DonationInterval, error)
+	GetDonationInterval(id uint) (*models.DonationInterval, error)
+	CreateDonationInterval(interval *models.DonationInterval) error
+	UpdateDonationInterval(interval *models.DonationInterval) error
+	DeleteDonationInterval(id uint) error
+}
+
+type QuestionnaireStore interface {
+	ListQuestionnaires() ([]models.Questionnaire, error)
+	GetQuestionnaire(id uint) (*models.Questionnaire, error)
+	CreateQuestionnaire(questionnaire *models.Questionnaire) error
+	UpdateQuestionnaire(questionnaire *models.Questionnaire) error
+}
+
+type QuestionnaireResponseStore interface {
+	ListQuestionnaireResponsesByAppointment(appointmentID uint) ([]models.QuestionnaireResponse, error)
+	GetQuestionnaireResponse(id uint) (*models.QuestionnaireResponse, error)
+	CreateQuestionnaireResponse(response *models.QuestionnaireResponse) error
+}
+
+type NotificationStore interface {
+	ListNotifications() ([]models.Notification, error)
+	ListNotificationsByUser(userID uint) ([]models.Notification, error)
+	ListNotificationsByStatus(status string) ([]models.Notification, error)
+	GetNotification(id uint) (*models.Notification, error)
+	CreateNotification(notification *models.Notification) error
+	UpdateNotification(notification *models.Notification) error
+}
+
+type RecallStore interface {
+	ListRecalls() ([]models.Recall, error)
+	ListRecallsByDonor(donorID uint) ([]models.Recall, error)
+	GetRecall(id uint) (*models.Recall, error)
+	CreateRecall(recall *models.Recall) error
+	UpdateRecall(recall *models.Recall) error
+}
+
+// Connect apre lo store configurato tramite DB_DRIVER ("json" o "sqlite")
+// e DB_PATH (file dati, default in base al driver)
+func Connect() {
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = "json"
+	}
+	path := os.Getenv("DB_PATH")
+
+	switch driver {
+	case "json":
+		if path == "" {
+			path = "bloodone_data.json"
+		}
+		DB = ConnectJSON(path)
+	case "sqlite":
+		if path == "" {
+			path = "bloodone.db"
+		}
+		DB = ConnectSQL(path)
+	default:
+		log.Fatalf("DB_DRIVER non supportato: %q (valori ammessi: json, sqlite)", driver)
+	}
+}
+
+// Migrate prepara lo store attivo (schema e dati di default)
+func Migrate() {
+	switch db := DB.(type) {
+	case *JSONDatabase:
+		db.Migrate()
+	case *SQLDatabase:
+		db.Migrate()
+	}
+	log.Println("Database migrated successfully")
+}
+
+// MigrateDryRun elenca le migrazioni in sospeso senza applicarle
+func MigrateDryRun() {
+	switch db := DB.(type) {
+	case *JSONDatabase:
+		if err := db.migrate(true); err != nil {
+			log.Fatal("Migration dry-run failed: ", err)
+		}
+	case *SQLDatabase:
+		log.Println("[dry-run] Con sqlite lo schema è gestito da AutoMigrate: nessuna anteprima disponibile")
+	}
+}
+
+// defaultSchedule - Configurazione iniziale dei giorni di donazione
+func defaultSchedule() *models.DonationSchedule {
+	return &models.DonationSchedule{
+		Monday:            true,
+		Tuesday:           true,
+		Wednesday:         false,
+		Thursday:          false,
+		Friday:            true,
+		Saturday:          false,
+		Sunday:            false,
+		MondayCapacity:    10,
+		TuesdayCapacity:   9,
+		WednesdayCapacity: 10,
+		ThursdayCapacity:  10,
+		FridayCapacity:    10,
+		SaturdayCapacity:  10,
+		SundayCapacity:    10,
+	}
+}
+
+// legacySuspensionReason - Motivo delle sospensioni che sostituiscono il
+// vecchio flag users.is_suspended, che non diceva né perché né fino a quando
+const legacySuspensionReason = "Da verificare: donatore sospeso con il vecchio flag is_suspended, senza motivo né data di fine"
+
+// legacySuspension - Sospensione per un donatore marcato con il vecchio flag
+// is_suspended: resta bloccato, ma la sospensione è in attesa di revisione
+// finché un admin non ne fissa durata e motivo, la termina o la annulla
+func legacySuspension(donorID uint, now time.Time) models.Suspension {
+	return models.Suspension{
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		DonorID:       donorID,
+		StartDate:     now,
+		Reason:        legacySuspensionReason,
+		Permanent:     true,
+		IsActive:      true,
+		PendingReview: true,
+	}
+}
+
+// defaultDeferralReasons - Catalogo iniziale dei motivi di sospensione
+func defaultDeferralReasons() []models.DeferralReason {
+	return []models.DeferralReason{
+		{Code: "tattoo", Name: "Tatuaggio o piercing", Category: models.DeferralCategoryLifestyle, DefaultDurationMonths: 4},
+		{Code: "malaria-area", Name: "Viaggio in zona malarica", Category: models.DeferralCategoryTravel, DefaultDurationMonths: 6},
+		{Code: "low-haemoglobin", Name: "Emoglobina bassa", Category: models.DeferralCategoryMedical, DefaultDurationMonths: 3},
+		{Code: "surgery", Name: "Intervento chirurgico", Category: models.DeferralCategoryMedical, DefaultDurationMonths: 4},
+		{Code: "pregnancy", Name: "Gravidanza", Category: models.DeferralCategoryMedical, DefaultDurationMonths: 12},
+		{Code: "fever", Name: "Febbre o sindrome influenzale", Category: models.DeferralCategoryMedical, DefaultDurationWeeks: 2},
+		{Code: "dental", Name: "Cure odontoiatriche", Category: models.DeferralCategoryMedical, DefaultDurationDays: 7},
+		{Code: "permanent", Name: "Esclusione permanente", Category: models.DeferralCategoryMedical, Permanent: true},
+	}
+}
+
+// defaultQuestionnaire - Versione 1 del questionario anamnestico. Le
+// risposte a rischio suggeriscono il motivo del catalogo con quel codice
+// (reasonIDs per codice); restituisce anche i codici non trovati, le cui
+// domande restano senza motivo suggerito.
+func defaultQuestionnaire(reasonIDs map[string]uint) (models.Questionnaire, []string) {
+	var missing []string
+	reason := func(code string) *uint {
+		if id, ok := reasonIDs[code]; ok {
+			return &id
+		}
+		for _, m := range missing {
+			if m == code {
+				return nil
+			}
+		}
+		missing = append(missing, code)
+		return nil
+	}
+	yesNo := func(code, text, disqualifying, reasonCode string) models.Question {
+		return models.Question{
+			Code:                 code,
+			Text:                 text,
+			AnswerType:           models.AnswerTypeYesNo,
+			Required:             true,
+			DisqualifyingAnswers: []string{disqualifying},
+			DeferralReasonID:     reason(reasonCode),
+		}
+	}
+	pregnancy := yesNo("pregnancy", "Sei in gravidanza o hai partorito nell'ultimo anno? (solo donatrici)", "yes", "pregnancy")
+	pregnancy.Required = false
+	questionnaire := models.Questionnaire{
+		Version: 1,
+		Title:   "Questionario anamnestico",
+		Questions: models.QuestionList{
+			yesNo("feeling_well", "Ti senti in buona salute oggi?", "no", "fever"),
+			yesNo("fever", "Hai avuto febbre o sindrome influenzale nelle ultime 2 settimane?", "yes", "fever"),
+			yesNo("tattoo", "Hai fatto tatuaggi, piercing o agopuntura negli ultimi 4 mesi?", "yes", "tattoo"),
+			yesNo("malaria_area", "Hai soggiornato in zone malariche negli ultimi 6 mesi?", "yes", "malaria-area"),
+			yesNo("surgery", "Hai subito interventi chirurgici o endoscopie negli ultimi 4 mesi?", "yes", "surgery"),
+			yesNo("dental", "Hai fatto cure odontoiatriche nell'ultima settimana?", "yes", "dental"),
+			pregnancy,
+			{Code: "medications", Text: "Farmaci assunti negli ultimi 7 giorni", AnswerType: models.AnswerTypeText},
+			{Code: "notes", Text: "Altre informazioni per il medico", AnswerType: models.AnswerTypeText},
+		},
+	}
+	return questionnaire, missing
+}
+
+// logMissingReasons avvisa dei motivi del catalogo non trovati durante la
+// creazione del questionario di default
+func logMissingReasons(missing []string) {
+	if len(missing) > 0 {
+		log.Printf("ATTENZIONE: questionario di default creato senza motivo suggerito per i codici %s: mancano nel catalogo dei motivi di sospensione, collega le domande dal questionario", strings.Join(missing, ", "))
+	}
+}
+
+// defaultDonationIntervals - Intervalli minimi iniziali tra tipi di donazione:
+// 90 giorni tra due donazioni di sangue intero per gli uomini e 180 per le
+// donne, 30 giorni dal sangue intero a un'aferesi, 14 giorni dopo un'aferesi
+func defaultDonationIntervals() []models.DonationInterval {
+	intervals := []models.DonationInterval{
+		{PreviousType: models.DonationTypeWholeBlood, NextType: models.DonationTypeWholeBlood, Gender: models.GenderMale, IntervalDays: 90},
+		{PreviousType: models.DonationTypeWholeBlood, NextType: models.DonationTypeWholeBlood, Gender: models.GenderFemale, IntervalDays: 180},
+		{PreviousType: models.DonationTypeWholeBlood, NextType: models.DonationTypePlasma, IntervalDays: 30},
+		{PreviousType: models.DonationTypeWholeBlood, NextType: models.DonationTypePlatelets, IntervalDays: 30},
+	}
+	for _, previous := range []models.DonationType{models.DonationTypePlasma, models.DonationTypePlatelets} {
+		for _, next := range models.DonationTypes {
+			intervals = append(intervals, models.DonationInterval{PreviousType: previous, NextType: next, IntervalDays: 14})
+		}
+	}
+	return intervals
+}
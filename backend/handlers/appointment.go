@@ -3,8 +3,10 @@ package handlers
 import (
 	"bloodone/database"
 	"bloodone/models"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,24 +26,25 @@ func GetAppointments(c *gin.Context) {
 		ProposedDate3 time.Time                `json:"proposed_date_3"`
 		ConfirmedDate *time.Time               `json:"confirmed_date"`
 		Status        models.AppointmentStatus `json:"status"`
+		DonationType  models.DonationType      `json:"donation_type"`
 		User          *models.User             `json:"user"`
 	}
 
+	appointments, err := database.DB.ListAppointments()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load appointments"})
+		return
+	}
+
 	var result []AppointmentWithUser
-	for _, a := range database.DB.Appointments {
+	for _, a := range appointments {
 		// Filtro per status se specificato
 		if status != "" && string(a.Status) != status {
 			continue
 		}
 
 		// Trova l'utente
-		var user *models.User
-		for i := range database.DB.Users {
-			if database.DB.Users[i].ID == a.DonorID {
-				user = &database.DB.Users[i]
-				break
-			}
-		}
+		user, _ := database.DB.GetUser(a.DonorID)
 
 		result = append(result, AppointmentWithUser{
 			ID:            a.ID,
@@ -53,6 +56,7 @@ func GetAppointments(c *gin.Context) {
 			ProposedDate3: a.ProposedDate3,
 			ConfirmedDate: a.ConfirmedDate,
 			Status:        a.Status,
+			DonationType:  a.DonationType.OrDefault(),
 			User:          user,
 		})
 	}
@@ -62,13 +66,12 @@ func GetAppointments(c *gin.Context) {
 
 func GetAppointment(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	for _, a := range database.DB.Appointments {
-		if a.ID == uint(id) {
-			c.JSON(http.StatusOK, a)
-			return
-		}
+	appointment, err := database.DB.GetAppointment(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	c.JSON(http.StatusOK, appointment)
 }
 
 func CreateAppointment(c *gin.Context) {
@@ -77,126 +80,502 @@ func CreateAppointment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	appointment.ID = database.DB.NextAppointmentID()
+	appointment.ID = 0
 	appointment.CreatedAt = time.Now()
 	appointment.Status = models.AppointmentStatusPending
-	database.DB.Appointments = append(database.DB.Appointments, appointment)
-	database.DB.Save()
+	if err := database.DB.CreateAppointment(&appointment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create appointment"})
+		return
+	}
 	c.JSON(http.StatusCreated, appointment)
 }
 
 func ProposeAppointmentDates(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
 	var req struct {
 		DonorID       uint   `json:"donor_id"`
 		ProposedDate1 string `json:"proposed_date_1"`
 		ProposedDate2 string `json:"proposed_date_2"`
 		ProposedDate3 string `json:"proposed_date_3"`
+		// Tipo di donazione proposto (default: whole_blood)
+		DonationType string `json:"donation_type"`
+		// Permette date chiuse, escluse o già piene
+		OverrideCapacity bool `json:"override_capacity"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	donationType, err := parseDonationType(req.DonationType)
+	if err != nil {
+		respondError(c, err, "Invalid donation type")
+		return
+	}
 
-	// Parse le date
-	var date1, date2, date3 time.Time
-	var err error
-
-	if req.ProposedDate1 != "" {
-		date1, err = time.Parse("2006-01-02", req.ProposedDate1)
+	// Parse le date (quelle vuote vengono scelte automaticamente)
+	var dates [3]*time.Time
+	for i, value := range []string{req.ProposedDate1, req.ProposedDate2, req.ProposedDate3} {
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", value)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato data 1 non valido"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Formato data %d non valido", i+1)})
 			return
 		}
-	} else {
-		date1 = time.Now().AddDate(0, 0, 7)
+		dates[i] = &date
+	}
+
+	var appointment *models.Appointment
+	err = database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		appointment, err = createProposal(tx, req.DonorID, donationType, dates, req.OverrideCapacity, adminID.(uint))
+		return err
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create appointment")
+		return
 	}
+	c.JSON(http.StatusCreated, appointment)
+}
 
-	if req.ProposedDate2 != "" {
-		date2, err = time.Parse("2006-01-02", req.ProposedDate2)
+func ConfirmAppointment(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+	var req struct {
+		SelectedDate time.Time `json:"selected_date"`
+		// Orario di arrivo ("07:45"): se omesso viene assegnato il primo slot libero
+		Slot string `json:"slot"`
+		// Solo admin: permette una data (o un orario) chiusa, esclusa o già piena
+		OverrideCapacity bool `json:"override_capacity"`
+		// Solo admin: permette una data diversa da quelle proposte
+		AllowOtherDate bool `json:"allow_other_date"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.SelectedDate.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "selected_date obbligatoria"})
+		return
+	}
+
+	var appointment *models.Appointment
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		appointment, err = tx.GetAppointment(uint(id))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato data 2 non valido"})
-			return
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		before := *appointment
+
+		// Solo il donatore dell'appuntamento (o un admin) può confermare
+		if !isAdmin.(bool) && appointment.DonorID != userID.(uint) {
+			return newAPIError(http.StatusForbidden, "Puoi confermare solo i tuoi appuntamenti")
+		}
+		if appointment.Status != models.AppointmentStatusPending {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("Solo un appuntamento in attesa può essere confermato (stato attuale: %s)", appointment.Status))
+		}
+		// Una proposta scaduta che il job non ha ancora chiuso non è più confermabile dal donatore
+		if !isAdmin.(bool) && proposalExpiry(*appointment, time.Now()) != "" {
+			return newAPIError(http.StatusConflict, "La proposta è scaduta, attendi nuove date")
+		}
+
+		// La data deve essere una di quelle proposte, salvo scelta diversa di un admin
+		if !isProposedDate(appointment, req.SelectedDate) {
+			if !(req.AllowOtherDate && isAdmin.(bool)) {
+				return newAPIError(http.StatusBadRequest, "La data scelta non è tra quelle proposte")
+			}
+			adminID := userID.(uint)
+			appointment.AdminModified = true
+			appointment.ModifiedBy = &adminID
+		}
+
+		// Verifica calendario e capacità del giorno scelto
+		cal, err := loadCapacityCalendar(tx, appointment.ID)
+		if err != nil {
+			return err
+		}
+		override := req.OverrideCapacity && isAdmin.(bool)
+		forced, err := cal.checkBookable(req.SelectedDate, override)
+		if err != nil {
+			return err
+		}
+		slot, forcedSlot, err := cal.chooseSlot(req.SelectedDate, req.Slot, override)
+		if err != nil {
+			return err
 		}
-	} else {
-		date2 = time.Now().AddDate(0, 0, 14)
+		if forced || forcedSlot {
+			recordOverride(appointment, userID.(uint))
+		}
+
+		// Il donatore deve essere idoneo nel giorno scelto, anche se la
+		// proposta era valida quando è stata fatta
+		user, err := tx.GetUser(appointment.DonorID)
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "User not found")
+		}
+		record, err := loadDonorRecord(tx, *user)
+		if err != nil {
+			return err
+		}
+		if err := record.requireEligible(appointment.DonationType.OrDefault(), req.SelectedDate); err != nil {
+			return err
+		}
+
+		appointment.ConfirmedDate = &req.SelectedDate
+		appointment.ConfirmedSlot = slot
+		appointment.Status = models.AppointmentStatusConfirmed
+		if err := tx.UpdateAppointment(appointment); err != nil {
+			return err
+		}
+		if err := logAppointmentEvent(tx, before, appointment, userID.(uint), models.AppointmentActionConfirmed, ""); err != nil {
+			return err
+		}
+		if err := notifyConfirmation(tx, appointment); err != nil {
+			return err
+		}
+
+		// Aggiorna anche next_appointment_date dell'utente
+		user.NextAppointmentDate = &req.SelectedDate
+		user.UpdatedAt = time.Now()
+		return tx.UpdateUser(user)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to confirm appointment")
+		return
 	}
 
-	if req.ProposedDate3 != "" {
-		date3, err = time.Parse("2006-01-02", req.ProposedDate3)
+	c.JSON(http.StatusOK, appointment)
+}
+
+// isProposedDate indica se date cade in uno dei giorni proposti nell'appuntamento
+func isProposedDate(appointment *models.Appointment, date time.Time) bool {
+	day := date.Format("2006-01-02")
+	for _, proposed := range []time.Time{appointment.ProposedDate1, appointment.ProposedDate2, appointment.ProposedDate3} {
+		if !proposed.IsZero() && proposed.Format("2006-01-02") == day {
+			return true
+		}
+	}
+	return false
+}
+
+func CancelAppointment(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	adminID, _ := c.Get("user_id")
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// Il motivo è facoltativo: il body può mancare
+	_ = c.ShouldBindJSON(&req)
+
+	err := database.DB.WithTx(func(tx database.Store) error {
+		appointment, err := tx.GetAppointment(uint(id))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato data 3 non valido"})
-			return
+			return newAPIError(http.StatusNotFound, "Appuntamento non trovato")
 		}
-	} else {
-		date3 = time.Now().AddDate(0, 0, 21)
+
+		return cancelAppointment(tx, appointment, adminID.(uint), req.Reason, false)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to cancel appointment")
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Appuntamento annullato"})
+}
 
-	// Verifica che il donatore non abbia già un appuntamento pending o confirmed
-	for _, a := range database.DB.Appointments {
-		if a.DonorID == req.DonorID && (a.Status == models.AppointmentStatusPending || a.Status == models.AppointmentStatusConfirmed) {
-			c.JSON(http.StatusConflict, gin.H{"error": "Il donatore ha già un appuntamento attivo"})
-			return
+// findMyChangeableAppointment carica un appuntamento del donatore che può
+// ancora essere annullato o spostato: pending, oppure confermato e non
+// entro il limite di preavviso (APPOINTMENT_CHANGE_CUTOFF_HOURS)
+func findMyChangeableAppointment(tx database.Store, id, donorID uint) (*models.Appointment, error) {
+	appointment, err := tx.GetAppointment(id)
+	if err != nil || appointment.DonorID != donorID {
+		return nil, newAPIError(http.StatusNotFound, "Appuntamento non trovato")
+	}
+	switch appointment.Status {
+	case models.AppointmentStatusPending:
+		return appointment, nil
+	case models.AppointmentStatusConfirmed:
+		if appointment.ConfirmedDate != nil {
+			start := appointmentStart(*appointment.ConfirmedDate, appointment.ConfirmedSlot)
+			if time.Until(start) < changeCutoff() {
+				return nil, newAPIError(http.StatusConflict, fmt.Sprintf("Non è possibile modificare l'appuntamento a meno di %d ore dall'inizio: contatta il centro", int(changeCutoff().Hours())))
+			}
 		}
+		return appointment, nil
+	default:
+		return nil, newAPIError(http.StatusConflict, fmt.Sprintf("L'appuntamento non può essere modificato (stato attuale: %s)", appointment.Status))
 	}
+}
 
-	appointment := models.Appointment{
-		ID:            database.DB.NextAppointmentID(),
-		DonorID:       req.DonorID,
-		ProposedDate1: date1,
-		ProposedDate2: date2,
-		ProposedDate3: date3,
-		Status:        models.AppointmentStatusPending,
-		CreatedAt:     time.Now(),
+// CancelMyAppointment - Annullamento di un appuntamento da parte del donatore
+func CancelMyAppointment(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, _ := c.Get("user_id")
+	var req struct {
+		Reason string `json:"reason"`
 	}
-	database.DB.Appointments = append(database.DB.Appointments, appointment)
-	database.DB.Save()
-	c.JSON(http.StatusCreated, appointment)
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Indica il motivo dell'annullamento"})
+		return
+	}
+
+	var appointment *models.Appointment
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		appointment, err = findMyChangeableAppointment(tx, uint(id), userID.(uint))
+		if err != nil {
+			return err
+		}
+		return cancelAppointment(tx, appointment, userID.(uint), strings.TrimSpace(req.Reason), false)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to cancel appointment")
+		return
+	}
+	c.JSON(http.StatusOK, appointment)
 }
 
-func ConfirmAppointment(c *gin.Context) {
+// RescheduleMyAppointment - Il donatore sposta un appuntamento confermato su
+// un altro giorno disponibile, oppure chiede all'admin nuove date
+func RescheduleMyAppointment(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, _ := c.Get("user_id")
 	var req struct {
-		SelectedDate time.Time `json:"selected_date"`
+		NewDate string `json:"new_date"` // 2006-01-02
+		Slot    string `json:"slot"`
+		// In alternativa a new_date: annulla e chiede all'admin nuove proposte
+		RequestNewProposals bool   `json:"request_new_proposals"`
+		Reason              string `json:"reason"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	for i, a := range database.DB.Appointments {
-		if a.ID == uint(id) {
-			database.DB.Appointments[i].ConfirmedDate = &req.SelectedDate
-			database.DB.Appointments[i].Status = models.AppointmentStatusConfirmed
+	var newDate time.Time
+	if !req.RequestNewProposals {
+		var err error
+		newDate, err = time.Parse("2006-01-02", req.NewDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Indica new_date (2006-01-02) oppure request_new_proposals"})
+			return
+		}
+	}
 
-			// Aggiorna anche next_appointment_date dell'utente
-			for j := range database.DB.Users {
-				if database.DB.Users[j].ID == a.DonorID {
-					database.DB.Users[j].NextAppointmentDate = &req.SelectedDate
-					database.DB.Users[j].UpdatedAt = time.Now()
-					break
-				}
-			}
+	var appointment *models.Appointment
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		appointment, err = findMyChangeableAppointment(tx, uint(id), userID.(uint))
+		if err != nil {
+			return err
+		}
 
-			database.DB.Save()
-			c.JSON(http.StatusOK, database.DB.Appointments[i])
-			return
+		if req.RequestNewProposals {
+			return cancelAppointment(tx, appointment, userID.(uint), strings.TrimSpace(req.Reason), true)
+		}
+
+		if appointment.Status != models.AppointmentStatusConfirmed {
+			return newAPIError(http.StatusConflict, "Conferma una delle date proposte oppure chiedi nuove date")
+		}
+
+		// La nuova data deve rispettare preavviso, idoneità e calendario
+		if time.Until(appointmentStart(newDate, req.Slot)) < changeCutoff() {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("La nuova data deve essere ad almeno %d ore da adesso", int(changeCutoff().Hours())))
 		}
+		user, err := tx.GetUser(appointment.DonorID)
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "User not found")
+		}
+		record, err := loadDonorRecord(tx, *user)
+		if err != nil {
+			return err
+		}
+		if err := record.requireEligible(appointment.DonationType.OrDefault(), newDate); err != nil {
+			return err
+		}
+		cal, err := loadCapacityCalendar(tx, appointment.ID)
+		if err != nil {
+			return err
+		}
+		if err := cal.check(newDate); err != nil {
+			return err
+		}
+		slot, _, err := cal.chooseSlot(newDate, req.Slot, false)
+		if err != nil {
+			return err
+		}
+
+		before := *appointment
+		appointment.ConfirmedDate = &newDate
+		appointment.ConfirmedSlot = slot
+		appointment.RemindersSent = nil
+		appointment.UpdatedAt = time.Now()
+		if err := tx.UpdateAppointment(appointment); err != nil {
+			return err
+		}
+		user.NextAppointmentDate = &newDate
+		user.UpdatedAt = time.Now()
+		if err := tx.UpdateUser(user); err != nil {
+			return err
+		}
+		return logAppointmentEvent(tx, before, appointment, userID.(uint), models.AppointmentActionRescheduled, strings.TrimSpace(req.Reason))
+	})
+	if err != nil {
+		respondError(c, err, "Failed to reschedule appointment")
+		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	c.JSON(http.StatusOK, appointment)
 }
 
-func CancelAppointment(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+// Esiti possibili di un appuntamento confermato
+const (
+	outcomeDonated  = "donated"
+	outcomeDeferred = "deferred"
+)
 
-	for i, a := range database.DB.Appointments {
-		if a.ID == uint(id) {
-			database.DB.Appointments[i].Status = models.AppointmentStatusCancelled
-			database.DB.Save()
-			c.JSON(http.StatusOK, gin.H{"message": "Appuntamento annullato"})
+// CompleteAppointment - Registra l'esito di un appuntamento confermato (Admin):
+// la donazione collegata all'appuntamento, oppure la sospensione decisa il
+// giorno stesso. In entrambi i casi il donatore non ha più un appuntamento
+// fissato e la risposta riporta la nuova scadenza.
+func CompleteAppointment(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	adminID, _ := c.Get("user_id")
+	var req struct {
+		Outcome      string `json:"outcome"`       // "donated" (default) o "deferred"
+		DonationDate string `json:"donation_date"` // default: data confermata
+		Notes        string `json:"notes"`
+		// Solo per outcome "deferred"
+		Reason         string `json:"reason"`
+		ReasonID       *uint  `json:"reason_id"` // voce del catalogo, con la sua durata di default
+		DurationMonths int    `json:"duration_months"`
+		DurationWeeks  int    `json:"duration_weeks"`
+		DurationDays   int    `json:"duration_days"`
+		Permanent      bool   `json:"permanent"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Outcome == "" {
+		req.Outcome = outcomeDonated
+	}
+	if req.Outcome != outcomeDonated && req.Outcome != outcomeDeferred {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "outcome: valori ammessi donated, deferred"})
+		return
+	}
+	if req.Outcome == outcomeDeferred && strings.TrimSpace(req.Reason) == "" && req.ReasonID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Per una sospensione indica reason_id oppure reason e la durata"})
+		return
+	}
+	var date time.Time
+	if req.DonationDate != "" {
+		parsed, err := time.Parse("2006-01-02", req.DonationDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato data non valido"})
 			return
 		}
+		date = parsed
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "Appuntamento non trovato"})
+
+	var appointment *models.Appointment
+	var donation *models.Donation
+	var suspension *models.Suspension
+	var userResp models.UserResponse
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		appointment, err = tx.GetAppointment(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Appuntamento non trovato")
+		}
+		if appointment.Status != models.AppointmentStatusConfirmed {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("Solo un appuntamento confermato può essere completato (stato attuale: %s)", appointment.Status))
+		}
+		before := *appointment
+		if date.IsZero() {
+			if appointment.ConfirmedDate != nil {
+				date = *appointment.ConfirmedDate
+			} else {
+				date = dateOnly(time.Now())
+			}
+		}
+
+		action := models.AppointmentActionCompleted
+		if req.Outcome == outcomeDonated {
+			donation = &models.Donation{
+				CreatedAt:     time.Now(),
+				UpdatedAt:     time.Now(),
+				DonorID:       appointment.DonorID,
+				AppointmentID: &appointment.ID,
+				DonationDate:  date,
+				DonationType:  appointment.DonationType.OrDefault(),
+				Status:        models.DonationStatusCompleted,
+				Notes:         req.Notes,
+			}
+			if err := tx.CreateDonation(donation); err != nil {
+				return err
+			}
+			appointment.Status = models.AppointmentStatusCompleted
+			appointment.DonationID = &donation.ID
+		} else {
+			suspension = &models.Suspension{
+				DonorID:        appointment.DonorID,
+				StartDate:      date,
+				DurationMonths: req.DurationMonths,
+				DurationWeeks:  req.DurationWeeks,
+				DurationDays:   req.DurationDays,
+				Permanent:      req.Permanent,
+				Reason:         strings.TrimSpace(req.Reason),
+				ReasonID:       req.ReasonID,
+				CreatedBy:      adminID.(uint),
+			}
+			if err := createSuspension(tx, suspension); err != nil {
+				return err
+			}
+			appointment.Status = models.AppointmentStatusDeferred
+			appointment.SuspensionID = &suspension.ID
+			action = models.AppointmentActionDeferred
+		}
+
+		if req.Notes != "" {
+			appointment.Notes = req.Notes
+		}
+		appointment.UpdatedAt = time.Now()
+		if err := tx.UpdateAppointment(appointment); err != nil {
+			return err
+		}
+		if err := logAppointmentEvent(tx, before, appointment, adminID.(uint), action, req.Notes); err != nil {
+			return err
+		}
+
+		// Il donatore non ha più un appuntamento fissato
+		user, err := tx.GetUser(appointment.DonorID)
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "User not found")
+		}
+		user.NextAppointmentDate = nil
+		user.UpdatedAt = time.Now()
+		if err := tx.UpdateUser(user); err != nil {
+			return err
+		}
+
+		// Nuova scadenza calcolata dalla donazione (o dalla sospensione) appena registrata
+		userResp = buildUserResponse(tx, *user)
+		return nil
+	})
+	if err != nil {
+		respondError(c, err, "Failed to complete appointment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"appointment":   appointment,
+		"donation":      donation,
+		"suspension":    suspension,
+		"next_due_date": userResp.NextDueDate,
+		"user":          userResp,
+	})
 }
 
 func UpdateAppointment(c *gin.Context) {
@@ -209,11 +588,21 @@ func DeleteAppointment(c *gin.Context) {
 
 func GetDonorAppointments(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	var appointments []models.Appointment
-	for _, a := range database.DB.Appointments {
-		if a.DonorID == uint(id) {
-			appointments = append(appointments, a)
-		}
+	appointments, err := database.DB.ListAppointmentsByDonor(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load appointments"})
+		return
+	}
+	c.JSON(http.StatusOK, appointments)
+}
+
+// GetMyAppointments - Appuntamenti dell'utente corrente
+func GetMyAppointments(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	appointments, err := database.DB.ListAppointmentsByDonor(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load appointments"})
+		return
 	}
 	c.JSON(http.StatusOK, appointments)
 }
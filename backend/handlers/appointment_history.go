@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ore prima dell'appuntamento entro cui il donatore non può più annullarlo o spostarlo
+const defaultChangeCutoffHours = 24
+
+func changeCutoff() time.Duration {
+	return time.Duration(envInt("APPOINTMENT_CHANGE_CUTOFF_HOURS", defaultChangeCutoffHours)) * time.Hour
+}
+
+// appointmentStart - Inizio dell'appuntamento confermato nell'ora locale del
+// centro: la data confermata all'orario dello slot, o a inizio giornata
+func appointmentStart(date time.Time, slot string) time.Time {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	if t, err := time.Parse("15:04", slot); err == nil {
+		start = start.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	}
+	return start
+}
+
+// logAppointmentEvent registra nello storico il passaggio da before ad after
+func logAppointmentEvent(tx database.Store, before models.Appointment, after *models.Appointment, changedBy uint, action, note string) error {
+	event := models.AppointmentEvent{
+		CreatedAt:     time.Now(),
+		AppointmentID: after.ID,
+		ChangedBy:     changedBy,
+		Action:        action,
+		FromStatus:    before.Status,
+		ToStatus:      after.Status,
+		FromDate:      before.ConfirmedDate,
+		ToDate:        after.ConfirmedDate,
+		FromSlot:      before.ConfirmedSlot,
+		ToSlot:        after.ConfirmedSlot,
+		Note:          note,
+	}
+	return tx.CreateAppointmentEvent(&event)
+}
+
+// cancelAppointment annulla l'appuntamento, libera la data del donatore, lo
+// registra nello storico e ne avvisa il donatore. Solo gli appuntamenti
+// pending o confermati si possono annullare: gli altri hanno già un esito (409)
+func cancelAppointment(tx database.Store, appointment *models.Appointment, changedBy uint, reason string, rescheduleRequested bool) error {
+	switch appointment.Status {
+	case models.AppointmentStatusPending, models.AppointmentStatusConfirmed:
+	default:
+		return newAPIError(http.StatusConflict, fmt.Sprintf("L'appuntamento non può essere annullato (stato attuale: %s)", appointment.Status))
+	}
+
+	before := *appointment
+	appointment.Status = models.AppointmentStatusCancelled
+	appointment.CancelReason = reason
+	appointment.CancelledBy = &changedBy
+	appointment.RescheduleRequested = rescheduleRequested
+	appointment.UpdatedAt = time.Now()
+	if err := tx.UpdateAppointment(appointment); err != nil {
+		return err
+	}
+
+	if before.Status == models.AppointmentStatusConfirmed {
+		if user, err := tx.GetUser(appointment.DonorID); err == nil && user.NextAppointmentDate != nil {
+			user.NextAppointmentDate = nil
+			user.UpdatedAt = time.Now()
+			if err := tx.UpdateUser(user); err != nil {
+				return err
+			}
+		}
+	}
+
+	action := models.AppointmentActionCancelled
+	if rescheduleRequested {
+		action = models.AppointmentActionRescheduleRequested
+	}
+	if err := logAppointmentEvent(tx, before, appointment, changedBy, action, reason); err != nil {
+		return err
+	}
+	return notifyCancellation(tx, appointment)
+}
+
+// GetAppointmentHistory - Storico delle modifiche di un appuntamento (Admin)
+func GetAppointmentHistory(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if _, err := database.DB.GetAppointment(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	events, err := database.DB.ListAppointmentEventsByAppointment(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load appointment history"})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// GetMyAppointmentHistory - Storico di un appuntamento dell'utente corrente
+func GetMyAppointmentHistory(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, _ := c.Get("user_id")
+	appointment, err := database.DB.GetAppointment(uint(id))
+	if err != nil || appointment.DonorID != userID.(uint) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appuntamento non trovato"})
+		return
+	}
+	events, err := database.DB.ListAppointmentEventsByAppointment(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load appointment history"})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bloodone/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCancelAppointment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	date := dateOnly(time.Now()).AddDate(0, 0, -3)
+
+	tests := []struct {
+		status     models.AppointmentStatus
+		wantStatus int
+	}{
+		{models.AppointmentStatusPending, http.StatusOK},
+		{models.AppointmentStatusConfirmed, http.StatusOK},
+		{models.AppointmentStatusCompleted, http.StatusConflict},
+		{models.AppointmentStatusDeferred, http.StatusConflict},
+		{models.AppointmentStatusNoShow, http.StatusConflict},
+		{models.AppointmentStatusExpired, http.StatusConflict},
+		{models.AppointmentStatusCancelled, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			store := useTestStore(t)
+			donor := createTestDonor(t, store, models.NotificationChannelEmail, "")
+			appointment := models.Appointment{
+				DonorID:       donor.ID,
+				ProposedDate1: date,
+				Status:        tt.status,
+				DonationType:  models.DonationTypeWholeBlood,
+			}
+			if tt.status != models.AppointmentStatusPending {
+				appointment.ConfirmedDate = &date
+			}
+			if err := store.CreateAppointment(&appointment); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":"Centro chiuso"}`))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(appointment.ID))}}
+			c.Set("user_id", uint(1))
+
+			CancelAppointment(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, tt.wantStatus)
+			}
+
+			stored, err := store.GetAppointment(appointment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events, _ := store.ListAppointmentEventsByAppointment(appointment.ID)
+			notifications, _ := store.ListNotifications()
+			if tt.wantStatus == http.StatusOK {
+				if stored.Status != models.AppointmentStatusCancelled || len(events) != 1 || len(notifications) != 1 {
+					t.Errorf("annullato: stato %s, eventi %d, notifiche %d", stored.Status, len(events), len(notifications))
+				}
+				return
+			}
+			// Un appuntamento con un esito resta com'è, senza avvisare il donatore
+			if stored.Status != tt.status || stored.CancelReason != "" || len(events) != 0 || len(notifications) != 0 {
+				t.Errorf("modificato: stato %s, motivo %q, eventi %d, notifiche %d",
+					stored.Status, stored.CancelReason, len(events), len(notifications))
+			}
+		})
+	}
+}
@@ -113,79 +113,84 @@ func GoogleCallback(c *gin.Context) {
 	found := false
 
 	// Cerca per GoogleID
-	for i := range database.DB.Users {
-		if database.DB.Users[i].GoogleID == userInfo.ID {
-			user = &database.DB.Users[i]
-			found = true
-			break
-		}
+	if u, err := database.DB.FindUserByGoogleID(userInfo.ID); err == nil {
+		user = u
+		found = true
 	}
 
-	// Se non trovato, cerca per email
+	// Se non trovato, cerca per email (o crea il primo utente come amministratore)
 	if !found {
-		for i := range database.DB.Users {
-			if database.DB.Users[i].Email == userInfo.Email {
-				user = &database.DB.Users[i]
+		err := database.DB.WithTx(func(tx database.Store) error {
+			if u, err := tx.FindUserByEmail(userInfo.Email); err == nil {
+				user = u
 				user.GoogleID = userInfo.ID
-				database.DB.Save()
 				found = true
-				break
+				return tx.UpdateUser(user)
 			}
-		}
-	}
 
-	if !found {
-		// Se è il primo utente, crealo come amministratore
-		if len(database.DB.Users) == 0 {
+			// Se è il primo utente, crealo come amministratore
+			users, err := tx.ListUsers()
+			if err != nil || len(users) > 0 {
+				return err
+			}
 			newUser := models.User{
-				ID:        database.DB.NextUserID(),
 				Email:     userInfo.Email,
 				GoogleID:  userInfo.ID,
 				FirstName: userInfo.GivenName,
 				LastName:  userInfo.FamilyName,
 				IsAdmin:   true,
+				IsActive:  true,
 			}
-			database.DB.Users = append(database.DB.Users, newUser)
-			database.DB.Save()
-			user = &database.DB.Users[len(database.DB.Users)-1]
-			found = true
-		} else {
-			// Verifica se esiste già una richiesta pendente
-			var pendingRequest *models.RegistrationRequest
-			for i := range database.DB.RegistrationRequests {
-				if database.DB.RegistrationRequests[i].Email == userInfo.Email &&
-					database.DB.RegistrationRequests[i].Status == models.RegistrationRequestStatusPending {
-					pendingRequest = &database.DB.RegistrationRequests[i]
-					break
-				}
+			if err := tx.CreateUser(&newUser); err != nil {
+				return err
 			}
+			user = &newUser
+			found = true
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save user"})
+			return
+		}
+	}
 
-			// Utente non registrato - redirect a pagina appropriata
-			firstName := userInfo.GivenName
-			lastName := userInfo.FamilyName
-
-			// Se GivenName è vuoto, usa Name (per account business/aziendali)
-			if firstName == "" && userInfo.Name != "" {
-				firstName = userInfo.Name
+	if !found {
+		// Verifica se esiste già una richiesta pendente
+		var pendingRequest *models.RegistrationRequest
+		requests, _ := database.DB.ListRegistrationRequests()
+		for i := range requests {
+			if requests[i].Email == userInfo.Email &&
+				requests[i].Status == models.RegistrationRequestStatusPending {
+				pendingRequest = &requests[i]
+				break
 			}
+		}
 
-			params := url.Values{}
-			params.Add("email", userInfo.Email)
-			params.Add("google_id", userInfo.ID)
-			params.Add("first_name", firstName)
-			params.Add("last_name", lastName)
-			params.Add("name", userInfo.Name)
-
-			if pendingRequest != nil {
-				// Ha già una richiesta pendente
-				params.Add("pending", "true")
-				params.Add("request_date", pendingRequest.CreatedAt.Format("2006-01-02T15:04:05"))
-			}
+		// Utente non registrato - redirect a pagina appropriata
+		firstName := userInfo.GivenName
+		lastName := userInfo.FamilyName
 
-			frontendURL := frontendBaseURL + "/not-registered?" + params.Encode()
-			c.Redirect(http.StatusFound, frontendURL)
-			return
+		// Se GivenName è vuoto, usa Name (per account business/aziendali)
+		if firstName == "" && userInfo.Name != "" {
+			firstName = userInfo.Name
+		}
+
+		params := url.Values{}
+		params.Add("email", userInfo.Email)
+		params.Add("google_id", userInfo.ID)
+		params.Add("first_name", firstName)
+		params.Add("last_name", lastName)
+		params.Add("name", userInfo.Name)
+
+		if pendingRequest != nil {
+			// Ha già una richiesta pendente
+			params.Add("pending", "true")
+			params.Add("request_date", pendingRequest.CreatedAt.Format("2006-01-02T15:04:05"))
 		}
+
+		frontendURL := frontendBaseURL + "/not-registered?" + params.Encode()
+		c.Redirect(http.StatusFound, frontendURL)
+		return
 	}
 
 	// Genera JWT
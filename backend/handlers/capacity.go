@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var weekdayNames = [...]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"}
+
+// dayAvailability - Disponibilità di un singolo giorno
+type dayAvailability struct {
+	Date      string `json:"date"`
+	Open      bool   `json:"open"`
+	Capacity  int    `json:"capacity"`
+	Booked    int    `json:"booked"`
+	Remaining int    `json:"remaining"`
+	Proposed  int    `json:"proposed"` // proposte pending che includono il giorno
+	Reason    string `json:"reason,omitempty"`
+
+	Slots []slotAvailability `json:"slots,omitempty"`
+}
+
+// slotAvailability - Disponibilità di uno slot orario
+type slotAvailability struct {
+	Time      string `json:"time"`
+	Capacity  int    `json:"capacity"`
+	Booked    int    `json:"booked"`
+	Remaining int    `json:"remaining"`
+}
+
+// capacityCalendar - Schedule, date escluse, capacità speciali e prenotazioni
+// caricati una sola volta per valutare più giorni
+type capacityCalendar struct {
+	schedule *models.DonationSchedule
+	excluded map[string]string // data -> motivo
+	special  map[string]int
+	booked   map[string]int
+	proposed map[string]int
+
+	rules      map[time.Weekday]models.TimeSlotRule
+	slotBooked map[string]int // "data orario" -> prenotati
+}
+
+// loadCapacityCalendar carica il calendario. L'appuntamento ignoreID non
+// viene conteggiato (serve quando si sposta un appuntamento già confermato).
+func loadCapacityCalendar(tx database.Store, ignoreID uint) (*capacityCalendar, error) {
+	schedule, err := tx.GetSchedule()
+	if err != nil {
+		return nil, err
+	}
+	cal := &capacityCalendar{
+		schedule: schedule,
+		excluded: map[string]string{},
+		special:  map[string]int{},
+		booked:   map[string]int{},
+		proposed: map[string]int{},
+
+		rules:      map[time.Weekday]models.TimeSlotRule{},
+		slotBooked: map[string]int{},
+	}
+
+	excluded, err := tx.ListExcludedDates()
+	if err != nil {
+		return nil, err
+	}
+	for _, ed := range excluded {
+		cal.excluded[ed.Date.Format("2006-01-02")] = ed.Reason
+	}
+
+	capacities, err := tx.ListSpecialCapacities()
+	if err != nil {
+		return nil, err
+	}
+	for _, sc := range capacities {
+		cal.special[sc.Date.Format("2006-01-02")] = sc.Capacity
+	}
+
+	rules, err := tx.ListTimeSlotRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		cal.rules[time.Weekday(r.Weekday)] = r
+	}
+
+	appointments, err := tx.ListAppointments()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range appointments {
+		if a.ID == ignoreID {
+			continue
+		}
+		switch a.Status {
+		case models.AppointmentStatusConfirmed, models.AppointmentStatusCompleted, models.AppointmentStatusDeferred:
+			if a.ConfirmedDate != nil {
+				cal.booked[a.ConfirmedDate.Format("2006-01-02")]++
+				if a.ConfirmedSlot != "" {
+					cal.slotBooked[slotKey(*a.ConfirmedDate, a.ConfirmedSlot)]++
+				}
+			}
+		case models.AppointmentStatusPending:
+			// Le proposte non occupano posto finché il donatore non conferma
+			for _, date := range []time.Time{a.ProposedDate1, a.ProposedDate2, a.ProposedDate3} {
+				if !date.IsZero() {
+					cal.proposed[date.Format("2006-01-02")]++
+				}
+			}
+		}
+	}
+	return cal, nil
+}
+
+// day calcola la disponibilità di date. Una capacità speciale ha la
+// precedenza sul giorno della settimana (e apre anche un giorno chiuso),
+// una data esclusa chiude sempre il giorno.
+func (cal *capacityCalendar) day(date time.Time) dayAvailability {
+	key := date.Format("2006-01-02")
+	d := dayAvailability{
+		Date:     key,
+		Booked:   cal.booked[key],
+		Proposed: cal.proposed[key],
+	}
+
+	if reason, ok := cal.excluded[key]; ok {
+		d.Reason = "Data esclusa"
+		if reason != "" {
+			d.Reason += ": " + reason
+		}
+	} else if capacity, ok := cal.special[key]; ok {
+		d.Open = capacity > 0
+		d.Capacity = capacity
+		if !d.Open {
+			d.Reason = "Giornata chiusa (capacità speciale 0)"
+		}
+	} else if cal.schedule.IsOpen(date.Weekday()) {
+		d.Open = true
+		d.Capacity = cal.schedule.CapacityFor(date.Weekday())
+	} else {
+		d.Reason = fmt.Sprintf("Nessuna donazione di %s", weekdayNames[date.Weekday()])
+	}
+
+	if d.Open {
+		d.Remaining = d.Capacity - d.Booked
+		// Con le fasce orarie non si va oltre i posti liberi negli slot
+		if slots := cal.slots(date); slots != nil {
+			free := 0
+			for _, slot := range slots {
+				free += slot.Remaining
+			}
+			if free < d.Remaining {
+				d.Remaining = free
+			}
+		}
+		if d.Remaining < 0 {
+			d.Remaining = 0
+		}
+	}
+	return d
+}
+
+func slotKey(date time.Time, slot string) string {
+	return date.Format("2006-01-02") + " " + slot
+}
+
+// slots restituisce gli slot orari di date, nil se il giorno non ha fasce orarie
+func (cal *capacityCalendar) slots(date time.Time) []slotAvailability {
+	rule, ok := cal.rules[date.Weekday()]
+	if !ok {
+		return nil
+	}
+	slots := []slotAvailability{}
+	for _, t := range rule.Slots() {
+		s := slotAvailability{
+			Time:     t,
+			Capacity: rule.SlotCapacity,
+			Booked:   cal.slotBooked[slotKey(date, t)],
+		}
+		s.Remaining = s.Capacity - s.Booked
+		if s.Remaining < 0 {
+			s.Remaining = 0
+		}
+		slots = append(slots, s)
+	}
+	return slots
+}
+
+// hasSlots indica se il giorno di date è organizzato in fasce orarie
+func (cal *capacityCalendar) hasSlots(date time.Time) bool {
+	_, ok := cal.rules[date.Weekday()]
+	return ok
+}
+
+// checkSlot restituisce un errore se l'orario slot non esiste in date o è pieno
+func (cal *capacityCalendar) checkSlot(date time.Time, slot string) error {
+	for _, s := range cal.slots(date) {
+		if s.Time != slot {
+			continue
+		}
+		if s.Remaining <= 0 {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("Orario %s del %s già al completo", slot, date.Format("2006-01-02")))
+		}
+		return nil
+	}
+	return newAPIError(http.StatusBadRequest, fmt.Sprintf("Orario %s non previsto il %s", slot, date.Format("2006-01-02")))
+}
+
+// firstFreeSlot restituisce il primo slot con posti liberi in date ("" se nessuno)
+func (cal *capacityCalendar) firstFreeSlot(date time.Time) string {
+	for _, s := range cal.slots(date) {
+		if s.Remaining > 0 {
+			return s.Time
+		}
+	}
+	return ""
+}
+
+// chooseSlot sceglie l'orario per una prenotazione in date: quello richiesto
+// (verificato, salvo override) oppure il primo libero. Restituisce "" se il
+// giorno non ha fasce orarie; il bool indica se è stato forzato un orario pieno.
+func (cal *capacityCalendar) chooseSlot(date time.Time, requested string, override bool) (string, bool, error) {
+	if !cal.hasSlots(date) {
+		if requested != "" {
+			return "", false, newAPIError(http.StatusBadRequest, fmt.Sprintf("Nessuna fascia oraria configurata il %s", date.Format("2006-01-02")))
+		}
+		return "", false, nil
+	}
+	if requested == "" {
+		return cal.firstFreeSlot(date), false, nil
+	}
+	if err := cal.checkSlot(date, requested); err != nil {
+		var apiErr *apiError
+		if override && errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict {
+			return requested, true, nil
+		}
+		return "", false, err
+	}
+	return requested, false, nil
+}
+
+// check restituisce un errore 409 se in date non si può prenotare
+func (cal *capacityCalendar) check(date time.Time) error {
+	d := cal.day(date)
+	switch {
+	case !d.Open && d.Reason != "":
+		return newAPIError(http.StatusConflict, fmt.Sprintf("%s non disponibile. %s", d.Date, d.Reason))
+	case d.Remaining <= 0:
+		return newAPIError(http.StatusConflict, fmt.Sprintf("Capacità esaurita per il %s (%d/%d prenotati)", d.Date, d.Booked, d.Capacity))
+	}
+	return nil
+}
+
+// checkBookable è come check, ma con override (solo admin) i limiti vengono
+// ignorati: il risultato indica se è stato necessario forzare la prenotazione
+func (cal *capacityCalendar) checkBookable(date time.Time, override bool) (bool, error) {
+	if err := cal.check(date); err != nil {
+		if override {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// nextAvailable restituisce il primo giorno prenotabile da from in poi (entro un anno)
+func (cal *capacityCalendar) nextAvailable(from time.Time) (time.Time, bool) {
+	for i := 0; i < 366; i++ {
+		date := from.AddDate(0, 0, i)
+		if cal.check(date) == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// recordOverride annota sull'appuntamento la forzatura dei limiti da parte di un admin
+func recordOverride(appointment *models.Appointment, adminID uint) {
+	appointment.CapacityOverride = true
+	appointment.CapacityOverrideBy = &adminID
+}
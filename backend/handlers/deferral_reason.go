@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateDeferralReason normalizza e controlla una voce del catalogo
+func validateDeferralReason(tx database.Store, reason *models.DeferralReason) error {
+	reason.Code = strings.ToLower(strings.TrimSpace(reason.Code))
+	reason.Name = strings.TrimSpace(reason.Name)
+	reason.Category = strings.ToLower(strings.TrimSpace(reason.Category))
+	if reason.Code == "" || reason.Name == "" {
+		return newAPIError(http.StatusBadRequest, "code e name sono obbligatori")
+	}
+	if reason.Category == "" {
+		reason.Category = models.DeferralCategoryOther
+	}
+	validCategory := false
+	for _, category := range models.DeferralCategories {
+		validCategory = validCategory || category == reason.Category
+	}
+	if !validCategory {
+		return newAPIError(http.StatusBadRequest, "category: valori ammessi "+strings.Join(models.DeferralCategories, ", "))
+	}
+	if reason.DefaultDurationMonths < 0 || reason.DefaultDurationWeeks < 0 || reason.DefaultDurationDays < 0 {
+		return newAPIError(http.StatusBadRequest, "La durata non può essere negativa")
+	}
+	if reason.Permanent {
+		reason.DefaultDurationMonths, reason.DefaultDurationWeeks, reason.DefaultDurationDays = 0, 0, 0
+	} else if reason.DefaultDurationMonths == 0 && reason.DefaultDurationWeeks == 0 && reason.DefaultDurationDays == 0 {
+		return newAPIError(http.StatusBadRequest, "Indica una durata di default oppure permanent")
+	}
+
+	reasons, err := tx.ListDeferralReasons()
+	if err != nil {
+		return err
+	}
+	for _, r := range reasons {
+		if r.Code == reason.Code && r.ID != reason.ID {
+			return newAPIError(http.StatusConflict, "Esiste già un motivo con questo code")
+		}
+	}
+	return nil
+}
+
+// GetDeferralReasons - Catalogo dei motivi di sospensione (?all=true include i disattivati)
+func GetDeferralReasons(c *gin.Context) {
+	reasons, err := database.DB.ListDeferralReasons()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load deferral reasons"})
+		return
+	}
+
+	all := c.Query("all") == "true"
+	result := []models.DeferralReason{}
+	for _, r := range reasons {
+		if all || r.IsActive {
+			result = append(result, r)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateDeferralReason - Aggiunge un motivo al catalogo (Admin)
+func CreateDeferralReason(c *gin.Context) {
+	var reason models.DeferralReason
+	if err := c.ShouldBindJSON(&reason); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reason.ID = 0
+	reason.IsActive = true
+	reason.CreatedAt = time.Now()
+	reason.UpdatedAt = time.Now()
+
+	err := database.DB.WithTx(func(tx database.Store) error {
+		if err := validateDeferralReason(tx, &reason); err != nil {
+			return err
+		}
+		return tx.CreateDeferralReason(&reason)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create deferral reason")
+		return
+	}
+	c.JSON(http.StatusCreated, reason)
+}
+
+// UpdateDeferralReason - Modifica un motivo; le sospensioni già create non cambiano (Admin)
+func UpdateDeferralReason(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var reason *models.DeferralReason
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		reason, err = tx.GetDeferralReason(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		createdAt := reason.CreatedAt
+		if err := c.ShouldBindJSON(reason); err != nil {
+			return newAPIError(http.StatusBadRequest, err.Error())
+		}
+		reason.ID = uint(id)
+		reason.CreatedAt = createdAt
+		reason.UpdatedAt = time.Now()
+		if err := validateDeferralReason(tx, reason); err != nil {
+			return err
+		}
+		return tx.UpdateDeferralReason(reason)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to update deferral reason")
+		return
+	}
+	c.JSON(http.StatusOK, reason)
+}
+
+// DeleteDeferralReason - Disattiva un motivo: non è più selezionabile ma
+// resta collegato alle sospensioni che lo usano (Admin)
+func DeleteDeferralReason(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var reason *models.DeferralReason
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		reason, err = tx.GetDeferralReason(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		reason.IsActive = false
+		reason.UpdatedAt = time.Now()
+		return tx.UpdateDeferralReason(reason)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to delete deferral reason")
+		return
+	}
+	c.JSON(http.StatusOK, reason)
+}
+
+// GetDeferralReport - Sospensioni per motivo, categoria e mese, con inizio
+// nel periodo ?from&to (default: ultimi 12 mesi). Le sospensioni annullate
+// e quelle ancora da revisionare sono escluse; quelle senza voce del
+// catalogo sono raggruppate per testo.
+func GetDeferralReport(c *gin.Context) {
+	to := dateOnly(time.Now())
+	from := to.AddDate(-1, 0, 0)
+	if v := c.Query("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato from non valido"})
+			return
+		}
+		from = parsed
+	}
+	if v := c.Query("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato to non valido"})
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to deve essere successiva a from"})
+		return
+	}
+
+	suspensions, err := database.DB.ListSuspensions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load suspensions"})
+		return
+	}
+	reasons, err := database.DB.ListDeferralReasons()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load deferral reasons"})
+		return
+	}
+	catalogue := map[uint]models.DeferralReason{}
+	for _, r := range reasons {
+		catalogue[r.ID] = r
+	}
+
+	type reasonStats struct {
+		ReasonID  *uint  `json:"reason_id,omitempty"`
+		Code      string `json:"code,omitempty"`
+		Name      string `json:"name"`
+		Category  string `json:"category"`
+		Count     int    `json:"count"`
+		Active    int    `json:"active"`
+		Permanent int    `json:"permanent"`
+		// Durata media in giorni delle sospensioni temporanee
+		AverageDays float64 `json:"average_days"`
+		totalDays   int
+		temporary   int
+	}
+
+	now := time.Now()
+	total, permanent, active := 0, 0, 0
+	byKey := map[string]*reasonStats{}
+	byCategory := map[string]int{}
+	byMonth := map[string]int{}
+	for _, s := range suspensions {
+		start := dateOnly(s.StartDate)
+		if s.CancelledAt != nil || s.PendingReview || start.Before(from) || start.After(to) {
+			continue
+		}
+
+		key := "text:" + strings.ToLower(s.Reason)
+		stats := &reasonStats{Name: s.Reason, Category: models.DeferralCategoryOther}
+		if s.ReasonID != nil {
+			key = "id:" + strconv.FormatUint(uint64(*s.ReasonID), 10)
+			if r, ok := catalogue[*s.ReasonID]; ok {
+				stats = &reasonStats{ReasonID: s.ReasonID, Code: r.Code, Name: r.Name, Category: r.Category}
+			}
+		}
+		if existing, ok := byKey[key]; ok {
+			stats = existing
+		} else {
+			byKey[key] = stats
+		}
+
+		stats.Count++
+		total++
+		byCategory[stats.Category]++
+		byMonth[start.Format("2006-01")]++
+		if s.InEffect(now) {
+			stats.Active++
+			active++
+		}
+		if s.Permanent {
+			stats.Permanent++
+			permanent++
+		} else {
+			stats.temporary++
+			stats.totalDays += int(s.EndDate.Sub(s.StartDate).Hours() / 24)
+		}
+	}
+
+	byReason := []reasonStats{}
+	for _, stats := range byKey {
+		if stats.temporary > 0 {
+			stats.AverageDays = float64(stats.totalDays) / float64(stats.temporary)
+		}
+		byReason = append(byReason, *stats)
+	}
+	sort.Slice(byReason, func(i, j int) bool {
+		if byReason[i].Count != byReason[j].Count {
+			return byReason[i].Count > byReason[j].Count
+		}
+		return byReason[i].Name < byReason[j].Name
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":        from.Format("2006-01-02"),
+		"to":          to.Format("2006-01-02"),
+		"total":       total,
+		"active":      active,
+		"permanent":   permanent,
+		"by_reason":   byReason,
+		"by_category": byCategory,
+		"by_month":    byMonth,
+	})
+}
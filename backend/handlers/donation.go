@@ -11,18 +11,22 @@ import (
 )
 
 func GetDonations(c *gin.Context) {
-	c.JSON(http.StatusOK, database.DB.Donations)
+	donations, err := database.DB.ListDonations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load donations"})
+		return
+	}
+	c.JSON(http.StatusOK, donations)
 }
 
 func GetDonation(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	for _, d := range database.DB.Donations {
-		if d.ID == uint(id) {
-			c.JSON(http.StatusOK, d)
-			return
-		}
+	donation, err := database.DB.GetDonation(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	c.JSON(http.StatusOK, donation)
 }
 
 func CreateDonation(c *gin.Context) {
@@ -31,11 +35,18 @@ func CreateDonation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	donation.ID = database.DB.NextDonationID()
+	donation.ID = 0
 	donation.CreatedAt = time.Now()
 	donation.Status = models.DonationStatusCompleted
-	database.DB.Donations = append(database.DB.Donations, donation)
-	database.DB.Save()
+	donation.DonationType = donation.DonationType.OrDefault()
+	if !donation.DonationType.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "donation_type: valori ammessi whole_blood, plasma, platelets"})
+		return
+	}
+	if err := database.DB.CreateDonation(&donation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create donation"})
+		return
+	}
 	c.JSON(http.StatusCreated, donation)
 }
 
@@ -49,11 +60,10 @@ func DeleteDonation(c *gin.Context) {
 
 func GetDonorHistory(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	var donations []models.Donation
-	for _, d := range database.DB.Donations {
-		if d.DonorID == uint(id) {
-			donations = append(donations, d)
-		}
+	donations, err := database.DB.ListDonationsByDonor(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load donations"})
+		return
 	}
 	c.JSON(http.StatusOK, donations)
 }
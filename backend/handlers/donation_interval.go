@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// intervalMatrix - Intervalli minimi tra tipi di donazione, per sesso
+type intervalMatrix []models.DonationInterval
+
+// days restituisce l'intervallo tra una donazione previous e una next per il
+// sesso indicato: la riga specifica per sesso vince su quella generica
+func (m intervalMatrix) days(previous, next models.DonationType, gender models.Gender) (int, bool) {
+	found, days := false, 0
+	for _, interval := range m {
+		if interval.PreviousType != previous || interval.NextType != next {
+			continue
+		}
+		if interval.Gender == gender {
+			return interval.IntervalDays, true
+		}
+		if interval.Gender == "" {
+			found, days = true, interval.IntervalDays
+		}
+	}
+	return days, found
+}
+
+// parseDonationType legge un tipo di donazione, sangue intero se vuoto
+func parseDonationType(value string) (models.DonationType, error) {
+	t := models.DonationType(value).OrDefault()
+	if !t.Valid() {
+		return "", newAPIError(http.StatusBadRequest, "donation_type: valori ammessi whole_blood, plasma, platelets")
+	}
+	return t, nil
+}
+
+// GetDonationIntervals - Matrice degli intervalli minimi tra donazioni (Admin)
+func GetDonationIntervals(c *gin.Context) {
+	intervals, err := database.DB.ListDonationIntervals()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load donation intervals"})
+		return
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		a, b := intervals[i], intervals[j]
+		if a.PreviousType != b.PreviousType {
+			return a.PreviousType < b.PreviousType
+		}
+		if a.NextType != b.NextType {
+			return a.NextType < b.NextType
+		}
+		return a.Gender < b.Gender
+	})
+	c.JSON(http.StatusOK, intervals)
+}
+
+// SetDonationInterval - Crea o sostituisce l'intervallo per tipo precedente,
+// tipo successivo e sesso (vuoto = tutti) (Admin)
+func SetDonationInterval(c *gin.Context) {
+	var interval models.DonationInterval
+	if err := c.ShouldBindJSON(&interval); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !interval.PreviousType.Valid() || !interval.NextType.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "previous_type e next_type: valori ammessi whole_blood, plasma, platelets"})
+		return
+	}
+	if interval.Gender != "" && interval.Gender != models.GenderMale && interval.Gender != models.GenderFemale {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "gender: valori ammessi M, F o vuoto per tutti"})
+		return
+	}
+	if interval.IntervalDays <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval_days deve essere positivo"})
+		return
+	}
+
+	err := database.DB.WithTx(func(tx database.Store) error {
+		intervals, err := tx.ListDonationIntervals()
+		if err != nil {
+			return err
+		}
+		interval.UpdatedAt = time.Now()
+		for _, existing := range intervals {
+			if existing.PreviousType == interval.PreviousType && existing.NextType == interval.NextType && existing.Gender == interval.Gender {
+				interval.ID = existing.ID
+				interval.CreatedAt = existing.CreatedAt
+				return tx.UpdateDonationInterval(&interval)
+			}
+		}
+		interval.ID = 0
+		interval.CreatedAt = interval.UpdatedAt
+		return tx.CreateDonationInterval(&interval)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to save donation interval")
+		return
+	}
+	c.JSON(http.StatusOK, interval)
+}
+
+// DeleteDonationInterval - Rimuove una riga della matrice (Admin)
+func DeleteDonationInterval(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := database.DB.DeleteDonationInterval(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
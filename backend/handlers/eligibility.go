@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limiti annuali (12 mesi mobili) di default, come da normativa: 4 donazioni
+// di sangue intero per gli uomini e per le donne non in età fertile, 2 per
+// le donne in età fertile, 6 donazioni in tutto sommando sangue intero e aferesi
+const (
+	defaultMaxWholeBloodPerYearMale             = 4
+	defaultMaxWholeBloodPerYearFemale           = 2
+	defaultMaxWholeBloodPerYearFemaleNonFertile = 4
+	defaultMaxDonationsPerYear                  = 6
+)
+
+// Età oltre la quale una donatrice non è più considerata in età fertile; se
+// la data di nascita non è nota vale il limite più prudente
+const childBearingAgeLimit = 50
+
+// Età per donare di default: dai 18 anni, prima donazione entro i 65, poi
+// fino a 65 anni o fino a 70 con idoneità medica (age_clearance). Il
+// donatore viene segnalato nei 12 mesi prima del limite.
+const (
+	defaultMinDonorAge              = 18
+	defaultMaxFirstDonationAge      = 65
+	defaultMaxDonorAge              = 65
+	defaultMaxDonorAgeWithClearance = 70
+	defaultAgeLimitWarningMonths    = 12
+)
+
+// Regole di idoneità, riportate nelle risposte come "rule"
+const (
+	eligibilityRulePermanent  = "permanent_deferral"
+	eligibilityRuleSuspension = "suspension"
+	eligibilityRuleInterval   = "min_interval"
+	eligibilityRuleYearlyCap  = "yearly_cap"
+	eligibilityRuleMinAge     = "min_age"
+	eligibilityRuleMaxAge     = "max_age"
+)
+
+// eligibility - Esito della valutazione per un tipo di donazione a una data
+type eligibility struct {
+	DonationType models.DonationType `json:"donation_type"`
+	Date         time.Time           `json:"date"`
+	Eligible     bool                `json:"eligible"`
+	// Prima data utile da Date in poi; null se il donatore non potrà più donare
+	EarliestDate *time.Time `json:"earliest_date"`
+	// Regola che determina EarliestDate (o l'esclusione) e spiegazione
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// message - Testo dell'errore per una data non idonea
+func (e eligibility) message() string {
+	if e.EarliestDate == nil {
+		return "Il donatore non è idoneo: " + e.Reason
+	}
+	return fmt.Sprintf("Il donatore non è idoneo il %s: %s (prima data utile %s)",
+		e.Date.Format("2006-01-02"), e.Reason, e.EarliestDate.Format("2006-01-02"))
+}
+
+// apiError - 409 con la regola, il motivo e la prima data utile, così il
+// client può spiegare il rifiuto senza interpretare il messaggio
+func (e eligibility) apiError(message string) error {
+	return &apiError{
+		Status:  http.StatusConflict,
+		Message: message,
+		Details: gin.H{
+			"rule":          e.Rule,
+			"reason":        e.Reason,
+			"donation_type": e.DonationType,
+			"earliest_date": e.EarliestDate,
+		},
+	}
+}
+
+// donorRecord - Dati del donatore su cui si valutano le regole di idoneità
+type donorRecord struct {
+	user       models.User
+	donations  []models.Donation // solo completate, dalla più vecchia
+	intervals  intervalMatrix
+	suspension suspensionStatus
+}
+
+// loadDonorRecord legge donazioni, matrice degli intervalli e sospensioni
+// del donatore da store (usare tx dentro una transazione)
+func loadDonorRecord(store database.Store, user models.User) (*donorRecord, error) {
+	donations, err := store.ListDonationsByDonor(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	intervals, err := store.ListDonationIntervals()
+	if err != nil {
+		return nil, err
+	}
+	suspensions, err := store.ListSuspensionsByDonor(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &donorRecord{
+		user:       user,
+		intervals:  intervals,
+		suspension: donorSuspensionStatus(suspensions, time.Now()),
+	}
+	for _, donation := range donations {
+		if donation.Status == models.DonationStatusCompleted {
+			record.donations = append(record.donations, donation)
+		}
+	}
+	sort.Slice(record.donations, func(i, j int) bool {
+		return record.donations[i].DonationDate.Before(record.donations[j].DonationDate)
+	})
+	return record, nil
+}
+
+// ageOn - Età compiuta alla data indicata
+func ageOn(birthDate, date time.Time) int {
+	age := date.Year() - birthDate.Year()
+	if date.Month() < birthDate.Month() || (date.Month() == birthDate.Month() && date.Day() < birthDate.Day()) {
+		age--
+	}
+	return age
+}
+
+// eligibilityRule - Una regola restituisce la prima data, non precedente a
+// from, in cui consente la donazione e il motivo se la sposta; ok=false se
+// non la consentirà più
+type eligibilityRule struct {
+	name  string
+	check func(r *donorRecord, donationType models.DonationType, from time.Time) (date time.Time, reason string, ok bool)
+}
+
+var eligibilityRules = []eligibilityRule{
+	{eligibilityRulePermanent, checkPermanentDeferral},
+	{eligibilityRuleSuspension, checkSuspension},
+	{eligibilityRuleInterval, checkMinInterval},
+	{eligibilityRuleYearlyCap, checkYearlyCaps},
+	{eligibilityRuleMinAge, checkMinAge},
+	{eligibilityRuleMaxAge, checkMaxAge},
+}
+
+// checkPermanentDeferral - Un'esclusione definitiva blocca ogni data dal suo
+// inizio in poi, anche se inserita con una data di inizio futura
+func checkPermanentDeferral(r *donorRecord, _ models.DonationType, from time.Time) (time.Time, string, bool) {
+	if start := r.suspension.PermanentFrom; start != nil && !from.Before(dateOnly(*start)) {
+		if r.suspension.Permanent {
+			return time.Time{}, "Il donatore è escluso in modo permanente", false
+		}
+		return time.Time{}, "Il donatore è escluso in modo permanente dal " + start.Format("2006-01-02"), false
+	}
+	return from, "", true
+}
+
+func checkSuspension(r *donorRecord, _ models.DonationType, from time.Time) (time.Time, string, bool) {
+	if r.suspension.Until != nil && dateOnly(*r.suspension.Until).After(from) {
+		until := dateOnly(*r.suspension.Until)
+		return until, "Sospeso fino al " + until.Format("2006-01-02"), true
+	}
+	return from, "", true
+}
+
+// checkMinInterval - Ogni donazione impone l'intervallo della matrice verso
+// il tipo richiesto, o quello generico del donatore se manca la riga: così
+// dopo un'aferesi vale ancora l'intervallo dall'ultimo sangue intero
+func checkMinInterval(r *donorRecord, donationType models.DonationType, from time.Time) (time.Time, string, bool) {
+	date, reason := from, ""
+	for _, donation := range r.donations {
+		previous := donation.DonationType.OrDefault()
+		var due time.Time
+		if days, ok := r.intervals.days(previous, donationType, r.user.Gender); ok {
+			due = dateOnly(donation.DonationDate).AddDate(0, 0, days)
+		} else {
+			due = dateOnly(donation.DonationDate).AddDate(0, r.user.GetDonationInterval(), 0)
+		}
+		if due.After(date) {
+			date = due
+			reason = fmt.Sprintf("Intervallo minimo dalla donazione (%s) del %s", previous, donation.DonationDate.Format("2006-01-02"))
+		}
+	}
+	return date, reason, true
+}
+
+// maxWholeBloodPerYear - Limite annuale di sangue intero per il donatore alla
+// data indicata. Le donne non più in età fertile hanno un limite proprio, di
+// default uguale a quello degli uomini ma configurabile a parte.
+func maxWholeBloodPerYear(user models.User, on time.Time) int {
+	if user.Gender != models.GenderFemale {
+		return envInt("MAX_WHOLE_BLOOD_PER_YEAR_MALE", defaultMaxWholeBloodPerYearMale)
+	}
+	if user.BirthDate != nil && ageOn(*user.BirthDate, on) >= childBearingAgeLimit {
+		return envInt("MAX_WHOLE_BLOOD_PER_YEAR_FEMALE_NON_FERTILE", defaultMaxWholeBloodPerYearFemaleNonFertile)
+	}
+	return envInt("MAX_WHOLE_BLOOD_PER_YEAR_FEMALE", defaultMaxWholeBloodPerYearFemale)
+}
+
+// checkYearlyCaps - Nei 12 mesi che terminano con la donazione ci devono
+// essere meno donazioni del limite; se sono già al limite la data si sposta
+// a un anno dalla donazione che deve uscire dalla finestra
+func checkYearlyCaps(r *donorRecord, donationType models.DonationType, from time.Time) (time.Time, string, bool) {
+	type yearlyCap struct {
+		max     int
+		counts  func(models.DonationType) bool
+		subject string
+	}
+	caps := []yearlyCap{{
+		max:     envInt("MAX_DONATIONS_PER_YEAR", defaultMaxDonationsPerYear),
+		counts:  func(models.DonationType) bool { return true },
+		subject: "donazioni",
+	}}
+	if donationType == models.DonationTypeWholeBlood {
+		caps = append(caps, yearlyCap{
+			max:     maxWholeBloodPerYear(r.user, from),
+			counts:  func(t models.DonationType) bool { return t == models.DonationTypeWholeBlood },
+			subject: "donazioni di sangue intero",
+		})
+	}
+
+	date, reason := from, ""
+	windowStart := from.AddDate(-1, 0, 0)
+	for _, limit := range caps {
+		if limit.max == 0 {
+			return time.Time{}, fmt.Sprintf("Limite annuale di %s pari a zero", limit.subject), false
+		}
+		var window []time.Time
+		for _, donation := range r.donations {
+			donated := dateOnly(donation.DonationDate)
+			if limit.counts(donation.DonationType.OrDefault()) && donated.After(windowStart) && !donated.After(from) {
+				window = append(window, donated)
+			}
+		}
+		if len(window) < limit.max {
+			continue
+		}
+		if due := window[len(window)-limit.max].AddDate(1, 0, 0); due.After(date) {
+			date = due
+			reason = fmt.Sprintf("Raggiunto il limite di %d %s in 12 mesi", limit.max, limit.subject)
+		}
+	}
+	return date, reason, true
+}
+
+func checkMinAge(r *donorRecord, _ models.DonationType, from time.Time) (time.Time, string, bool) {
+	if r.user.BirthDate == nil {
+		return from, "", true
+	}
+	minAge := envInt("MIN_DONOR_AGE", defaultMinDonorAge)
+	if adult := dateOnly(*r.user.BirthDate).AddDate(minAge, 0, 0); adult.After(from) {
+		return adult, fmt.Sprintf("Età minima %d anni", minAge), true
+	}
+	return from, "", true
+}
+
+// maxAge - Età massima del donatore (compresa) e descrizione del limite:
+// per la prima donazione, ordinaria o estesa con idoneità medica
+func (r *donorRecord) maxAge() (int, string) {
+	switch {
+	case len(r.donations) == 0:
+		age := envInt("MAX_FIRST_DONATION_AGE", defaultMaxFirstDonationAge)
+		return age, fmt.Sprintf("l'età massima di %d anni per la prima donazione", age)
+	case r.user.AgeClearance:
+		age := envInt("MAX_DONOR_AGE_WITH_CLEARANCE", defaultMaxDonorAgeWithClearance)
+		return age, fmt.Sprintf("l'età massima di %d anni con idoneità medica", age)
+	default:
+		age := envInt("MAX_DONOR_AGE", defaultMaxDonorAge)
+		return age, fmt.Sprintf("l'età massima di %d anni senza idoneità medica", age)
+	}
+}
+
+// ageLimitDate - Primo giorno in cui il donatore supera l'età massima; nil se la data di nascita non è nota
+func (r *donorRecord) ageLimitDate() *time.Time {
+	if r.user.BirthDate == nil {
+		return nil
+	}
+	age, _ := r.maxAge()
+	limit := dateOnly(*r.user.BirthDate).AddDate(age+1, 0, 0)
+	return &limit
+}
+
+func checkMaxAge(r *donorRecord, _ models.DonationType, from time.Time) (time.Time, string, bool) {
+	if limit := r.ageLimitDate(); limit != nil && !from.Before(*limit) {
+		_, description := r.maxAge()
+		return time.Time{}, "Superata " + description, false
+	}
+	return from, "", true
+}
+
+// approachingAgeLimit indica se il donatore supererà l'età massima entro
+// AGE_LIMIT_WARNING_MONTHS mesi da oggi (e non l'ha ancora superata)
+func (r *donorRecord) approachingAgeLimit(now time.Time) bool {
+	limit := r.ageLimitDate()
+	if limit == nil {
+		return false
+	}
+	today := dateOnly(now)
+	warningFrom := limit.AddDate(0, -envInt("AGE_LIMIT_WARNING_MONTHS", defaultAgeLimitWarningMonths), 0)
+	return !today.Before(warningFrom) && today.Before(*limit)
+}
+
+// evaluate applica le regole finché nessuna sposta più la data: il
+// risultato è la prima data utile da from in poi e la regola che per ultima
+// l'ha spostata
+func (r *donorRecord) evaluate(donationType models.DonationType, from time.Time) eligibility {
+	result := eligibility{DonationType: donationType, Date: from}
+	candidate := from
+	// Le regole spostano la data solo in avanti: poche passate bastano
+	for pass := 0; pass < 10; pass++ {
+		moved := false
+		for _, rule := range eligibilityRules {
+			date, reason, ok := rule.check(r, donationType, candidate)
+			if !ok {
+				result.Eligible, result.EarliestDate = false, nil
+				result.Rule, result.Reason = rule.name, reason
+				return result
+			}
+			if date.After(candidate) {
+				candidate = date
+				result.Rule, result.Reason = rule.name, reason
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	result.EarliestDate = &candidate
+	result.Eligible = !candidate.After(from)
+	return result
+}
+
+// nextDue - Scadenza per il tipo di donazione indipendentemente da oggi
+// (può essere passata); nil se il donatore non ha vincoli o non potrà più donare.
+// Chi non ha mai donato e non è sospeso non ha scadenza: altrimenti l'età
+// minima lo farebbe risultare scaduto dal compimento dei 18 anni.
+func (r *donorRecord) nextDue(donationType models.DonationType) *time.Time {
+	if len(r.donations) == 0 && r.suspension.Until == nil && r.suspension.PermanentFrom == nil {
+		return nil
+	}
+	result := r.evaluate(donationType, time.Time{})
+	if result.EarliestDate == nil || result.EarliestDate.IsZero() {
+		return nil
+	}
+	if today := dateOnly(time.Now()); result.EarliestDate.Before(today) && r.evaluate(donationType, today).EarliestDate == nil {
+		return nil
+	}
+	return result.EarliestDate
+}
+
+// earliestProposalDate - Primo giorno proponibile per il tipo di donazione:
+// da domani, non prima della data in cui tutte le regole di idoneità sono
+// soddisfatte. Un donatore che non potrà più donare non è proponibile (409).
+func (r *donorRecord) earliestProposalDate(donationType models.DonationType) (time.Time, error) {
+	result := r.evaluate(donationType, dateOnly(time.Now()).AddDate(0, 0, 1))
+	if result.EarliestDate == nil {
+		return time.Time{}, result.apiError(result.Reason)
+	}
+	return *result.EarliestDate, nil
+}
+
+// requireEligible - 409 se il donatore non può donare quel tipo alla data indicata
+func (r *donorRecord) requireEligible(donationType models.DonationType, date time.Time) error {
+	if result := r.evaluate(donationType, dateOnly(date)); !result.Eligible {
+		return result.apiError(result.message())
+	}
+	return nil
+}
+
+// eligibilityReport - Idoneità del donatore per ogni tipo (o solo quello
+// indicato da ?donation_type) alla data ?date, default oggi
+func eligibilityReport(c *gin.Context, user models.User) {
+	date := dateOnly(time.Now())
+	if v := c.Query("date"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato date non valido"})
+			return
+		}
+		date = parsed
+	}
+	types := models.DonationTypes
+	if v := c.Query("donation_type"); v != "" {
+		donationType, err := parseDonationType(v)
+		if err != nil {
+			respondError(c, err, "Failed to evaluate eligibility")
+			return
+		}
+		types = []models.DonationType{donationType}
+	}
+
+	record, err := loadDonorRecord(database.DB, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate eligibility"})
+		return
+	}
+	results := []eligibility{}
+	for _, donationType := range types {
+		results = append(results, record.evaluate(donationType, date))
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// GetDonorEligibility - Idoneità di un donatore (Admin)
+func GetDonorEligibility(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	user, err := database.DB.GetUser(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	eligibilityReport(c, *user)
+}
+
+// GetMyEligibility - Idoneità del donatore autenticato
+func GetMyEligibility(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	user, err := database.DB.GetUser(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	eligibilityReport(c, *user)
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"bloodone/models"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Giovedì 1 ottobre 2026: la data da cui si valutano le regole
+var eligibilityBase = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+// Matrice degli intervalli come quella predefinita, senza la riga sangue
+// intero → piastrine per provare l'intervallo generico del donatore
+var testIntervals = intervalMatrix{
+	{PreviousType: models.DonationTypeWholeBlood, NextType: models.DonationTypeWholeBlood, Gender: models.GenderMale, IntervalDays: 90},
+	{PreviousType: models.DonationTypeWholeBlood, NextType: models.DonationTypeWholeBlood, Gender: models.GenderFemale, IntervalDays: 180},
+	{PreviousType: models.DonationTypeWholeBlood, NextType: models.DonationTypePlasma, IntervalDays: 30},
+	{PreviousType: models.DonationTypePlasma, NextType: models.DonationTypePlasma, IntervalDays: 14},
+	{PreviousType: models.DonationTypePlasma, NextType: models.DonationTypeWholeBlood, IntervalDays: 14},
+}
+
+// daysFromBase - Data a days giorni da eligibilityBase (negativo: nel passato)
+func daysFromBase(days int) time.Time {
+	return eligibilityBase.AddDate(0, 0, days)
+}
+
+// completed - Donazioni completate del tipo indicato, days giorni da eligibilityBase
+func completed(donationType models.DonationType, days ...int) []models.Donation {
+	var donations []models.Donation
+	for _, d := range days {
+		donations = append(donations, models.Donation{
+			DonationDate: daysFromBase(d),
+			DonationType: donationType,
+			Status:       models.DonationStatusCompleted,
+		})
+	}
+	return donations
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// birthDate - Nascita years anni prima di eligibilityBase, spostata di days giorni
+func birthDate(years, days int) *time.Time {
+	date := eligibilityBase.AddDate(-years, 0, days)
+	return &date
+}
+
+func TestEvaluate(t *testing.T) {
+	male := models.User{Gender: models.GenderMale, BirthDate: birthDate(40, 0)}
+	female := models.User{Gender: models.GenderFemale, BirthDate: birthDate(36, 0)}
+	over50 := models.User{Gender: models.GenderFemale, BirthDate: birthDate(56, 0)}
+	cleared := models.User{Gender: models.GenderMale, BirthDate: birthDate(66, 0), AgeClearance: true}
+	until := daysFromBase(20)
+	untilSoon := daysFromBase(10)
+
+	tests := []struct {
+		name         string
+		user         models.User
+		donations    []models.Donation
+		suspension   suspensionStatus
+		env          map[string]string
+		donationType models.DonationType
+		want         *time.Time // prima data utile, nil se non potrà più donare
+		rule         string
+	}{
+		{name: "nessun vincolo", user: male, donationType: models.DonationTypeWholeBlood,
+			want: &eligibilityBase},
+
+		// min_interval
+		{name: "intervallo uomo", user: male, donationType: models.DonationTypeWholeBlood,
+			donations: completed(models.DonationTypeWholeBlood, -30),
+			want:      timePtr(daysFromBase(60)), rule: eligibilityRuleInterval},
+		{name: "intervallo donna", user: female, donationType: models.DonationTypeWholeBlood,
+			donations: completed(models.DonationTypeWholeBlood, -30),
+			want:      timePtr(daysFromBase(150)), rule: eligibilityRuleInterval},
+		{name: "intervallo già trascorso", user: male, donationType: models.DonationTypeWholeBlood,
+			donations: completed(models.DonationTypeWholeBlood, -90),
+			want:      &eligibilityBase},
+		{name: "tipo non indicato vale sangue intero", user: male, donationType: models.DonationTypeWholeBlood,
+			donations: completed("", -30),
+			want:      timePtr(daysFromBase(60)), rule: eligibilityRuleInterval},
+		{name: "riga mancante: intervallo generico in mesi", user: male, donationType: models.DonationTypePlatelets,
+			donations: completed(models.DonationTypeWholeBlood, -30),
+			want:      timePtr(daysFromBase(-30).AddDate(0, 3, 0)), rule: eligibilityRuleInterval},
+		{name: "dopo un'aferesi vale ancora l'ultimo sangue intero", user: male, donationType: models.DonationTypeWholeBlood,
+			donations: append(completed(models.DonationTypeWholeBlood, -60), completed(models.DonationTypePlasma, -5)...),
+			want:      timePtr(daysFromBase(30)), rule: eligibilityRuleInterval},
+
+		// yearly_cap
+		{name: "4 sangue intero in 12 mesi (uomo)", user: male, donationType: models.DonationTypeWholeBlood,
+			donations: completed(models.DonationTypeWholeBlood, -330, -240, -150, -60),
+			want:      timePtr(daysFromBase(-330).AddDate(1, 0, 0)), rule: eligibilityRuleYearlyCap},
+		{name: "2 sangue intero in 12 mesi (donna in età fertile)", user: female, donationType: models.DonationTypeWholeBlood,
+			donations: completed(models.DonationTypeWholeBlood, -300, -120),
+			want:      timePtr(daysFromBase(-300).AddDate(1, 0, 0)), rule: eligibilityRuleYearlyCap},
+		{name: "donna dai 50 anni: limite per età non fertile", user: over50, donationType: models.DonationTypeWholeBlood,
+			donations: completed(models.DonationTypeWholeBlood, -300, -120),
+			want:      timePtr(daysFromBase(60)), rule: eligibilityRuleInterval},
+		{name: "donna dai 50 anni: il limite maschile non vale", user: over50, donationType: models.DonationTypeWholeBlood,
+			env:       map[string]string{"MAX_WHOLE_BLOOD_PER_YEAR_MALE": "1"},
+			donations: completed(models.DonationTypeWholeBlood, -300, -120),
+			want:      timePtr(daysFromBase(60)), rule: eligibilityRuleInterval},
+		{name: "donna dai 50 anni: limite configurato", user: over50, donationType: models.DonationTypeWholeBlood,
+			env:       map[string]string{"MAX_WHOLE_BLOOD_PER_YEAR_FEMALE_NON_FERTILE": "2"},
+			donations: completed(models.DonationTypeWholeBlood, -300, -120),
+			want:      timePtr(daysFromBase(-300).AddDate(1, 0, 0)), rule: eligibilityRuleYearlyCap},
+		{name: "donna di età non nota: limite per età fertile", user: models.User{Gender: models.GenderFemale},
+			donationType: models.DonationTypeWholeBlood,
+			donations:    completed(models.DonationTypeWholeBlood, -300, -120),
+			want:         timePtr(daysFromBase(-300).AddDate(1, 0, 0)), rule: eligibilityRuleYearlyCap},
+		{name: "il limite di sangue intero non vale per l'aferesi", user: female, donationType: models.DonationTypePlasma,
+			donations: completed(models.DonationTypeWholeBlood, -300, -120),
+			want:      &eligibilityBase},
+		{name: "6 donazioni in 12 mesi", user: male, donationType: models.DonationTypePlasma,
+			donations: completed(models.DonationTypePlasma, -84, -70, -56, -42, -28, -14),
+			want:      timePtr(daysFromBase(-84).AddDate(1, 0, 0)), rule: eligibilityRuleYearlyCap},
+		{name: "limite annuale configurato", user: male, donationType: models.DonationTypePlasma,
+			env:       map[string]string{"MAX_DONATIONS_PER_YEAR": "2"},
+			donations: completed(models.DonationTypePlasma, -100, -50),
+			want:      timePtr(daysFromBase(-100).AddDate(1, 0, 0)), rule: eligibilityRuleYearlyCap},
+		{name: "limite annuale zero", user: male, donationType: models.DonationTypePlasma,
+			env:  map[string]string{"MAX_DONATIONS_PER_YEAR": "0"},
+			rule: eligibilityRuleYearlyCap},
+
+		// min_age e max_age
+		{name: "minorenne", user: models.User{Gender: models.GenderMale, BirthDate: birthDate(17, 0)},
+			donationType: models.DonationTypeWholeBlood,
+			want:         timePtr(eligibilityBase.AddDate(1, 0, 0)), rule: eligibilityRuleMinAge},
+		{name: "età minima configurata", user: models.User{Gender: models.GenderMale, BirthDate: birthDate(17, 0)},
+			env:          map[string]string{"MIN_DONOR_AGE": "16"},
+			donationType: models.DonationTypeWholeBlood, want: &eligibilityBase},
+		{name: "prima donazione a 65 anni compiuti", user: models.User{Gender: models.GenderMale, BirthDate: birthDate(66, 1)},
+			donationType: models.DonationTypeWholeBlood, want: &eligibilityBase},
+		{name: "prima donazione a 66 anni", user: models.User{Gender: models.GenderMale, BirthDate: birthDate(66, 0)},
+			donationType: models.DonationTypeWholeBlood, rule: eligibilityRuleMaxAge},
+		{name: "66 anni senza idoneità medica", user: models.User{Gender: models.GenderMale, BirthDate: birthDate(66, 0)},
+			donations:    completed(models.DonationTypeWholeBlood, -400),
+			donationType: models.DonationTypeWholeBlood, rule: eligibilityRuleMaxAge},
+		{name: "66 anni con idoneità medica", user: cleared,
+			donations:    completed(models.DonationTypeWholeBlood, -400),
+			donationType: models.DonationTypeWholeBlood, want: &eligibilityBase},
+		{name: "71 anni con idoneità medica", user: models.User{Gender: models.GenderMale, BirthDate: birthDate(71, 0), AgeClearance: true},
+			donations:    completed(models.DonationTypeWholeBlood, -400),
+			donationType: models.DonationTypeWholeBlood, rule: eligibilityRuleMaxAge},
+		{name: "data di nascita non nota", user: models.User{Gender: models.GenderMale},
+			donationType: models.DonationTypeWholeBlood, want: &eligibilityBase},
+
+		// Sospensioni
+		{name: "esclusione permanente", user: male, suspension: suspensionStatus{Suspended: true, Permanent: true, PermanentFrom: timePtr(daysFromBase(-10))},
+			donationType: models.DonationTypeWholeBlood, rule: eligibilityRulePermanent},
+		{name: "esclusione permanente futura: idoneo prima dell'inizio", user: male, suspension: suspensionStatus{PermanentFrom: &until},
+			donationType: models.DonationTypeWholeBlood, want: &eligibilityBase},
+		{name: "esclusione permanente futura: bloccato dall'inizio", user: male, suspension: suspensionStatus{PermanentFrom: &untilSoon},
+			donations:    completed(models.DonationTypeWholeBlood, -60),
+			donationType: models.DonationTypeWholeBlood, rule: eligibilityRulePermanent},
+		{name: "sospensione temporanea", user: male, suspension: suspensionStatus{Suspended: true, Until: &until},
+			donationType: models.DonationTypeWholeBlood, want: &until, rule: eligibilityRuleSuspension},
+		{name: "la regola che sposta la data per ultima", user: male, suspension: suspensionStatus{Suspended: true, Until: &untilSoon},
+			donations:    completed(models.DonationTypeWholeBlood, -60),
+			donationType: models.DonationTypeWholeBlood, want: timePtr(daysFromBase(30)), rule: eligibilityRuleInterval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			record := &donorRecord{user: tt.user, donations: tt.donations, intervals: testIntervals, suspension: tt.suspension}
+
+			got := record.evaluate(tt.donationType, eligibilityBase)
+			switch {
+			case tt.want == nil && got.EarliestDate != nil:
+				t.Errorf("EarliestDate = %s, want nessuna data", got.EarliestDate.Format("2006-01-02"))
+			case tt.want != nil && (got.EarliestDate == nil || !got.EarliestDate.Equal(*tt.want)):
+				t.Errorf("EarliestDate = %v, want %s", got.EarliestDate, tt.want.Format("2006-01-02"))
+			}
+			if got.Rule != tt.rule {
+				t.Errorf("Rule = %q (%s), want %q", got.Rule, got.Reason, tt.rule)
+			}
+			if wantEligible := tt.want != nil && !tt.want.After(eligibilityBase); got.Eligible != wantEligible {
+				t.Errorf("Eligible = %v, want %v", got.Eligible, wantEligible)
+			}
+		})
+	}
+}
+
+func TestNextDue(t *testing.T) {
+	today := dateOnly(time.Now())
+	adult := today.AddDate(-30, 0, 0)
+	until := today.AddDate(0, 1, 0)
+	lastDonation := today.AddDate(0, 0, -100)
+
+	tests := []struct {
+		name       string
+		user       models.User
+		donations  []models.Donation
+		suspension suspensionStatus
+		want       *time.Time
+	}{
+		{name: "mai donato, data di nascita nota", user: models.User{Gender: models.GenderMale, BirthDate: &adult}},
+		{name: "mai donato, data di nascita non nota", user: models.User{Gender: models.GenderMale}},
+		{name: "mai donato ma sospeso", user: models.User{Gender: models.GenderMale, BirthDate: &adult},
+			suspension: suspensionStatus{Suspended: true, Until: &until}, want: &until},
+		{name: "scadenza passata", user: models.User{Gender: models.GenderMale, BirthDate: &adult},
+			donations: []models.Donation{{DonationDate: lastDonation, DonationType: models.DonationTypeWholeBlood, Status: models.DonationStatusCompleted}},
+			want:      timePtr(lastDonation.AddDate(0, 0, 90))},
+		{name: "escluso in modo permanente", user: models.User{Gender: models.GenderMale, BirthDate: &adult},
+			donations:  []models.Donation{{DonationDate: lastDonation, DonationType: models.DonationTypeWholeBlood, Status: models.DonationStatusCompleted}},
+			suspension: suspensionStatus{Suspended: true, Permanent: true, PermanentFrom: &lastDonation}},
+	}
+	for _, tt := range tests {
+		record := &donorRecord{user: tt.user, donations: tt.donations, intervals: testIntervals, suspension: tt.suspension}
+		if got := record.nextDue(models.DonationTypeWholeBlood); !sameTime(got, tt.want) {
+			t.Errorf("%s: nextDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApproachingAgeLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+		want bool
+	}{
+		{"lontano dal limite", models.User{BirthDate: birthDate(60, 0)}, false},
+		{"nei 12 mesi prima del limite", models.User{BirthDate: birthDate(65, 0)}, true},
+		{"limite superato", models.User{BirthDate: birthDate(66, 0)}, false},
+		{"data di nascita non nota", models.User{}, false},
+	}
+	for _, tt := range tests {
+		// Nessuna donazione: vale l'età massima per la prima donazione
+		record := &donorRecord{user: tt.user}
+		if got := record.approachingAgeLimit(eligibilityBase); got != tt.want {
+			t.Errorf("%s: approachingAgeLimit = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEarliestProposalDate(t *testing.T) {
+	today := dateOnly(time.Now())
+	tomorrow := today.AddDate(0, 0, 1)
+	suspensionEnd := today.AddDate(0, 2, 0)
+
+	tests := []struct {
+		name        string
+		donations   []models.Donation
+		suspensions []models.Suspension
+		want        time.Time
+		wantErr     bool
+	}{
+		{name: "nessuna donazione: da domani", want: tomorrow},
+		{name: "intervallo dalla matrice predefinita",
+			donations: []models.Donation{{DonationDate: today.AddDate(0, 0, -30), DonationType: models.DonationTypeWholeBlood, Status: models.DonationStatusCompleted}},
+			want:      today.AddDate(0, 0, 60)},
+		{name: "le donazioni non completate non contano",
+			donations: []models.Donation{{DonationDate: today.AddDate(0, 0, -30), DonationType: models.DonationTypeWholeBlood, Status: models.DonationStatusCancelled}},
+			want:      tomorrow},
+		{name: "sospensione temporanea",
+			suspensions: []models.Suspension{{StartDate: today.AddDate(0, 0, -7), EndDate: suspensionEnd, Reason: "Tatuaggio", IsActive: true}},
+			want:        suspensionEnd},
+		{name: "esclusione permanente",
+			suspensions: []models.Suspension{{StartDate: today.AddDate(0, 0, -7), Permanent: true, Reason: "Esclusione", IsActive: true}},
+			wantErr:     true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useTestStore(t)
+			user := createTestDonor(t, store, models.NotificationChannelEmail, "")
+			for _, donation := range tt.donations {
+				donation.DonorID = user.ID
+				if err := store.CreateDonation(&donation); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, suspension := range tt.suspensions {
+				suspension.DonorID = user.ID
+				if err := store.CreateSuspension(&suspension); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := earliestProposalDate(store, *user, models.DonationTypeWholeBlood)
+			if tt.wantErr {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict {
+					t.Fatalf("err = %v, want 409", err)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("earliestProposalDate = %s, %v; want %s", got.Format("2006-01-02"), err, tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
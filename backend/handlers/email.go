@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/mailer"
+	"bloodone/models"
+	"bloodone/scheduler"
+	"fmt"
+	"time"
+)
+
+// queueEmail compone il messaggio e lo mette nell'outbox nella transazione
+// corrente: viene salvato solo insieme all'operazione che lo genera e
+// consegnato dal job outbox, anche se il server SMTP è momentaneamente giù
+func queueEmail(tx database.Store, kind, to string, userID, appointmentID *uint, data messageData) error {
+	if to == "" {
+		return nil
+	}
+	data.URL = frontendBaseURL
+	msg, err := mailer.Render(kind, to, data)
+	if err != nil {
+		return fmt.Errorf("email %s: %w", kind, err)
+	}
+
+	now := time.Now()
+	notification := models.Notification{
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Channel:       models.NotificationChannelEmail,
+		Kind:          kind,
+		Recipient:     msg.To,
+		Subject:       msg.Subject,
+		Text:          msg.Text,
+		HTML:          msg.HTML,
+		UserID:        userID,
+		AppointmentID: appointmentID,
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: &now,
+	}
+	if err := tx.CreateNotification(&notification); err != nil {
+		return err
+	}
+	scheduler.Wake(outboxJob)
+	return nil
+}
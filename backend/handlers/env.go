@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envInt legge un intero non negativo da env, def se assente o non valido
+func envInt(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// envDays legge la variabile name come lista di giorni separati da virgola
+// (es. "3,1"), def se non impostata; "off" restituisce una lista vuota
+func envDays(name, def string) ([]int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		value = def
+	}
+	if value == "off" {
+		return nil, nil
+	}
+
+	var days []int
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s: %q non è un numero di giorni valido", name, strings.TrimSpace(part))
+		}
+		days = append(days, n)
+	}
+	return days, nil
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiError - Errore con codice HTTP, usato per uscire da una transazione
+// (database.DB.WithTx) annullandola e rispondendo al client con quel codice
+type apiError struct {
+	Status  int
+	Message string
+	// Campi aggiunti alla risposta accanto a "error"
+	Details gin.H
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, message string) error {
+	return &apiError{Status: status, Message: message}
+}
+
+// prefixError antepone prefix al messaggio di un apiError mantenendone codice e dettagli
+func prefixError(err error, prefix string) error {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return &apiError{Status: apiErr.Status, Message: prefix + apiErr.Message, Details: apiErr.Details}
+	}
+	return err
+}
+
+// respondError risponde con il codice di un apiError, altrimenti con 500 e il messaggio fallback
+func respondError(c *gin.Context, err error, fallback string) {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		body := gin.H{"error": apiErr.Message}
+		for k, v := range apiErr.Details {
+			body[k] = v
+		}
+		c.JSON(apiErr.Status, body)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
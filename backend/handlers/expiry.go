@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"fmt"
+	"time"
+)
+
+// Giorni entro cui il donatore deve rispondere a una proposta prima che scada (0 = nessun limite)
+const defaultProposalResponseDays = 7
+
+func proposalResponseDays() int {
+	return envInt("PROPOSAL_RESPONSE_DAYS", defaultProposalResponseDays)
+}
+
+// proposalExpiry restituisce il motivo per cui una proposta pending è scaduta, o "" se è ancora valida
+func proposalExpiry(appointment models.Appointment, now time.Time) string {
+	today := dateOnly(now)
+	allPast := true
+	for _, date := range []time.Time{appointment.ProposedDate1, appointment.ProposedDate2, appointment.ProposedDate3} {
+		if !date.IsZero() && !dateOnly(date).Before(today) {
+			allPast = false
+			break
+		}
+	}
+	if allPast {
+		return "tutte le date proposte sono passate"
+	}
+
+	if days := proposalResponseDays(); days > 0 && !now.Before(appointment.CreatedAt.AddDate(0, 0, days)) {
+		return fmt.Sprintf("nessuna risposta entro %d giorni", days)
+	}
+	return ""
+}
+
+// ExpireProposals segna come expired le proposte pending senza più date utili
+// o rimaste senza risposta oltre PROPOSAL_RESPONSE_DAYS giorni, avvisando gli
+// admin. Il donatore torna così tra quelli in scadenza e può ricevere nuove date.
+func ExpireProposals(now time.Time) (int, error) {
+	expired := 0
+	err := database.DB.WithTx(func(tx database.Store) error {
+		expired = 0
+		appointments, err := tx.ListAppointments()
+		if err != nil {
+			return err
+		}
+		for i := range appointments {
+			appointment := &appointments[i]
+			if appointment.Status != models.AppointmentStatusPending {
+				continue
+			}
+			reason := proposalExpiry(*appointment, now)
+			if reason == "" {
+				continue
+			}
+
+			before := *appointment
+			appointment.Status = models.AppointmentStatusExpired
+			appointment.UpdatedAt = now
+			if err := tx.UpdateAppointment(appointment); err != nil {
+				return err
+			}
+			if err := logAppointmentEvent(tx, before, appointment, 0, models.AppointmentActionExpired, "Proposta scaduta: "+reason); err != nil {
+				return err
+			}
+
+			donor := fmt.Sprintf("#%d", appointment.DonorID)
+			if user, err := tx.GetUser(appointment.DonorID); err == nil {
+				donor = user.FirstName + " " + user.LastName
+			}
+			message := fmt.Sprintf("La proposta di appuntamento per %s è scaduta (%s)", donor, reason)
+			donorID, appointmentID := appointment.DonorID, appointment.ID
+			if err := notifyAdmins(tx, models.AdminNotificationProposalExpired, message, &donorID, &appointmentID); err != nil {
+				return err
+			}
+			expired++
+		}
+		return nil
+	})
+	return expired, err
+}
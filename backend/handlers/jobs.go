@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bloodone/scheduler"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterJobs registra nello scheduler i job periodici del server. Come
+// un'espressione non valida, anche una configurazione errata dei promemoria
+// o del richiamo blocca l'avvio.
+func RegisterJobs() error {
+	if _, err := appointmentReminderDays(); err != nil {
+		return err
+	}
+	if _, err := recallMode(); err != nil {
+		return err
+	}
+	if _, err := recallFollowUpDays(); err != nil {
+		return err
+	}
+	jobs := []scheduler.Job{
+		{
+			Name:            "no-show",
+			Description:     "Segna come no_show gli appuntamenti confermati rimasti senza esito",
+			DefaultSchedule: "0 * * * *",
+			Run: func(now time.Time) (string, error) {
+				n, err := MarkNoShows(now)
+				return fmt.Sprintf("%d appuntamenti segnati come no_show", n), err
+			},
+		},
+		{
+			Name:            "proposal-expiry",
+			Description:     "Chiude le proposte di appuntamento scadute e avvisa gli admin",
+			DefaultSchedule: "5 * * * *",
+			Run: func(now time.Time) (string, error) {
+				n, err := ExpireProposals(now)
+				return fmt.Sprintf("%d proposte segnate come expired", n), err
+			},
+		},
+		{
+			Name:            "suspension-expiry",
+			Description:     "Chiude le sospensioni temporanee arrivate alla data di fine",
+			DefaultSchedule: "15 0 * * *",
+			Run: func(now time.Time) (string, error) {
+				n, err := ExpireSuspensions(now)
+				return fmt.Sprintf("%d sospensioni terminate", n), err
+			},
+		},
+		{
+			Name:            "appointment-reminders",
+			Description:     "Invia i promemoria degli appuntamenti confermati con le istruzioni per prepararsi",
+			DefaultSchedule: "0 18 * * *",
+			Run: func(now time.Time) (string, error) {
+				n, err := SendAppointmentReminders(now)
+				return fmt.Sprintf("%d promemoria inviati", n), err
+			},
+		},
+		{
+			Name:            "recall",
+			Description:     "Richiama i donatori tornati idonei senza appuntamento e invia i solleciti",
+			DefaultSchedule: "30 9 * * *",
+			Run: func(now time.Time) (string, error) {
+				run, err := RunRecalls(now)
+				return fmt.Sprintf("%d richiami aperti, %d solleciti, %d richiami chiusi", run.Opened, run.FollowUps, run.Closed), err
+			},
+		},
+		{
+			Name:            outboxJob,
+			Description:     "Consegna le notifiche in coda e ritenta quelle non consegnate",
+			DefaultSchedule: "@every 5m",
+			Run: func(now time.Time) (string, error) {
+				sent, failed, err := ProcessOutbox(now)
+				return fmt.Sprintf("%d notifiche consegnate, %d tentativi falliti", sent, failed), err
+			},
+		},
+	}
+	for _, job := range jobs {
+		if err := scheduler.Register(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetJobs - Job pianificati con espressione, prossima esecuzione ed esito dell'ultima (Admin)
+func GetJobs(c *gin.Context) {
+	jobs, err := scheduler.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// RunJob - Esegue subito un job e ne restituisce l'esito (Admin)
+func RunJob(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+	run, err := scheduler.RunNow(c.Param("name"), adminID.(uint))
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job non trovato"})
+		return
+	case errors.Is(err, scheduler.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": "Il job è già in esecuzione"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run job"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Giorni dopo la data confermata oltre i quali un appuntamento non completato è un no-show
+const defaultNoShowGraceDays = 1
+
+func noShowGraceDays() int {
+	return envInt("NO_SHOW_GRACE_DAYS", defaultNoShowGraceDays)
+}
+
+// MarkNoShows segna come no_show gli appuntamenti confermati la cui data è
+// passata da almeno NO_SHOW_GRACE_DAYS giorni senza esito registrato: il
+// contatore del donatore aumenta e il donatore torna proponibile.
+func MarkNoShows(now time.Time) (int, error) {
+	marked := 0
+	cutoff := dateOnly(now).AddDate(0, 0, -noShowGraceDays())
+	err := database.DB.WithTx(func(tx database.Store) error {
+		marked = 0
+		appointments, err := tx.ListAppointments()
+		if err != nil {
+			return err
+		}
+		for i := range appointments {
+			appointment := &appointments[i]
+			if appointment.Status != models.AppointmentStatusConfirmed || appointment.ConfirmedDate == nil {
+				continue
+			}
+			if dateOnly(*appointment.ConfirmedDate).After(cutoff) {
+				continue
+			}
+
+			before := *appointment
+			appointment.Status = models.AppointmentStatusNoShow
+			appointment.UpdatedAt = now
+			if err := tx.UpdateAppointment(appointment); err != nil {
+				return err
+			}
+			if err := logAppointmentEvent(tx, before, appointment, 0, models.AppointmentActionNoShow, "Segnato automaticamente: nessun esito registrato"); err != nil {
+				return err
+			}
+
+			if user, err := tx.GetUser(appointment.DonorID); err == nil {
+				user.NoShowCount++
+				user.NextAppointmentDate = nil
+				user.UpdatedAt = now
+				if err := tx.UpdateUser(user); err != nil {
+					return err
+				}
+			}
+			marked++
+		}
+		return nil
+	})
+	return marked, err
+}
+
+// GetNoShowStats - Statistiche sui no-show (Admin): totale, tasso sugli
+// appuntamenti con esito, andamento mensile e donatori con più assenze
+func GetNoShowStats(c *gin.Context) {
+	appointments, err := database.DB.ListAppointments()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load appointments"})
+		return
+	}
+
+	type donorNoShows struct {
+		DonorID    uint       `json:"donor_id"`
+		FirstName  string     `json:"first_name"`
+		LastName   string     `json:"last_name"`
+		Count      int        `json:"count"`
+		LastNoShow *time.Time `json:"last_no_show,omitempty"`
+	}
+
+	total, withOutcome := 0, 0
+	byMonth := map[string]int{}
+	byDonor := map[uint]*donorNoShows{}
+	for _, a := range appointments {
+		switch a.Status {
+		case models.AppointmentStatusCompleted, models.AppointmentStatusDeferred:
+			withOutcome++
+		case models.AppointmentStatusNoShow:
+			withOutcome++
+			total++
+			d := byDonor[a.DonorID]
+			if d == nil {
+				d = &donorNoShows{DonorID: a.DonorID}
+				byDonor[a.DonorID] = d
+			}
+			d.Count++
+			if a.ConfirmedDate != nil {
+				byMonth[a.ConfirmedDate.Format("2006-01")]++
+				if d.LastNoShow == nil || a.ConfirmedDate.After(*d.LastNoShow) {
+					d.LastNoShow = a.ConfirmedDate
+				}
+			}
+		}
+	}
+
+	donors := []donorNoShows{}
+	for _, d := range byDonor {
+		if user, err := database.DB.GetUser(d.DonorID); err == nil {
+			d.FirstName = user.FirstName
+			d.LastName = user.LastName
+		}
+		donors = append(donors, *d)
+	}
+	sort.Slice(donors, func(i, j int) bool {
+		if donors[i].Count != donors[j].Count {
+			return donors[i].Count > donors[j].Count
+		}
+		return donors[i].DonorID < donors[j].DonorID
+	})
+
+	rate := 0.0
+	if withOutcome > 0 {
+		rate = float64(total) / float64(withOutcome)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total_no_shows":            total,
+		"appointments_with_outcome": withOutcome,
+		"no_show_rate":              rate,
+		"by_month":                  byMonth,
+		"donors":                    donors,
+	})
+}
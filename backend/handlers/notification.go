@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notifyAdmins crea un avviso per gli amministratori nella transazione corrente
+func notifyAdmins(tx database.Store, kind, message string, donorID, appointmentID *uint) error {
+	notification := models.AdminNotification{
+		CreatedAt:     time.Now(),
+		Kind:          kind,
+		Message:       message,
+		DonorID:       donorID,
+		AppointmentID: appointmentID,
+	}
+	return tx.CreateAdminNotification(&notification)
+}
+
+// GetAdminNotifications - Avvisi per gli amministratori, dal più recente (?unread=true solo da leggere)
+func GetAdminNotifications(c *gin.Context) {
+	notifications, err := database.DB.ListAdminNotifications()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notifications"})
+		return
+	}
+
+	onlyUnread := c.Query("unread") == "true"
+	result := []models.AdminNotification{}
+	for _, n := range notifications {
+		if onlyUnread && n.ReadAt != nil {
+			continue
+		}
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID > result[j].ID })
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MarkAdminNotificationRead - Segna un avviso come letto
+func MarkAdminNotificationRead(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	adminID, _ := c.Get("user_id")
+
+	var notification *models.AdminNotification
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		notification, err = tx.GetAdminNotification(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Notifica non trovata")
+		}
+		if notification.ReadAt != nil {
+			return nil
+		}
+		now := time.Now()
+		readBy := adminID.(uint)
+		notification.ReadAt = &now
+		notification.ReadBy = &readBy
+		return tx.UpdateAdminNotification(notification)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to update notification")
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
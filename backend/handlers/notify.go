@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"time"
+)
+
+// messageData - Dati usati dai modelli in mailer/templates e sms/templates
+type messageData struct {
+	FirstName string
+	URL       string
+	Dates     []time.Time
+	Deadline  *time.Time
+	Date      *time.Time
+	Slot      string
+	Reason    string
+	Until     *time.Time
+	Permanent bool
+	DaysLeft  int  // giorni all'appuntamento, per i promemoria
+	Apheresis bool // plasmaferesi o piastrinoaferesi
+	FollowUp  bool // sollecito di un richiamo già inviato
+}
+
+// notifyUser mette nell'outbox un messaggio di tipo kind per l'utente userID
+// sul canale che ha scelto: email, SMS o entrambi. Se l'SMS non può partire
+// (canale SMS disattivato, numero non valido o tipo senza modello SMS) il
+// messaggio viene inviato per email.
+func notifyUser(tx database.Store, kind string, userID uint, appointmentID *uint, data messageData) error {
+	user, err := tx.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	data.FirstName = user.FirstName
+
+	channel := user.GetNotificationChannel()
+	if channel == models.NotificationChannelSMS || channel == models.NotificationChannelBoth {
+		queued, err := queueSMS(tx, kind, user, appointmentID, data)
+		if err != nil {
+			return err
+		}
+		if queued && channel == models.NotificationChannelSMS {
+			return nil
+		}
+	}
+	return queueEmail(tx, kind, user.Email, &user.ID, appointmentID, data)
+}
+
+// notifyProposal avvisa il donatore delle date proposte e del termine per rispondere
+func notifyProposal(tx database.Store, appointment *models.Appointment) error {
+	data := messageData{Dates: []time.Time{appointment.ProposedDate1, appointment.ProposedDate2, appointment.ProposedDate3}}
+	if days := proposalResponseDays(); days > 0 {
+		deadline := appointment.CreatedAt.AddDate(0, 0, days)
+		data.Deadline = &deadline
+	}
+	return notifyUser(tx, models.NotificationKindAppointmentProposed, appointment.DonorID, &appointment.ID, data)
+}
+
+// notifyConfirmation riepiloga al donatore data e orario confermati
+func notifyConfirmation(tx database.Store, appointment *models.Appointment) error {
+	return notifyUser(tx, models.NotificationKindAppointmentConfirmed, appointment.DonorID, &appointment.ID, messageData{
+		Date: appointment.ConfirmedDate,
+		Slot: appointment.ConfirmedSlot,
+	})
+}
+
+// notifyCancellation avvisa il donatore di un appuntamento annullato
+func notifyCancellation(tx database.Store, appointment *models.Appointment) error {
+	return notifyUser(tx, models.NotificationKindAppointmentCancelled, appointment.DonorID, &appointment.ID, messageData{
+		Date:   appointment.ConfirmedDate,
+		Reason: appointment.CancelReason,
+	})
+}
+
+// notifySuspension avvisa il donatore di una sospensione attiva. Il motivo
+// (un dato sanitario) non viene riportato: il donatore lo chiede al centro.
+func notifySuspension(tx database.Store, suspension *models.Suspension) error {
+	if !suspension.IsActive || suspension.PendingReview {
+		return nil
+	}
+	data := messageData{Permanent: suspension.Permanent}
+	if !suspension.Permanent {
+		until := suspension.EndDate
+		data.Until = &until
+	}
+	return notifyUser(tx, models.NotificationKindSuspension, suspension.DonorID, nil, data)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/mailer"
+	"bloodone/models"
+	"bloodone/scheduler"
+	"bloodone/sms"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Nome del job che consegna le notifiche dell'outbox
+const outboxJob = "outbox"
+
+// Attesa massima tra due tentativi di consegna
+const maxRetryDelay = 6 * time.Hour
+
+// notificationMaxAttempts - Tentativi di consegna prima che una notifica
+// passi a failed (NOTIFICATION_MAX_ATTEMPTS, default 8)
+func notificationMaxAttempts() int {
+	if n := envInt("NOTIFICATION_MAX_ATTEMPTS", 8); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// retryDelay - Attesa dopo il tentativo fallito numero attempts: raddoppia a
+// ogni tentativo partendo da NOTIFICATION_RETRY_BASE_MINUTES (default 5)
+func retryDelay(attempts int) time.Duration {
+	delay := time.Duration(envInt("NOTIFICATION_RETRY_BASE_MINUTES", 5)) * time.Minute
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// deliver consegna la notifica sul suo canale
+func deliver(notification models.Notification) error {
+	switch notification.Channel {
+	case models.NotificationChannelEmail:
+		return mailer.Send(mailer.Message{
+			To:      notification.Recipient,
+			Subject: notification.Subject,
+			Text:    notification.Text,
+			HTML:    notification.HTML,
+		})
+	case models.NotificationChannelSMS:
+		return sms.Send(sms.Message{To: notification.Recipient, Text: notification.Text})
+	default:
+		return fmt.Errorf("canale %q non supportato", notification.Channel)
+	}
+}
+
+// ProcessOutbox consegna le notifiche pending arrivate al momento del loro
+// tentativo. L'invio avviene fuori dalla transazione; l'esito viene salvato
+// subito dopo: un errore riprogramma il tentativo con attesa crescente, e
+// esauriti i tentativi la notifica passa a failed e gli admin vengono avvisati.
+// Restituisce le notifiche consegnate e i tentativi falliti.
+func ProcessOutbox(now time.Time) (int, int, error) {
+	pending, err := database.DB.ListNotificationsByStatus(models.NotificationStatusPending)
+	if err != nil {
+		return 0, 0, err
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+
+	sent, failed := 0, 0
+	for _, notification := range pending {
+		if notification.NextAttemptAt != nil && now.Before(*notification.NextAttemptAt) {
+			continue
+		}
+		sendErr := deliver(notification)
+		if err := recordDelivery(notification.ID, sendErr, time.Now()); err != nil {
+			return sent, failed, err
+		}
+		if sendErr != nil {
+			log.Printf("Notifica %d a %s non consegnata: %v", notification.ID, notification.Recipient, sendErr)
+			failed++
+		} else {
+			sent++
+		}
+	}
+	return sent, failed, nil
+}
+
+// recordDelivery salva l'esito di un tentativo di consegna. Una notifica
+// scartata da un admin nel frattempo resta com'è.
+func recordDelivery(id uint, sendErr error, at time.Time) error {
+	return database.DB.WithTx(func(tx database.Store) error {
+		notification, err := tx.GetNotification(id)
+		if err != nil || notification.Status != models.NotificationStatusPending {
+			return nil
+		}
+
+		notification.Attempts++
+		notification.LastAttemptAt = &at
+		notification.NextAttemptAt = nil
+		notification.UpdatedAt = at
+		if sendErr == nil {
+			notification.Status = models.NotificationStatusSent
+			notification.Error = ""
+			notification.SentAt = &at
+			if notification.Channel == models.NotificationChannelSMS {
+				notification.Cost = sms.Cost(notification.Segments)
+			}
+			if err := tx.UpdateNotification(notification); err != nil {
+				return err
+			}
+			return markProposalNotified(tx, notification)
+		}
+
+		notification.Error = sendErr.Error()
+		if notification.Attempts < notificationMaxAttempts() {
+			next := at.Add(retryDelay(notification.Attempts))
+			notification.NextAttemptAt = &next
+			return tx.UpdateNotification(notification)
+		}
+
+		notification.Status = models.NotificationStatusFailed
+		if err := tx.UpdateNotification(notification); err != nil {
+			return err
+		}
+		message := fmt.Sprintf("Notifica %s a %s non consegnata dopo %d tentativi: %s", notification.Kind, notification.Recipient, notification.Attempts, notification.Error)
+		return notifyAdmins(tx, models.AdminNotificationDeliveryFailed, message, notification.UserID, notification.AppointmentID)
+	})
+}
+
+// markProposalNotified segna l'appuntamento come notificato quando la
+// proposta è stata consegnata al donatore
+func markProposalNotified(tx database.Store, notification *models.Notification) error {
+	if notification.Kind != models.NotificationKindAppointmentProposed || notification.AppointmentID == nil {
+		return nil
+	}
+	appointment, err := tx.GetAppointment(*notification.AppointmentID)
+	if err != nil || appointment.NotificationSent {
+		return nil
+	}
+	appointment.NotificationSent = true
+	return tx.UpdateAppointment(appointment)
+}
+
+// GetDonorNotifications - Notifiche per i donatori nell'outbox con lo stato
+// della consegna, dalla più recente (?status=, ?user_id=)
+func GetDonorNotifications(c *gin.Context) {
+	var notifications []models.Notification
+	var err error
+	if userID := c.Query("user_id"); userID != "" {
+		id, parseErr := strconv.ParseUint(userID, 10, 32)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id non valido"})
+			return
+		}
+		notifications, err = database.DB.ListNotificationsByUser(uint(id))
+	} else {
+		notifications, err = database.DB.ListNotifications()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notifications"})
+		return
+	}
+
+	status := c.Query("status")
+	result := []models.Notification{}
+	for _, n := range notifications {
+		if status != "" && n.Status != status {
+			continue
+		}
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID > result[j].ID })
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetDonorNotification - Dettaglio di una notifica, con il messaggio completo
+func GetDonorNotification(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	notification, err := database.DB.GetNotification(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notifica non trovata"})
+		return
+	}
+	c.JSON(http.StatusOK, notification)
+}
+
+// RetryDonorNotification - Rimette in coda una notifica failed o scartata,
+// con tutti i tentativi disponibili (Admin)
+func RetryDonorNotification(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var notification *models.Notification
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		notification, err = tx.GetNotification(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Notifica non trovata")
+		}
+		if notification.Status != models.NotificationStatusFailed && notification.Status != models.NotificationStatusDiscarded {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("Solo una notifica failed o discarded può essere rimessa in coda (stato attuale: %s)", notification.Status))
+		}
+
+		now := time.Now()
+		notification.Status = models.NotificationStatusPending
+		notification.Attempts = 0
+		notification.NextAttemptAt = &now
+		notification.DiscardedAt = nil
+		notification.DiscardedBy = nil
+		notification.UpdatedAt = now
+		return tx.UpdateNotification(notification)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to retry notification")
+		return
+	}
+	scheduler.Wake(outboxJob)
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// DiscardDonorNotification - Scarta una notifica non ancora consegnata (Admin)
+func DiscardDonorNotification(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	adminID, _ := c.Get("user_id")
+
+	var notification *models.Notification
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		notification, err = tx.GetNotification(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Notifica non trovata")
+		}
+		if notification.Status != models.NotificationStatusPending && notification.Status != models.NotificationStatusFailed {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("Solo una notifica pending o failed può essere scartata (stato attuale: %s)", notification.Status))
+		}
+
+		now := time.Now()
+		discardedBy := adminID.(uint)
+		notification.Status = models.NotificationStatusDiscarded
+		notification.NextAttemptAt = nil
+		notification.DiscardedAt = &now
+		notification.DiscardedBy = &discardedBy
+		notification.UpdatedAt = now
+		return tx.UpdateNotification(notification)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to discard notification")
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/mailer"
+	"bloodone/models"
+	"bloodone/sms"
+	"errors"
+	"testing"
+	"time"
+)
+
+// createTestDonor crea un donatore con il canale di notifica indicato
+func createTestDonor(t *testing.T, store database.Store, channel, phone string) *models.User {
+	t.Helper()
+	user := &models.User{
+		Email:               "donatore@example.com",
+		FirstName:           "Marco",
+		LastName:            "Rossi",
+		PhoneNumber:         phone,
+		Gender:              models.GenderMale,
+		IsActive:            true,
+		NotificationChannel: channel,
+	}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// queueTestRecall mette nell'outbox un invito al richiamo per il donatore
+func queueTestRecall(t *testing.T, store database.Store, userID uint) {
+	t.Helper()
+	due := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	err := store.WithTx(func(tx database.Store) error {
+		return notifyUser(tx, models.NotificationKindRecall, userID, nil, messageData{Date: &due})
+	})
+	if err != nil {
+		t.Fatalf("notifyUser: %v", err)
+	}
+}
+
+// fakeMailer - Sender email che tiene in memoria i messaggi; con err
+// impostato ogni invio fallisce
+type fakeMailer struct {
+	sent []mailer.Message
+	err  error
+}
+
+func (f *fakeMailer) Send(msg mailer.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func notificationsByChannel(t *testing.T, store database.Store) map[string][]models.Notification {
+	t.Helper()
+	notifications, err := store.ListNotifications()
+	if err != nil {
+		t.Fatalf("ListNotifications: %v", err)
+	}
+	byChannel := map[string][]models.Notification{}
+	for _, n := range notifications {
+		byChannel[n.Channel] = append(byChannel[n.Channel], n)
+	}
+	return byChannel
+}
+
+func TestOutboxDeliversSMSWithFake(t *testing.T) {
+	store := useTestStore(t)
+	t.Setenv("SMS_COST_PER_SEGMENT", "0.05")
+	if err := sms.Configure(); err != nil {
+		t.Fatal(err)
+	}
+	fake := &sms.Fake{}
+	sms.SetSender(fake)
+	t.Cleanup(func() { sms.SetSender(nil) })
+
+	user := createTestDonor(t, store, models.NotificationChannelSMS, "333 123 4567")
+	queueTestRecall(t, store, user.ID)
+
+	byChannel := notificationsByChannel(t, store)
+	if len(byChannel[models.NotificationChannelEmail]) != 0 || len(byChannel[models.NotificationChannelSMS]) != 1 {
+		t.Fatalf("notifiche in coda = %v, want un solo SMS", byChannel)
+	}
+
+	sent, failed, err := ProcessOutbox(time.Now())
+	if err != nil || sent != 1 || failed != 0 {
+		t.Fatalf("ProcessOutbox = %d, %d, %v; want 1, 0", sent, failed, err)
+	}
+	messages := fake.Sent()
+	if len(messages) != 1 || messages[0].To != "+393331234567" {
+		t.Fatalf("SMS inviati = %v", messages)
+	}
+
+	notification := notificationsByChannel(t, store)[models.NotificationChannelSMS][0]
+	if notification.Status != models.NotificationStatusSent || notification.Text != messages[0].Text {
+		t.Errorf("notifica = %s %q", notification.Status, notification.Text)
+	}
+	if notification.Segments != sms.Segments(messages[0].Text) || notification.Cost != 0.05*float64(notification.Segments) {
+		t.Errorf("segmenti e costo = %d, %v", notification.Segments, notification.Cost)
+	}
+}
+
+func TestOutboxRetriesFailedSMS(t *testing.T) {
+	store := useTestStore(t)
+	fake := &sms.Fake{Err: errors.New("gateway giù")}
+	sms.SetSender(fake)
+	t.Cleanup(func() { sms.SetSender(nil) })
+
+	user := createTestDonor(t, store, models.NotificationChannelBoth, "+39 333 1234567")
+	queueTestRecall(t, store, user.ID)
+
+	now := time.Now()
+	sent, failed, err := ProcessOutbox(now)
+	if err != nil || sent != 1 || failed != 1 {
+		t.Fatalf("ProcessOutbox = %d, %d, %v; want email consegnata e SMS fallito", sent, failed, err)
+	}
+	notification := notificationsByChannel(t, store)[models.NotificationChannelSMS][0]
+	if notification.Status != models.NotificationStatusPending || notification.Attempts != 1 ||
+		notification.NextAttemptAt == nil || !notification.NextAttemptAt.After(now) || notification.Cost != 0 {
+		t.Fatalf("SMS fallito = %+v", notification)
+	}
+
+	// Al tentativo successivo il gateway risponde
+	fake.Err = nil
+	if sent, _, _ := ProcessOutbox(*notification.NextAttemptAt); sent != 1 || len(fake.Sent()) != 1 {
+		t.Errorf("secondo tentativo: consegnati %d, SMS %d", sent, len(fake.Sent()))
+	}
+}
+
+func TestNotifyFallsBackToEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		sender sms.Sender
+		phone  string
+	}{
+		{"canale SMS disattivato", nil, "3331234567"},
+		{"numero non valido", &sms.Fake{}, "123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useTestStore(t)
+			sms.SetSender(tt.sender)
+			t.Cleanup(func() { sms.SetSender(nil) })
+
+			user := createTestDonor(t, store, models.NotificationChannelSMS, tt.phone)
+			queueTestRecall(t, store, user.ID)
+
+			byChannel := notificationsByChannel(t, store)
+			if len(byChannel[models.NotificationChannelSMS]) != 0 || len(byChannel[models.NotificationChannelEmail]) != 1 {
+				t.Errorf("notifiche = %v, want solo l'email", byChannel)
+			}
+		})
+	}
+}
+
+func TestOutboxDeliversEmailProposal(t *testing.T) {
+	store := useTestStore(t)
+	fake := &fakeMailer{}
+	mailer.SetSender(fake)
+	t.Cleanup(func() { mailer.SetSender(nil) })
+
+	user := createTestDonor(t, store, models.NotificationChannelEmail, "")
+	first := dateOnly(time.Now()).AddDate(0, 0, 7)
+	appointment := models.Appointment{
+		CreatedAt:     time.Now(),
+		DonorID:       user.ID,
+		ProposedDate1: first,
+		ProposedDate2: first.AddDate(0, 0, 1),
+		ProposedDate3: first.AddDate(0, 0, 2),
+		Status:        models.AppointmentStatusPending,
+		DonationType:  models.DonationTypeWholeBlood,
+	}
+	err := store.WithTx(func(tx database.Store) error {
+		if err := tx.CreateAppointment(&appointment); err != nil {
+			return err
+		}
+		return notifyProposal(tx, &appointment)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent, failed, err := ProcessOutbox(time.Now())
+	if err != nil || sent != 1 || failed != 0 {
+		t.Fatalf("ProcessOutbox = %d, %d, %v; want 1, 0", sent, failed, err)
+	}
+	if len(fake.sent) != 1 || fake.sent[0].To != user.Email || fake.sent[0].Subject != "Nuove date per la tua donazione" {
+		t.Fatalf("email inviate = %+v", fake.sent)
+	}
+	notification := notificationsByChannel(t, store)[models.NotificationChannelEmail][0]
+	if notification.Status != models.NotificationStatusSent || notification.SentAt == nil || notification.Attempts != 1 {
+		t.Errorf("notifica = %+v", notification)
+	}
+	// La proposta risulta notificata solo dopo la consegna
+	stored, err := store.GetAppointment(appointment.ID)
+	if err != nil || !stored.NotificationSent {
+		t.Errorf("appuntamento notificato = %v, %v", stored.NotificationSent, err)
+	}
+}
+
+func TestOutboxDeadLettersEmail(t *testing.T) {
+	store := useTestStore(t)
+	t.Setenv("NOTIFICATION_MAX_ATTEMPTS", "2")
+	fake := &fakeMailer{err: errors.New("SMTP giù")}
+	mailer.SetSender(fake)
+	t.Cleanup(func() { mailer.SetSender(nil) })
+
+	user := createTestDonor(t, store, models.NotificationChannelEmail, "")
+	queueTestRecall(t, store, user.ID)
+
+	now := time.Now()
+	if sent, failed, err := ProcessOutbox(now); err != nil || sent != 0 || failed != 1 {
+		t.Fatalf("primo tentativo = %d, %d, %v; want 0, 1", sent, failed, err)
+	}
+	notification := notificationsByChannel(t, store)[models.NotificationChannelEmail][0]
+	if notification.Status != models.NotificationStatusPending || notification.NextAttemptAt == nil {
+		t.Fatalf("dopo il primo tentativo = %+v", notification)
+	}
+	// Prima del tentativo successivo la notifica non viene ritentata
+	if sent, failed, _ := ProcessOutbox(now); sent != 0 || failed != 0 {
+		t.Errorf("tentativo anticipato: consegnati %d, falliti %d", sent, failed)
+	}
+
+	if _, failed, err := ProcessOutbox(*notification.NextAttemptAt); err != nil || failed != 1 {
+		t.Fatalf("secondo tentativo = %d, %v", failed, err)
+	}
+	notification = notificationsByChannel(t, store)[models.NotificationChannelEmail][0]
+	if notification.Status != models.NotificationStatusFailed || notification.Attempts != 2 ||
+		notification.NextAttemptAt != nil || notification.Error != "SMTP giù" {
+		t.Errorf("esauriti i tentativi = %+v", notification)
+	}
+	alerts, err := store.ListAdminNotifications()
+	if err != nil || len(alerts) != 1 || alerts[0].Kind != models.AdminNotificationDeliveryFailed ||
+		alerts[0].DonorID == nil || *alerts[0].DonorID != user.ID {
+		t.Errorf("avvisi agli admin = %+v, %v", alerts, err)
+	}
+}
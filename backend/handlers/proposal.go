@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Giorni, a partire dalla prima data utile, in cui cercare le date da proporre
+const proposalWindowDays = 56
+
+// dateOnly riporta t alla mezzanotte UTC dello stesso giorno, come le date lette da "2006-01-02"
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// earliestProposalDate - Primo giorno proponibile al donatore per il tipo
+// di donazione, secondo le regole di idoneità (vedi donorRecord)
+func earliestProposalDate(tx database.Store, user models.User, donationType models.DonationType) (time.Time, error) {
+	record, err := loadDonorRecord(tx, user)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return record.earliestProposalDate(donationType)
+}
+
+// suggestDates sceglie fino a count giorni prenotabili da from in poi, il
+// prima possibile ma su giorni della settimana diversi tra loro e da quelli
+// di taken. I giorni preferiti dal donatore hanno la precedenza; se non
+// bastano si usano anche gli altri giorni di apertura.
+func suggestDates(cal *capacityCalendar, from time.Time, preferred models.IntList, taken []time.Time, count int) []time.Time {
+	var candidates []time.Time
+	for i := 0; i < proposalWindowDays; i++ {
+		date := from.AddDate(0, 0, i)
+		if cal.check(date) == nil {
+			candidates = append(candidates, date)
+		}
+	}
+
+	used := map[string]bool{}
+	usedWeekdays := map[time.Weekday]bool{}
+	for _, t := range taken {
+		used[t.Format("2006-01-02")] = true
+		usedWeekdays[t.Weekday()] = true
+	}
+
+	chosen := []time.Time{}
+	pick := func(onlyPreferred, newWeekday bool) {
+		for _, date := range candidates {
+			if len(chosen) == count {
+				return
+			}
+			if used[date.Format("2006-01-02")] {
+				continue
+			}
+			if onlyPreferred && len(preferred) > 0 && !preferred.Contains(int(date.Weekday())) {
+				continue
+			}
+			if newWeekday && usedWeekdays[date.Weekday()] {
+				continue
+			}
+			chosen = append(chosen, date)
+			used[date.Format("2006-01-02")] = true
+			usedWeekdays[date.Weekday()] = true
+		}
+	}
+	pick(true, true)
+	pick(true, false)
+	pick(false, true)
+	pick(false, false)
+
+	sort.Slice(chosen, func(i, j int) bool { return chosen[i].Before(chosen[j]) })
+	return chosen
+}
+
+// createProposal crea una proposta di appuntamento per il donatore e gliela
+// notifica. Le date nil vengono scelte automaticamente dalla prima data
+// utile; override permette date chiuse o piene. createdBy è 0 se la proposta
+// è creata dal sistema (es. dal richiamo dei donatori).
+func createProposal(tx database.Store, donorID uint, donationType models.DonationType, dates [3]*time.Time, override bool, createdBy uint) (*models.Appointment, error) {
+	appointment := models.Appointment{
+		DonorID:      donorID,
+		Status:       models.AppointmentStatusPending,
+		DonationType: donationType,
+		CreatedAt:    time.Now(),
+	}
+
+	// Una data passata non potrebbe mai essere confermata dal donatore
+	today := dateOnly(time.Now())
+	for i, date := range dates {
+		if date != nil && dateOnly(*date).Before(today) {
+			return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("Data %d: non può essere precedente a oggi", i+1))
+		}
+	}
+
+	// Verifica che il donatore non abbia già un appuntamento pending o confirmed
+	appointments, err := tx.ListAppointmentsByDonor(donorID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range appointments {
+		if a.Status == models.AppointmentStatusPending || a.Status == models.AppointmentStatusConfirmed {
+			return nil, newAPIError(http.StatusConflict, "Il donatore ha già un appuntamento attivo")
+		}
+	}
+
+	user, err := tx.GetUser(donorID)
+	if err != nil {
+		return nil, newAPIError(http.StatusNotFound, "User not found")
+	}
+	// Un donatore che non potrà più donare questo tipo non è proponibile
+	record, err := loadDonorRecord(tx, *user)
+	if err != nil {
+		return nil, err
+	}
+	earliest, err := record.earliestProposalDate(donationType)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := loadCapacityCalendar(tx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := false
+	var taken []time.Time
+	missing := 0
+	for i := range dates {
+		if dates[i] == nil {
+			missing++
+			continue
+		}
+		forced, err := cal.checkBookable(*dates[i], override)
+		if err != nil {
+			return nil, newAPIError(http.StatusConflict, fmt.Sprintf("Data %d: %s", i+1, err.Error()))
+		}
+		if err := record.requireEligible(donationType, *dates[i]); err != nil {
+			return nil, prefixError(err, fmt.Sprintf("Data %d: ", i+1))
+		}
+		overridden = overridden || forced
+		taken = append(taken, *dates[i])
+	}
+
+	if missing > 0 {
+		suggested := suggestDates(cal, earliest, user.PreferredWeekdays, taken, missing)
+		if len(suggested) < missing {
+			return nil, newAPIError(http.StatusConflict, fmt.Sprintf("Nessuna data disponibile da proporre nelle %d settimane dal %s", proposalWindowDays/7, earliest.Format("2006-01-02")))
+		}
+		for i := range dates {
+			if dates[i] == nil {
+				// Le date scelte partono dalla prima data utile, ma l'età massima può cadere nella finestra
+				if err := record.requireEligible(donationType, suggested[0]); err != nil {
+					return nil, prefixError(err, fmt.Sprintf("Data %d: ", i+1))
+				}
+				dates[i] = &suggested[0]
+				suggested = suggested[1:]
+			}
+		}
+	}
+	if overridden {
+		recordOverride(&appointment, createdBy)
+	}
+
+	appointment.ProposedDate1 = *dates[0]
+	appointment.ProposedDate2 = *dates[1]
+	appointment.ProposedDate3 = *dates[2]
+	if err := tx.CreateAppointment(&appointment); err != nil {
+		return nil, err
+	}
+	if err := logAppointmentEvent(tx, models.Appointment{}, &appointment, createdBy, models.AppointmentActionProposed, ""); err != nil {
+		return nil, err
+	}
+	if err := notifyProposal(tx, &appointment); err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+// GetSuggestedDates - Anteprima delle date che verrebbero proposte al donatore (Admin)
+func GetSuggestedDates(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	user, err := database.DB.GetUser(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	donationType, err := parseDonationType(c.Query("donation_type"))
+	if err != nil {
+		respondError(c, err, "Failed to compute proposal")
+		return
+	}
+	earliest, err := earliestProposalDate(database.DB, *user, donationType)
+	if err != nil {
+		respondError(c, err, "Failed to compute proposal")
+		return
+	}
+	cal, err := loadCapacityCalendar(database.DB, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load availability"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"donation_type":      donationType,
+		"earliest_date":      earliest,
+		"preferred_weekdays": user.PreferredWeekdays,
+		"dates":              suggestDates(cal, earliest, user.PreferredWeekdays, nil, 3),
+	})
+}
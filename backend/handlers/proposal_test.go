@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCreateProposalRejectsPastDates(t *testing.T) {
+	today := dateOnly(time.Now())
+	yesterday := today.AddDate(0, 0, -1)
+	later := today.AddDate(0, 0, 10)
+
+	tests := []struct {
+		name     string
+		dates    [3]*time.Time
+		override bool
+		wantErr  bool // 400 per la data passata
+	}{
+		{"prima data passata", [3]*time.Time{&yesterday, nil, nil}, false, true},
+		{"terza data passata", [3]*time.Time{&later, nil, &yesterday}, false, true},
+		{"passata anche forzando il calendario", [3]*time.Time{&yesterday, nil, nil}, true, true},
+		{"oggi", [3]*time.Time{&today, nil, nil}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useTestStore(t)
+			donor := createTestDonor(t, store, models.NotificationChannelEmail, "")
+			err := store.WithTx(func(tx database.Store) error {
+				_, err := createProposal(tx, donor.ID, models.DonationTypeWholeBlood, tt.dates, tt.override, 1)
+				return err
+			})
+			var apiErr *apiError
+			badRequest := errors.As(err, &apiErr) && apiErr.Status == http.StatusBadRequest
+			if badRequest != tt.wantErr {
+				t.Errorf("createProposal = %v, want 400: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if appointments, _ := store.ListAppointmentsByDonor(donor.ID); len(appointments) != 0 {
+					t.Errorf("appuntamenti creati = %d, want 0", len(appointments))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateQuestions normalizza e controlla le domande di una versione
+func validateQuestions(tx database.Store, questions models.QuestionList) error {
+	if len(questions) == 0 {
+		return newAPIError(http.StatusBadRequest, "Il questionario deve avere almeno una domanda")
+	}
+	codes := map[string]bool{}
+	for i := range questions {
+		q := &questions[i]
+		q.Code = strings.ToLower(strings.TrimSpace(q.Code))
+		q.Text = strings.TrimSpace(q.Text)
+		if q.Code == "" || q.Text == "" {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda %d: code e text sono obbligatori", i+1))
+		}
+		if codes[q.Code] {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda %d: code %q ripetuto", i+1, q.Code))
+		}
+		codes[q.Code] = true
+
+		switch q.AnswerType {
+		case models.AnswerTypeYesNo:
+			q.Options = nil
+			for _, answer := range q.DisqualifyingAnswers {
+				if answer != "yes" && answer != "no" {
+					return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda %s: le risposte yes_no sono yes o no", q.Code))
+				}
+			}
+		case models.AnswerTypeChoice:
+			if len(q.Options) < 2 {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda %s: indica almeno due options", q.Code))
+			}
+			for _, answer := range q.DisqualifyingAnswers {
+				if !containsString(q.Options, answer) {
+					return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda %s: %q non è tra le options", q.Code, answer))
+				}
+			}
+		case models.AnswerTypeNumber, models.AnswerTypeText:
+			if len(q.DisqualifyingAnswers) > 0 {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda %s: solo le domande yes_no e choice possono escludere", q.Code))
+			}
+			q.Options = nil
+		default:
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda %s: answer_type ammessi %s", q.Code, strings.Join(models.AnswerTypes, ", ")))
+		}
+
+		if q.DeferralReasonID != nil {
+			if len(q.DisqualifyingAnswers) == 0 {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda %s: deferral_reason_id richiede disqualifying_answers", q.Code))
+			}
+			if reason, err := tx.GetDeferralReason(*q.DeferralReasonID); err != nil || !reason.IsActive {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda %s: motivo di sospensione non valido", q.Code))
+			}
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// currentQuestionnaire - Ultima versione pubblicata, nil se non ce n'è
+func currentQuestionnaire(store database.Store) (*models.Questionnaire, error) {
+	questionnaires, err := store.ListQuestionnaires()
+	if err != nil {
+		return nil, err
+	}
+	var current *models.Questionnaire
+	for i := range questionnaires {
+		q := &questionnaires[i]
+		if q.PublishedAt != nil && (current == nil || q.Version > current.Version) {
+			current = q
+		}
+	}
+	return current, nil
+}
+
+// GetQuestionnaires - Tutte le versioni del questionario, dalla più recente (Admin)
+func GetQuestionnaires(c *gin.Context) {
+	questionnaires, err := database.DB.ListQuestionnaires()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load questionnaires"})
+		return
+	}
+	sort.Slice(questionnaires, func(i, j int) bool { return questionnaires[i].Version > questionnaires[j].Version })
+	c.JSON(http.StatusOK, questionnaires)
+}
+
+// GetCurrentQuestionnaire - Versione in uso, da compilare prima della donazione
+func GetCurrentQuestionnaire(c *gin.Context) {
+	questionnaire, err := currentQuestionnaire(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load questionnaire"})
+		return
+	}
+	if questionnaire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nessun questionario pubblicato"})
+		return
+	}
+	c.JSON(http.StatusOK, questionnaire)
+}
+
+// CreateQuestionnaire - Nuova versione in bozza, con numero successivo all'ultima (Admin)
+func CreateQuestionnaire(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+	var questionnaire models.Questionnaire
+	if err := c.ShouldBindJSON(&questionnaire); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	questionnaire.ID = 0
+	questionnaire.Title = strings.TrimSpace(questionnaire.Title)
+	questionnaire.CreatedBy = adminID.(uint)
+	questionnaire.PublishedAt, questionnaire.PublishedBy = nil, nil
+	questionnaire.CreatedAt = time.Now()
+	questionnaire.UpdatedAt = time.Now()
+
+	err := database.DB.WithTx(func(tx database.Store) error {
+		if err := validateQuestions(tx, questionnaire.Questions); err != nil {
+			return err
+		}
+		questionnaires, err := tx.ListQuestionnaires()
+		if err != nil {
+			return err
+		}
+		questionnaire.Version = 1
+		for _, q := range questionnaires {
+			if q.Version >= questionnaire.Version {
+				questionnaire.Version = q.Version + 1
+			}
+		}
+		return tx.CreateQuestionnaire(&questionnaire)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create questionnaire")
+		return
+	}
+	c.JSON(http.StatusCreated, questionnaire)
+}
+
+// UpdateQuestionnaire - Modifica titolo e domande di una bozza (Admin)
+func UpdateQuestionnaire(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var req struct {
+		Title     *string             `json:"title"`
+		Questions models.QuestionList `json:"questions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var questionnaire *models.Questionnaire
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		questionnaire, err = tx.GetQuestionnaire(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		if questionnaire.PublishedAt != nil {
+			return newAPIError(http.StatusConflict, "Una versione pubblicata non si modifica: crea una nuova versione")
+		}
+		if req.Title != nil {
+			questionnaire.Title = strings.TrimSpace(*req.Title)
+		}
+		if req.Questions != nil {
+			if err := validateQuestions(tx, req.Questions); err != nil {
+				return err
+			}
+			questionnaire.Questions = req.Questions
+		}
+		questionnaire.UpdatedAt = time.Now()
+		return tx.UpdateQuestionnaire(questionnaire)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to update questionnaire")
+		return
+	}
+	c.JSON(http.StatusOK, questionnaire)
+}
+
+// PublishQuestionnaire - Pubblica una bozza: diventa la versione in uso se è la più recente (Admin)
+func PublishQuestionnaire(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	adminID, _ := c.Get("user_id")
+
+	var questionnaire *models.Questionnaire
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		questionnaire, err = tx.GetQuestionnaire(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		if questionnaire.PublishedAt != nil {
+			return newAPIError(http.StatusConflict, "La versione è già pubblicata")
+		}
+		// Le domande vanno ricontrollate: un motivo collegato può essere stato disattivato
+		if err := validateQuestions(tx, questionnaire.Questions); err != nil {
+			return err
+		}
+		now := time.Now()
+		publishedBy := adminID.(uint)
+		questionnaire.PublishedAt = &now
+		questionnaire.PublishedBy = &publishedBy
+		questionnaire.UpdatedAt = now
+		return tx.UpdateQuestionnaire(questionnaire)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to publish questionnaire")
+		return
+	}
+	c.JSON(http.StatusOK, questionnaire)
+}
+
+// parseAnswer controlla una risposta rispetto al tipo della domanda
+func parseAnswer(q models.Question, value string) error {
+	switch q.AnswerType {
+	case models.AnswerTypeYesNo:
+		if value != "yes" && value != "no" {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("%s: rispondi yes o no", q.Code))
+		}
+	case models.AnswerTypeChoice:
+		if !containsString(q.Options, value) {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("%s: valori ammessi %s", q.Code, strings.Join(q.Options, ", ")))
+		}
+	case models.AnswerTypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("%s: indica un numero", q.Code))
+		}
+	}
+	return nil
+}
+
+// suggestedSuspension - Sospensione in attesa di revisione per le risposte
+// a rischio: se le domande indicano motivi del catalogo si propone il più
+// restrittivo (permanente, altrimenti la durata più lunga); senza motivo la
+// durata è decisa da chi la revisiona
+func suggestedSuspension(tx database.Store, questionnaire *models.Questionnaire, answers models.AnswerList, donorID uint) (*models.Suspension, error) {
+	now := time.Now()
+	suspension := &models.Suspension{
+		DonorID:       donorID,
+		StartDate:     dateOnly(now),
+		CreatedBy:     donorID,
+		PendingReview: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	var texts []string
+	var chosen *models.DeferralReason
+	var chosenEnd time.Time
+	for _, answer := range answers {
+		if !answer.Disqualifying {
+			continue
+		}
+		for _, q := range questionnaire.Questions {
+			if q.Code != answer.Code {
+				continue
+			}
+			texts = append(texts, fmt.Sprintf("%s (%s)", q.Text, answer.Value))
+			if q.DeferralReasonID == nil {
+				continue
+			}
+			reason, err := tx.GetDeferralReason(*q.DeferralReasonID)
+			if err != nil || !reason.IsActive {
+				continue
+			}
+			end := suspension.StartDate.AddDate(0, reason.DefaultDurationMonths, reason.DefaultDurationWeeks*7+reason.DefaultDurationDays)
+			if chosen == nil || (reason.Permanent && !chosen.Permanent) || (!chosen.Permanent && end.After(chosenEnd)) {
+				chosen, chosenEnd = reason, end
+			}
+		}
+	}
+	suspension.Reason = "Questionario anamnestico: " + strings.Join(texts, "; ")
+
+	if chosen != nil {
+		suspension.ReasonID = &chosen.ID
+		suspension.Permanent = chosen.Permanent
+		suspension.DurationMonths = chosen.DefaultDurationMonths
+		suspension.DurationWeeks = chosen.DefaultDurationWeeks
+		suspension.DurationDays = chosen.DefaultDurationDays
+		if err := setSuspensionEnd(suspension); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.CreateSuspension(suspension); err != nil {
+		return nil, err
+	}
+	return suspension, nil
+}
+
+// SubmitQuestionnaire - Il donatore compila il questionario per un proprio
+// appuntamento confermato. Le risposte a rischio creano una sospensione in
+// attesa di revisione e avvisano gli admin.
+func SubmitQuestionnaire(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, _ := c.Get("user_id")
+	var req struct {
+		Answers []struct {
+			Code  string `json:"code"`
+			Value string `json:"value"`
+		} `json:"answers"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response models.QuestionnaireResponse
+	err := database.DB.WithTx(func(tx database.Store) error {
+		appointment, err := tx.GetAppointment(uint(id))
+		if err != nil || appointment.DonorID != userID.(uint) {
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		if appointment.Status != models.AppointmentStatusConfirmed {
+			return newAPIError(http.StatusConflict, "Il questionario si compila per un appuntamento confermato")
+		}
+		existing, err := tx.ListQuestionnaireResponsesByAppointment(appointment.ID)
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return newAPIError(http.StatusConflict, "Il questionario per questo appuntamento è già stato inviato")
+		}
+		questionnaire, err := currentQuestionnaire(tx)
+		if err != nil {
+			return err
+		}
+		if questionnaire == nil {
+			return newAPIError(http.StatusConflict, "Nessun questionario pubblicato")
+		}
+
+		questions := map[string]bool{}
+		for _, q := range questionnaire.Questions {
+			questions[q.Code] = true
+		}
+		given := map[string]string{}
+		for _, answer := range req.Answers {
+			code := strings.ToLower(strings.TrimSpace(answer.Code))
+			if !questions[code] {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Domanda sconosciuta: %s", code))
+			}
+			if _, ok := given[code]; ok {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("%s: risposta ripetuta", code))
+			}
+			given[code] = strings.TrimSpace(answer.Value)
+		}
+
+		response = models.QuestionnaireResponse{
+			CreatedAt:       time.Now(),
+			AppointmentID:   appointment.ID,
+			DonorID:         appointment.DonorID,
+			QuestionnaireID: questionnaire.ID,
+			Version:         questionnaire.Version,
+			Answers:         models.AnswerList{},
+		}
+		for _, q := range questionnaire.Questions {
+			value, ok := given[q.Code]
+			if !ok || value == "" {
+				if q.Required {
+					return newAPIError(http.StatusBadRequest, fmt.Sprintf("Rispondi alla domanda %s: %s", q.Code, q.Text))
+				}
+				continue
+			}
+			if err := parseAnswer(q, value); err != nil {
+				return err
+			}
+			answer := models.Answer{Code: q.Code, Value: value, Disqualifying: q.Disqualifies(value)}
+			response.Disqualified = response.Disqualified || answer.Disqualifying
+			response.Answers = append(response.Answers, answer)
+		}
+
+		if response.Disqualified {
+			suspension, err := suggestedSuspension(tx, questionnaire, response.Answers, appointment.DonorID)
+			if err != nil {
+				return err
+			}
+			response.SuspensionID = &suspension.ID
+			message := fmt.Sprintf("Questionario con risposte a rischio per l'appuntamento #%d: sospensione #%d da revisionare", appointment.ID, suspension.ID)
+			if err := notifyAdmins(tx, models.AdminNotificationQuestionnaireFlagged, message, &appointment.DonorID, &appointment.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.CreateQuestionnaireResponse(&response); err != nil {
+			return err
+		}
+		return logAppointmentEvent(tx, *appointment, appointment, userID.(uint), models.AppointmentActionQuestionnaire, "")
+	})
+	if err != nil {
+		respondError(c, err, "Failed to submit questionnaire")
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// appointmentQuestionnaire risponde con il questionario compilato per l'appuntamento
+func appointmentQuestionnaire(c *gin.Context, appointmentID uint) {
+	responses, err := database.DB.ListQuestionnaireResponsesByAppointment(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load questionnaire"})
+		return
+	}
+	if len(responses) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Questionario non ancora compilato"})
+		return
+	}
+	c.JSON(http.StatusOK, responses[0])
+}
+
+// GetAppointmentQuestionnaire - Questionario compilato per un appuntamento (Admin)
+func GetAppointmentQuestionnaire(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	appointmentQuestionnaire(c, uint(id))
+}
+
+// GetMyQuestionnaire - Questionario compilato per un proprio appuntamento
+func GetMyQuestionnaire(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, _ := c.Get("user_id")
+	appointment, err := database.DB.GetAppointment(uint(id))
+	if err != nil || appointment.DonorID != userID.(uint) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	appointmentQuestionnaire(c, appointment.ID)
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Modalità del richiamo (RECALL_MODE)
+const (
+	recallModeInvite  = "invite"  // messaggio che invita a prenotare
+	recallModePropose = "propose" // proposta di date creata automaticamente
+	recallModeOff     = "off"
+)
+
+// Attese di default dopo l'invito e dopo ogni sollecito
+const defaultRecallFollowUpDays = "7,14,30"
+
+// recallMode - Modalità del richiamo dei donatori tornati idonei
+// (RECALL_MODE: invite, propose oppure off; default invite)
+func recallMode() (string, error) {
+	switch mode := os.Getenv("RECALL_MODE"); mode {
+	case "":
+		return recallModeInvite, nil
+	case recallModeInvite, recallModePropose, recallModeOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("RECALL_MODE: %q non valido (invite, propose, off)", mode)
+	}
+}
+
+// recallFollowUpDays - Giorni di attesa dopo ogni messaggio del richiamo
+// (RECALL_FOLLOW_UP_DAYS, default "7,14,30"): dopo l'invito e dopo ogni
+// sollecito; trascorsa l'ultima attesa senza prenotazione il richiamo si chiude
+func recallFollowUpDays() ([]int, error) {
+	days, err := envDays("RECALL_FOLLOW_UP_DAYS", defaultRecallFollowUpDays)
+	if err != nil {
+		return nil, err
+	}
+	if len(days) == 0 {
+		return nil, errors.New("RECALL_FOLLOW_UP_DAYS: indica almeno un'attesa (per disattivare il richiamo usa RECALL_MODE=off)")
+	}
+	for _, d := range days {
+		if d == 0 {
+			return nil, errors.New("RECALL_FOLLOW_UP_DAYS: le attese devono essere di almeno un giorno")
+		}
+	}
+	return days, nil
+}
+
+// recallState - Situazione del donatore rilevante per il richiamo
+type recallState struct {
+	record       *donorRecord
+	eligibility  eligibility
+	due          *time.Time          // next_due_date (sangue intero)
+	booked       *models.Appointment // appuntamento confermato da oggi in poi
+	pending      bool                // proposta in attesa di risposta
+	lastDonation *time.Time
+}
+
+func loadRecallState(tx database.Store, user models.User, today time.Time) (*recallState, error) {
+	record, err := loadDonorRecord(tx, user)
+	if err != nil {
+		return nil, err
+	}
+	state := &recallState{
+		record:      record,
+		eligibility: record.evaluate(models.DonationTypeWholeBlood, today),
+		due:         record.nextDue(models.DonationTypeWholeBlood),
+	}
+	if n := len(record.donations); n > 0 {
+		state.lastDonation = &record.donations[n-1].DonationDate
+	}
+
+	appointments, err := tx.ListAppointmentsByDonor(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range appointments {
+		appointment := &appointments[i]
+		switch appointment.Status {
+		case models.AppointmentStatusPending:
+			state.pending = true
+		case models.AppointmentStatusConfirmed:
+			if appointment.ConfirmedDate != nil && !dateOnly(*appointment.ConfirmedDate).Before(today) {
+				state.booked = appointment
+			}
+		}
+	}
+	return state, nil
+}
+
+// closeReason - Motivo per cui un richiamo attivo va chiuso, con lo stato
+// finale; "" se il richiamo prosegue
+func (s *recallState) closeReason(user models.User, recall *models.Recall) (string, string) {
+	switch {
+	case s.booked != nil:
+		return models.RecallStatusBooked, "appuntamento confermato per il " + s.booked.ConfirmedDate.Format("2006-01-02")
+	case s.lastDonation != nil && !s.lastDonation.Before(dateOnly(recall.CreatedAt)):
+		return models.RecallStatusBooked, "donazione registrata il " + s.lastDonation.Format("2006-01-02")
+	case !user.IsActive:
+		return models.RecallStatusStopped, "donatore disattivato"
+	case !s.eligibility.Eligible:
+		return models.RecallStatusStopped, "donatore non idoneo: " + s.eligibility.Reason
+	}
+	return "", ""
+}
+
+// recallRun - Esito di un'esecuzione del richiamo
+type recallRun struct {
+	Opened    int
+	FollowUps int
+	Closed    int
+}
+
+// RunRecalls richiama i donatori arrivati alla scadenza (next_due_date) senza
+// appuntamenti attivi: apre un richiamo con il primo messaggio e invia i
+// solleciti secondo RECALL_FOLLOW_UP_DAYS. Mentre c'è una proposta in attesa
+// di risposta non parte nessun sollecito. Il richiamo si chiude quando il
+// donatore prenota o dona, non è più idoneo o attivo, oppure dopo l'ultima
+// attesa senza risposta: in questo caso gli admin vengono avvisati.
+func RunRecalls(now time.Time) (recallRun, error) {
+	var run recallRun
+	mode, err := recallMode()
+	if err != nil || mode == recallModeOff {
+		return run, err
+	}
+	waits, err := recallFollowUpDays()
+	if err != nil {
+		return run, err
+	}
+	today := dateOnly(now)
+
+	err = database.DB.WithTx(func(tx database.Store) error {
+		run = recallRun{}
+		users, err := tx.ListUsers()
+		if err != nil {
+			return err
+		}
+		recalls, err := tx.ListRecalls()
+		if err != nil {
+			return err
+		}
+		// Un richiamo attivo per donatore; ogni scadenza si richiama una volta sola
+		active := map[uint]*models.Recall{}
+		recalled := map[string]bool{}
+		for i := range recalls {
+			recall := &recalls[i]
+			recalled[recallKey(recall.DonorID, recall.DueDate)] = true
+			if recall.Status == models.RecallStatusActive {
+				active[recall.DonorID] = recall
+			}
+		}
+
+		for _, user := range users {
+			state, err := loadRecallState(tx, user, today)
+			if err != nil {
+				return err
+			}
+
+			recall := active[user.ID]
+			if recall == nil {
+				if !user.IsActive || len(state.record.donations) == 0 || state.due == nil || state.due.After(today) ||
+					!state.eligibility.Eligible || state.pending || state.booked != nil || recalled[recallKey(user.ID, *state.due)] {
+					continue
+				}
+				recall = &models.Recall{
+					CreatedAt: now,
+					UpdatedAt: now,
+					DonorID:   user.ID,
+					DueDate:   *state.due,
+					Status:    models.RecallStatusActive,
+				}
+				if err := tx.CreateRecall(recall); err != nil {
+					return err
+				}
+				if err := sendRecall(tx, recall, user, mode, waits, now); err != nil {
+					return err
+				}
+				run.Opened++
+				continue
+			}
+
+			if status, reason := state.closeReason(user, recall); status != "" {
+				if status == models.RecallStatusBooked && state.booked != nil {
+					recall.AppointmentID = &state.booked.ID
+				}
+				if err := closeRecall(tx, recall, status, reason, now); err != nil {
+					return err
+				}
+				run.Closed++
+				continue
+			}
+			if state.pending || (recall.NextSendAt != nil && now.Before(*recall.NextSendAt)) {
+				continue
+			}
+
+			if recall.Messages >= len(waits) {
+				reason := fmt.Sprintf("nessuna prenotazione dopo %d messaggi", recall.Messages)
+				if err := closeRecall(tx, recall, models.RecallStatusExhausted, reason, now); err != nil {
+					return err
+				}
+				message := fmt.Sprintf("%s %s non ha prenotato dopo %d messaggi di richiamo: contattalo direttamente", user.FirstName, user.LastName, recall.Messages)
+				donorID := user.ID
+				if err := notifyAdmins(tx, models.AdminNotificationRecallExhausted, message, &donorID, nil); err != nil {
+					return err
+				}
+				run.Closed++
+				continue
+			}
+			if err := sendRecall(tx, recall, user, mode, waits, now); err != nil {
+				return err
+			}
+			run.FollowUps++
+		}
+		return nil
+	})
+	return run, err
+}
+
+func recallKey(donorID uint, due time.Time) string {
+	return fmt.Sprintf("%d/%s", donorID, due.Format("2006-01-02"))
+}
+
+// sendRecall invia il messaggio successivo del richiamo: in modalità
+// propose una proposta di date (con la sua notifica), altrimenti, o se non ci
+// sono date da proporre, un invito a prenotare
+func sendRecall(tx database.Store, recall *models.Recall, user models.User, mode string, waits []int, now time.Time) error {
+	invite := true
+	if mode == recallModePropose {
+		appointment, err := createProposal(tx, user.ID, models.DonationTypeWholeBlood, [3]*time.Time{}, false, 0)
+		var apiErr *apiError
+		switch {
+		case err == nil:
+			recall.AppointmentID = &appointment.ID
+			invite = false
+		case errors.As(err, &apiErr):
+			log.Printf("Richiamo donatore %d: proposta non creata (%s), invio l'invito", user.ID, apiErr.Message)
+		default:
+			return err
+		}
+	}
+	if invite {
+		data := messageData{Date: &recall.DueDate, FollowUp: recall.Messages > 0}
+		if err := notifyUser(tx, models.NotificationKindRecall, user.ID, nil, data); err != nil {
+			return err
+		}
+	}
+
+	next := now.AddDate(0, 0, waits[recall.Messages])
+	recall.Messages++
+	recall.LastSentAt = &now
+	recall.NextSendAt = &next
+	recall.UpdatedAt = now
+	return tx.UpdateRecall(recall)
+}
+
+// closeRecall chiude il richiamo con lo stato e il motivo indicati
+func closeRecall(tx database.Store, recall *models.Recall, status, reason string, now time.Time) error {
+	recall.Status = status
+	recall.CloseReason = reason
+	recall.ClosedAt = &now
+	recall.NextSendAt = nil
+	recall.UpdatedAt = now
+	return tx.UpdateRecall(recall)
+}
+
+// GetRecalls - Richiami dei donatori, dal più recente (?status=, ?donor_id=)
+func GetRecalls(c *gin.Context) {
+	var recalls []models.Recall
+	var err error
+	if donorID := c.Query("donor_id"); donorID != "" {
+		id, parseErr := strconv.ParseUint(donorID, 10, 32)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "donor_id non valido"})
+			return
+		}
+		recalls, err = database.DB.ListRecallsByDonor(uint(id))
+	} else {
+		recalls, err = database.DB.ListRecalls()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recalls"})
+		return
+	}
+
+	status := c.Query("status")
+	result := []models.Recall{}
+	for _, r := range recalls {
+		if status != "" && r.Status != status {
+			continue
+		}
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID > result[j].ID })
+
+	c.JSON(http.StatusOK, result)
+}
+
+// StopRecall - Ferma un richiamo attivo: il donatore non riceve altri
+// solleciti per questa scadenza (Admin)
+func StopRecall(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	adminID, _ := c.Get("user_id")
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// Il motivo è facoltativo: il body può mancare
+	_ = c.ShouldBindJSON(&req)
+
+	var recall *models.Recall
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		recall, err = tx.GetRecall(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Richiamo non trovato")
+		}
+		if recall.Status != models.RecallStatusActive {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("Il richiamo non è attivo (stato attuale: %s)", recall.Status))
+		}
+
+		reason := strings.TrimSpace(req.Reason)
+		if reason == "" {
+			reason = "fermato da un amministratore"
+		}
+		stoppedBy := adminID.(uint)
+		recall.StoppedBy = &stoppedBy
+		return closeRecall(tx, recall, models.RecallStatusStopped, reason, time.Now())
+	})
+	if err != nil {
+		respondError(c, err, "Failed to stop recall")
+		return
+	}
+
+	c.JSON(http.StatusOK, recall)
+}
@@ -32,22 +32,6 @@ func SubmitRegistrationRequest(c *gin.Context) {
 		return
 	}
 
-	// Verifica se esiste già una richiesta pending per questa email
-	for _, r := range database.DB.RegistrationRequests {
-		if r.Email == req.Email && r.Status == models.RegistrationRequestStatusPending {
-			c.JSON(http.StatusConflict, gin.H{"error": "Richiesta già inviata"})
-			return
-		}
-	}
-
-	// Verifica se l'utente esiste già
-	for _, u := range database.DB.Users {
-		if u.Email == req.Email {
-			c.JSON(http.StatusConflict, gin.H{"error": "Utente già registrato"})
-			return
-		}
-	}
-
 	// Parse birth date
 	var birthDate *time.Time
 	if req.BirthDate != "" {
@@ -59,7 +43,6 @@ func SubmitRegistrationRequest(c *gin.Context) {
 
 	// Crea nuova richiesta
 	newRequest := models.RegistrationRequest{
-		ID:          database.DB.NextRegistrationRequestID(),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 		Email:       req.Email,
@@ -72,8 +55,29 @@ func SubmitRegistrationRequest(c *gin.Context) {
 		Status:      models.RegistrationRequestStatusPending,
 	}
 
-	database.DB.RegistrationRequests = append(database.DB.RegistrationRequests, newRequest)
-	database.DB.Save()
+	err := database.DB.WithTx(func(tx database.Store) error {
+		// Verifica se esiste già una richiesta pending per questa email
+		requests, err := tx.ListRegistrationRequests()
+		if err != nil {
+			return err
+		}
+		for _, r := range requests {
+			if r.Email == req.Email && r.Status == models.RegistrationRequestStatusPending {
+				return newAPIError(http.StatusConflict, "Richiesta già inviata")
+			}
+		}
+
+		// Verifica se l'utente esiste già
+		if _, err := tx.FindUserByEmail(req.Email); err == nil {
+			return newAPIError(http.StatusConflict, "Utente già registrato")
+		}
+
+		return tx.CreateRegistrationRequest(&newRequest)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create registration request")
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Richiesta inviata con successo",
@@ -85,8 +89,14 @@ func SubmitRegistrationRequest(c *gin.Context) {
 func GetRegistrationRequests(c *gin.Context) {
 	status := c.Query("status")
 
+	all, err := database.DB.ListRegistrationRequests()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load registration requests"})
+		return
+	}
+
 	var requests []models.RegistrationRequest
-	for _, req := range database.DB.RegistrationRequests {
+	for _, req := range all {
 		if status == "" || string(req.Status) == status {
 			requests = append(requests, req)
 		}
@@ -97,8 +107,14 @@ func GetRegistrationRequests(c *gin.Context) {
 
 // GetPendingRequestsCount - Conta richieste pendenti
 func GetPendingRequestsCount(c *gin.Context) {
+	requests, err := database.DB.ListRegistrationRequests()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load registration requests"})
+		return
+	}
+
 	count := 0
-	for _, req := range database.DB.RegistrationRequests {
+	for _, req := range requests {
 		if req.Status == models.RegistrationRequestStatusPending {
 			count++
 		}
@@ -125,134 +141,128 @@ func ApproveRegistrationRequest(c *gin.Context) {
 	}
 	c.ShouldBindJSON(&req)
 
-	// Trova la richiesta
-	var request *models.RegistrationRequest
-	var requestIndex int
-	for i, r := range database.DB.RegistrationRequests {
-		if r.ID == uint(id) {
-			request = &database.DB.RegistrationRequests[i]
-			requestIndex = i
-			break
+	var newUser models.User
+	err := database.DB.WithTx(func(tx database.Store) error {
+		// Trova la richiesta
+		request, err := tx.GetRegistrationRequest(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Richiesta non trovata")
 		}
-	}
 
-	if request == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Richiesta non trovata"})
-		return
-	}
-
-	if request.Status != models.RegistrationRequestStatusPending {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Richiesta già processata"})
-		return
-	}
+		if request.Status != models.RegistrationRequestStatusPending {
+			return newAPIError(http.StatusBadRequest, "Richiesta già processata")
+		}
 
-	// Verifica che non esista già un utente con questa email o GoogleID
-	for _, u := range database.DB.Users {
-		if u.Email == request.Email {
-			c.JSON(http.StatusConflict, gin.H{"error": "Esiste già un utente con questa email"})
-			return
+		// Verifica che non esista già un utente con questa email o GoogleID
+		if _, err := tx.FindUserByEmail(request.Email); err == nil {
+			return newAPIError(http.StatusConflict, "Esiste già un utente con questa email")
 		}
-		if u.GoogleID == request.GoogleID {
-			c.JSON(http.StatusConflict, gin.H{"error": "Esiste già un utente con questo account Google"})
-			return
+		if _, err := tx.FindUserByGoogleID(request.GoogleID); err == nil {
+			return newAPIError(http.StatusConflict, "Esiste già un utente con questo account Google")
 		}
-	}
 
-	// Usa i dati dalla richiesta, o quelli modificati dall'admin
-	firstName := request.FirstName
-	if req.FirstName != "" {
-		firstName = req.FirstName
-	}
-	lastName := request.LastName
-	if req.LastName != "" {
-		lastName = req.LastName
-	}
-	phoneNumber := request.PhoneNumber
-	if req.PhoneNumber != "" {
-		phoneNumber = req.PhoneNumber
-	}
-	gender := request.Gender
-	if req.Gender != "" {
-		gender = req.Gender
-	}
+		// Usa i dati dalla richiesta, o quelli modificati dall'admin
+		firstName := request.FirstName
+		if req.FirstName != "" {
+			firstName = req.FirstName
+		}
+		lastName := request.LastName
+		if req.LastName != "" {
+			lastName = req.LastName
+		}
+		phoneNumber := request.PhoneNumber
+		if req.PhoneNumber != "" {
+			phoneNumber = req.PhoneNumber
+		}
+		gender := request.Gender
+		if req.Gender != "" {
+			gender = req.Gender
+		}
 
-	// Parse birth date - usa quella dalla richiesta o quella modificata
-	birthDate := request.BirthDate
-	if req.BirthDate != "" {
-		parsed, err := time.Parse("2006-01-02", req.BirthDate)
-		if err == nil {
-			birthDate = &parsed
+		// Parse birth date - usa quella dalla richiesta o quella modificata
+		birthDate := request.BirthDate
+		if req.BirthDate != "" {
+			parsed, err := time.Parse("2006-01-02", req.BirthDate)
+			if err == nil {
+				birthDate = &parsed
+			}
 		}
-	}
 
-	// Parse next appointment date
-	var nextAppointmentDate *time.Time
-	if req.NextAppointmentDate != "" {
-		parsed, err := time.Parse("2006-01-02", req.NextAppointmentDate)
-		if err == nil {
-			nextAppointmentDate = &parsed
+		// Parse next appointment date
+		var nextAppointmentDate *time.Time
+		if req.NextAppointmentDate != "" {
+			parsed, err := time.Parse("2006-01-02", req.NextAppointmentDate)
+			if err == nil {
+				nextAppointmentDate = &parsed
+			}
 		}
-	}
 
-	// Determina is_active e is_admin
-	isActive := true
-	if req.IsActive != nil {
-		isActive = *req.IsActive
-	}
-	isAdmin := false
-	if req.IsAdmin != nil {
-		isAdmin = *req.IsAdmin
-	}
+		// Determina is_active e is_admin
+		isActive := true
+		if req.IsActive != nil {
+			isActive = *req.IsActive
+		}
+		isAdmin := false
+		if req.IsAdmin != nil {
+			isAdmin = *req.IsAdmin
+		}
 
-	// Crea nuovo utente
-	newUser := models.User{
-		ID:                  database.DB.NextUserID(),
-		CreatedAt:           time.Now(),
-		UpdatedAt:           time.Now(),
-		Email:               request.Email,
-		GoogleID:            request.GoogleID,
-		FirstName:           firstName,
-		LastName:            lastName,
-		PhoneNumber:         phoneNumber,
-		Gender:              models.Gender(gender),
-		BloodType:           req.BloodType,
-		BirthDate:           birthDate,
-		NextAppointmentDate: nextAppointmentDate,
-		IsAdmin:             isAdmin,
-		IsActive:            isActive,
-		IsSuspended:         false,
-	}
+		// Crea nuovo utente
+		newUser = models.User{
+			CreatedAt:           time.Now(),
+			UpdatedAt:           time.Now(),
+			Email:               request.Email,
+			GoogleID:            request.GoogleID,
+			FirstName:           firstName,
+			LastName:            lastName,
+			PhoneNumber:         phoneNumber,
+			Gender:              models.Gender(gender),
+			BloodType:           req.BloodType,
+			BirthDate:           birthDate,
+			NextAppointmentDate: nextAppointmentDate,
+			IsAdmin:             isAdmin,
+			IsActive:            isActive,
+		}
 
-	database.DB.Users = append(database.DB.Users, newUser)
+		if err := tx.CreateUser(&newUser); err != nil {
+			return err
+		}
 
-	// Se è stata specificata una data di ultima donazione, crea una donazione
-	if req.LastDonationDate != "" {
-		parsed, err := time.Parse("2006-01-02", req.LastDonationDate)
-		if err == nil {
-			donation := models.Donation{
-				ID:           database.DB.NextDonationID(),
-				CreatedAt:    time.Now(),
-				UpdatedAt:    time.Now(),
-				DonorID:      newUser.ID,
-				DonationDate: parsed,
-				Status:       models.DonationStatusCompleted,
-				Notes:        "Donazione iniziale importata alla registrazione",
+		// Se è stata specificata una data di ultima donazione, crea una donazione
+		if req.LastDonationDate != "" {
+			parsed, err := time.Parse("2006-01-02", req.LastDonationDate)
+			if err == nil {
+				donation := models.Donation{
+					CreatedAt:    time.Now(),
+					UpdatedAt:    time.Now(),
+					DonorID:      newUser.ID,
+					DonationDate: parsed,
+					Status:       models.DonationStatusCompleted,
+					Notes:        "Donazione iniziale importata alla registrazione",
+				}
+				if err := tx.CreateDonation(&donation); err != nil {
+					return err
+				}
 			}
-			database.DB.Donations = append(database.DB.Donations, donation)
 		}
-	}
-
-	database.DB.Users = append(database.DB.Users, newUser)
 
-	// Aggiorna richiesta
-	adminID := c.GetUint("userID")
-	now := time.Now()
-	database.DB.RegistrationRequests[requestIndex].Status = models.RegistrationRequestStatusApproved
-	database.DB.RegistrationRequests[requestIndex].ProcessedBy = &adminID
-	database.DB.RegistrationRequests[requestIndex].ProcessedAt = &now
-	database.DB.RegistrationRequests[requestIndex].UpdatedAt = now
+		// Aggiorna richiesta
+		adminID := c.GetUint("userID")
+		now := time.Now()
+		request.Status = models.RegistrationRequestStatusApproved
+		request.ProcessedBy = &adminID
+		request.ProcessedAt = &now
+		request.UpdatedAt = now
 
-	database.DB.Save()
+		if err := tx.UpdateRegistrationRequest(request); err != nil {
+			return err
+		}
+		return queueEmail(tx, models.NotificationKindRegistrationApproved, newUser.Email, &newUser.ID, nil, messageData{FirstName: newUser.FirstName})
+	})
+	if err != nil {
+		respondError(c, err, "Failed to approve registration request")
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Utente creato con successo",
@@ -272,52 +282,44 @@ func AssociateRegistrationRequest(c *gin.Context) {
 		return
 	}
 
-	// Trova la richiesta
-	var requestIndex int = -1
-	for i, r := range database.DB.RegistrationRequests {
-		if r.ID == uint(id) {
-			requestIndex = i
-			break
+	err := database.DB.WithTx(func(tx database.Store) error {
+		// Trova la richiesta
+		request, err := tx.GetRegistrationRequest(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Richiesta non trovata")
 		}
-	}
-
-	if requestIndex == -1 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Richiesta non trovata"})
-		return
-	}
 
-	if database.DB.RegistrationRequests[requestIndex].Status != models.RegistrationRequestStatusPending {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Richiesta già processata"})
-		return
-	}
+		if request.Status != models.RegistrationRequestStatusPending {
+			return newAPIError(http.StatusBadRequest, "Richiesta già processata")
+		}
 
-	// Trova l'utente e aggiorna GoogleID
-	userFound := false
-	for i := range database.DB.Users {
-		if database.DB.Users[i].ID == req.UserID {
-			database.DB.Users[i].GoogleID = database.DB.RegistrationRequests[requestIndex].GoogleID
-			database.DB.Users[i].UpdatedAt = time.Now()
-			userFound = true
-			break
+		// Trova l'utente e aggiorna GoogleID
+		user, err := tx.GetUser(req.UserID)
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Utente non trovato")
 		}
-	}
+		user.GoogleID = request.GoogleID
+		user.UpdatedAt = time.Now()
+		if err := tx.UpdateUser(user); err != nil {
+			return err
+		}
+
+		// Aggiorna richiesta
+		adminID := c.GetUint("userID")
+		now := time.Now()
+		request.Status = models.RegistrationRequestStatusApproved
+		request.AssociatedUserID = &req.UserID
+		request.ProcessedBy = &adminID
+		request.ProcessedAt = &now
+		request.UpdatedAt = now
 
-	if !userFound {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Utente non trovato"})
+		return tx.UpdateRegistrationRequest(request)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to associate registration request")
 		return
 	}
 
-	// Aggiorna richiesta
-	adminID := c.GetUint("userID")
-	now := time.Now()
-	database.DB.RegistrationRequests[requestIndex].Status = models.RegistrationRequestStatusApproved
-	database.DB.RegistrationRequests[requestIndex].AssociatedUserID = &req.UserID
-	database.DB.RegistrationRequests[requestIndex].ProcessedBy = &adminID
-	database.DB.RegistrationRequests[requestIndex].ProcessedAt = &now
-	database.DB.RegistrationRequests[requestIndex].UpdatedAt = now
-
-	database.DB.Save()
-
 	c.JSON(http.StatusOK, gin.H{"message": "Account Google associato all'utente esistente"})
 }
 
@@ -330,36 +332,36 @@ func RejectRegistrationRequest(c *gin.Context) {
 	}
 	c.ShouldBindJSON(&req)
 
-	// Trova la richiesta
-	var requestIndex int = -1
-	for i, r := range database.DB.RegistrationRequests {
-		if r.ID == uint(id) {
-			requestIndex = i
-			break
+	err := database.DB.WithTx(func(tx database.Store) error {
+		// Trova la richiesta
+		request, err := tx.GetRegistrationRequest(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Richiesta non trovata")
 		}
-	}
 
-	if requestIndex == -1 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Richiesta non trovata"})
-		return
-	}
+		if request.Status != models.RegistrationRequestStatusPending {
+			return newAPIError(http.StatusBadRequest, "Richiesta già processata")
+		}
 
-	if database.DB.RegistrationRequests[requestIndex].Status != models.RegistrationRequestStatusPending {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Richiesta già processata"})
+		// Aggiorna richiesta
+		adminID := c.GetUint("userID")
+		now := time.Now()
+		request.Status = models.RegistrationRequestStatusRejected
+		request.ProcessedBy = &adminID
+		request.ProcessedAt = &now
+		request.RejectionNote = req.Note
+		request.UpdatedAt = now
+
+		if err := tx.UpdateRegistrationRequest(request); err != nil {
+			return err
+		}
+		return queueEmail(tx, models.NotificationKindRegistrationRejected, request.Email, nil, nil, messageData{FirstName: request.FirstName, Reason: req.Note})
+	})
+	if err != nil {
+		respondError(c, err, "Failed to reject registration request")
 		return
 	}
 
-	// Aggiorna richiesta
-	adminID := c.GetUint("userID")
-	now := time.Now()
-	database.DB.RegistrationRequests[requestIndex].Status = models.RegistrationRequestStatusRejected
-	database.DB.RegistrationRequests[requestIndex].ProcessedBy = &adminID
-	database.DB.RegistrationRequests[requestIndex].ProcessedAt = &now
-	database.DB.RegistrationRequests[requestIndex].RejectionNote = req.Note
-	database.DB.RegistrationRequests[requestIndex].UpdatedAt = now
-
-	database.DB.Save()
-
 	c.JSON(http.StatusOK, gin.H{"message": "Richiesta rifiutata"})
 }
 
@@ -367,23 +369,10 @@ func RejectRegistrationRequest(c *gin.Context) {
 func DeleteRegistrationRequest(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
-	var newRequests []models.RegistrationRequest
-	found := false
-	for _, r := range database.DB.RegistrationRequests {
-		if r.ID == uint(id) {
-			found = true
-		} else {
-			newRequests = append(newRequests, r)
-		}
-	}
-
-	if !found {
+	if err := database.DB.DeleteRegistrationRequest(uint(id)); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Richiesta non trovata"})
 		return
 	}
 
-	database.DB.RegistrationRequests = newRequests
-	database.DB.Save()
-
 	c.JSON(http.StatusOK, gin.H{"message": "Richiesta eliminata"})
 }
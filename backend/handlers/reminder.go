@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"sort"
+	"time"
+)
+
+// Promemoria di default: 3 giorni e 1 giorno prima dell'appuntamento
+const defaultReminderDays = "3,1"
+
+// appointmentReminderDays - Giorni prima della data confermata in cui inviare
+// un promemoria, dal più lontano (APPOINTMENT_REMINDER_DAYS, es. "3,1";
+// 0 è il giorno stesso, "off" disattiva i promemoria)
+func appointmentReminderDays() ([]int, error) {
+	values, err := envDays("APPOINTMENT_REMINDER_DAYS", defaultReminderDays)
+	if err != nil {
+		return nil, err
+	}
+	var days []int
+	for _, n := range values {
+		if !models.IntList(days).Contains(n) {
+			days = append(days, n)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(days)))
+	return days, nil
+}
+
+// dueReminders - Promemoria non ancora inviati il cui anticipo è già stato
+// raggiunto, con daysLeft giorni all'appuntamento
+func dueReminders(days []int, sent models.IntList, daysLeft int) []int {
+	var due []int
+	for _, d := range days {
+		if daysLeft <= d && !sent.Contains(d) {
+			due = append(due, d)
+		}
+	}
+	return due
+}
+
+// notifyReminder ricorda al donatore l'appuntamento, con le istruzioni per prepararsi
+func notifyReminder(tx database.Store, appointment *models.Appointment, daysLeft int) error {
+	return notifyUser(tx, models.NotificationKindAppointmentReminder, appointment.DonorID, &appointment.ID, messageData{
+		Date:      appointment.ConfirmedDate,
+		Slot:      appointment.ConfirmedSlot,
+		DaysLeft:  daysLeft,
+		Apheresis: appointment.DonationType.OrDefault() != models.DonationTypeWholeBlood,
+	})
+}
+
+// SendAppointmentReminders mette nell'outbox i promemoria degli appuntamenti
+// confermati arrivati a uno degli anticipi di APPOINTMENT_REMINDER_DAYS. Se
+// più promemoria sono dovuti insieme (es. conferma il giorno prima) ne parte
+// uno solo. I promemoria inviati sono segnati sull'appuntamento nello stesso
+// salvataggio, così un riavvio non li ripete.
+func SendAppointmentReminders(now time.Time) (int, error) {
+	days, err := appointmentReminderDays()
+	if err != nil || len(days) == 0 {
+		return 0, err
+	}
+	today := dateOnly(now)
+
+	sent := 0
+	err = database.DB.WithTx(func(tx database.Store) error {
+		sent = 0
+		appointments, err := tx.ListAppointments()
+		if err != nil {
+			return err
+		}
+		for i := range appointments {
+			appointment := &appointments[i]
+			if appointment.Status != models.AppointmentStatusConfirmed || appointment.ConfirmedDate == nil {
+				continue
+			}
+			daysLeft := int(dateOnly(*appointment.ConfirmedDate).Sub(today).Hours() / 24)
+			if daysLeft < 0 {
+				continue
+			}
+			due := dueReminders(days, appointment.RemindersSent, daysLeft)
+			if len(due) == 0 {
+				continue
+			}
+
+			if err := notifyReminder(tx, appointment, daysLeft); err != nil {
+				return err
+			}
+			appointment.RemindersSent = append(appointment.RemindersSent, due...)
+			appointment.UpdatedAt = now
+			if err := tx.UpdateAppointment(appointment); err != nil {
+				return err
+			}
+			sent++
+		}
+		return nil
+	})
+	return sent, err
+}
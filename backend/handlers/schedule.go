@@ -11,7 +11,12 @@ import (
 )
 
 func GetSchedule(c *gin.Context) {
-	c.JSON(http.StatusOK, database.DB.Schedule)
+	schedule, err := database.DB.GetSchedule()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load schedule"})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
 }
 
 func UpdateSchedule(c *gin.Context) {
@@ -21,18 +26,168 @@ func UpdateSchedule(c *gin.Context) {
 		return
 	}
 
-	// Mantieni l'ID e i timestamp
-	schedule.ID = database.DB.Schedule.ID
-	schedule.CreatedAt = database.DB.Schedule.CreatedAt
-	schedule.UpdatedAt = time.Now()
+	err := database.DB.WithTx(func(tx database.Store) error {
+		// Mantieni l'ID e i timestamp
+		current, err := tx.GetSchedule()
+		if err != nil {
+			return err
+		}
+		schedule.ID = current.ID
+		schedule.CreatedAt = current.CreatedAt
+		schedule.UpdatedAt = time.Now()
+
+		return tx.SaveSchedule(&schedule)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to update schedule")
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// Intervallo massimo richiedibile al calendario di disponibilità
+const maxAvailabilityDays = 366
+
+// GetAvailability - Disponibilità giorno per giorno tra from e to (inclusi, formato 2006-01-02).
+// Default: da oggi per 30 giorni.
+func GetAvailability(c *gin.Context) {
+	from, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	if v := c.Query("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato data from non valido"})
+			return
+		}
+		from = parsed
+	}
+	to := from.AddDate(0, 0, 30)
+	if v := c.Query("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato data to non valido"})
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "La data to precede from"})
+		return
+	}
+	if to.Sub(from) > maxAvailabilityDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Intervallo massimo: 366 giorni"})
+		return
+	}
+
+	cal, err := loadCapacityCalendar(database.DB, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load availability"})
+		return
+	}
+
+	withSlots := c.Query("slots") == "true"
+	days := []dayAvailability{}
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		day := cal.day(date)
+		if withSlots && day.Open {
+			day.Slots = cal.slots(date)
+		}
+		days = append(days, day)
+	}
+	c.JSON(http.StatusOK, days)
+}
+
+// GetDaySlots - Slot orari di un giorno con i posti liberi, per scegliere l'orario alla conferma
+func GetDaySlots(c *gin.Context) {
+	date, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato data non valido"})
+		return
+	}
+
+	cal, err := loadCapacityCalendar(database.DB, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load availability"})
+		return
+	}
+	day := cal.day(date)
+	if day.Open {
+		day.Slots = cal.slots(date)
+	}
+	c.JSON(http.StatusOK, day)
+}
+
+func GetTimeSlotRules(c *gin.Context) {
+	rules, err := database.DB.ListTimeSlotRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load time slots"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// SetTimeSlotRule - Crea o sostituisce le fasce orarie di un giorno della settimana
+func SetTimeSlotRule(c *gin.Context) {
+	var rule models.TimeSlotRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if rule.SlotMinutes == 0 {
+		rule.SlotMinutes = 15
+	}
+	if rule.SlotCapacity == 0 {
+		rule.SlotCapacity = 1
+	}
+	if rule.Weekday < 0 || rule.Weekday > 6 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weekday: valori ammessi da 0 (domenica) a 6 (sabato)"})
+		return
+	}
+	if rule.SlotMinutes < 0 || rule.SlotCapacity < 0 || len(rule.Slots()) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fascia oraria non valida: usare HH:MM, con start_time prima di end_time di almeno uno slot"})
+		return
+	}
+
+	err := database.DB.WithTx(func(tx database.Store) error {
+		rules, err := tx.ListTimeSlotRules()
+		if err != nil {
+			return err
+		}
+		rule.UpdatedAt = time.Now()
+		for _, existing := range rules {
+			if existing.Weekday == rule.Weekday {
+				rule.ID = existing.ID
+				rule.CreatedAt = existing.CreatedAt
+				return tx.UpdateTimeSlotRule(&rule)
+			}
+		}
+		rule.ID = 0
+		rule.CreatedAt = time.Now()
+		return tx.CreateTimeSlotRule(&rule)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to save time slots")
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func DeleteTimeSlotRule(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
-	database.DB.Schedule = &schedule
-	database.DB.Save()
-	c.JSON(http.StatusOK, database.DB.Schedule)
+	if err := database.DB.DeleteTimeSlotRule(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Non trovato"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Eliminato"})
 }
 
 func GetExcludedDates(c *gin.Context) {
-	c.JSON(http.StatusOK, database.DB.ExcludedDates)
+	dates, err := database.DB.ListExcludedDates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load excluded dates"})
+		return
+	}
+	c.JSON(http.StatusOK, dates)
 }
 
 func AddExcludedDate(c *gin.Context) {
@@ -51,41 +206,49 @@ func AddExcludedDate(c *gin.Context) {
 		return
 	}
 
-	// Verifica duplicati
-	for _, ed := range database.DB.ExcludedDates {
-		if ed.Date.Format("2006-01-02") == req.Date {
-			c.JSON(http.StatusConflict, gin.H{"error": "Data già esclusa"})
-			return
-		}
-	}
-
 	date := models.ExcludedDate{
-		ID:        database.DB.NextExcludedDateID(),
 		CreatedAt: time.Now(),
 		Date:      parsedDate,
 		Reason:    req.Reason,
 	}
-	database.DB.ExcludedDates = append(database.DB.ExcludedDates, date)
-	database.DB.Save()
+
+	err = database.DB.WithTx(func(tx database.Store) error {
+		// Verifica duplicati
+		excluded, err := tx.ListExcludedDates()
+		if err != nil {
+			return err
+		}
+		for _, ed := range excluded {
+			if ed.Date.Format("2006-01-02") == req.Date {
+				return newAPIError(http.StatusConflict, "Data già esclusa")
+			}
+		}
+		return tx.CreateExcludedDate(&date)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create excluded date")
+		return
+	}
 	c.JSON(http.StatusCreated, date)
 }
 
 func DeleteExcludedDate(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
-	for i, ed := range database.DB.ExcludedDates {
-		if ed.ID == uint(id) {
-			database.DB.ExcludedDates = append(database.DB.ExcludedDates[:i], database.DB.ExcludedDates[i+1:]...)
-			database.DB.Save()
-			c.JSON(http.StatusOK, gin.H{"message": "Eliminato"})
-			return
-		}
+	if err := database.DB.DeleteExcludedDate(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Non trovato"})
+		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "Non trovato"})
+	c.JSON(http.StatusOK, gin.H{"message": "Eliminato"})
 }
 
 func GetSpecialCapacities(c *gin.Context) {
-	c.JSON(http.StatusOK, database.DB.SpecialCapacities)
+	capacities, err := database.DB.ListSpecialCapacities()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load special capacities"})
+		return
+	}
+	c.JSON(http.StatusOK, capacities)
 }
 
 func SetSpecialCapacity(c *gin.Context) {
@@ -94,65 +257,15 @@ func SetSpecialCapacity(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	capacity.ID = 0
 	capacity.CreatedAt = time.Now()
-	database.DB.SpecialCapacities = append(database.DB.SpecialCapacities, capacity)
-	database.DB.Save()
+	if err := database.DB.CreateSpecialCapacity(&capacity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create special capacity"})
+		return
+	}
 	c.JSON(http.StatusCreated, capacity)
 }
 
 func DeleteSpecialCapacity(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
 }
-
-func GetSuspensions(c *gin.Context) {
-	c.JSON(http.StatusOK, database.DB.Suspensions)
-}
-
-func CreateSuspension(c *gin.Context) {
-	adminID, _ := c.Get("user_id")
-	var suspension models.Suspension
-	if err := c.ShouldBindJSON(&suspension); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	suspension.ID = database.DB.NextSuspensionID()
-	suspension.CreatedBy = adminID.(uint)
-	suspension.IsActive = true
-	suspension.EndDate = suspension.StartDate.AddDate(0, suspension.DurationMonths, 0)
-	suspension.CreatedAt = time.Now()
-
-	// Aggiorna stato utente
-	for i, u := range database.DB.Users {
-		if u.ID == suspension.DonorID {
-			database.DB.Users[i].IsSuspended = true
-			break
-		}
-	}
-
-	database.DB.Suspensions = append(database.DB.Suspensions, suspension)
-	database.DB.Save()
-	c.JSON(http.StatusCreated, suspension)
-}
-
-func EndSuspension(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	for i, s := range database.DB.Suspensions {
-		if s.ID == uint(id) {
-			database.DB.Suspensions[i].IsActive = false
-			database.DB.Suspensions[i].EndDate = time.Now()
-
-			// Aggiorna stato utente
-			for j, u := range database.DB.Users {
-				if u.ID == s.DonorID {
-					database.DB.Users[j].IsSuspended = false
-					break
-				}
-			}
-
-			database.DB.Save()
-			c.JSON(http.StatusOK, database.DB.Suspensions[i])
-			return
-		}
-	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
-}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"bloodone/scheduler"
+	"bloodone/sms"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queueSMS compone l'SMS di tipo kind per l'utente e lo mette nell'outbox
+// nella transazione corrente, come queueEmail. Restituisce false senza
+// errore se l'SMS non può partire: canale SMS disattivato, tipo senza
+// modello SMS o numero di telefono non valido.
+func queueSMS(tx database.Store, kind string, user *models.User, appointmentID *uint, data messageData) (bool, error) {
+	if !sms.Enabled() || !sms.Supports(kind) {
+		return false, nil
+	}
+	phone, err := sms.Normalize(user.PhoneNumber)
+	if err != nil {
+		log.Printf("SMS %s per l'utente %d non inviato: %v", kind, user.ID, err)
+		return false, nil
+	}
+	data.URL = frontendBaseURL
+	text, err := sms.Render(kind, data)
+	if err != nil {
+		return false, fmt.Errorf("SMS %s: %w", kind, err)
+	}
+
+	now := time.Now()
+	notification := models.Notification{
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Channel:       models.NotificationChannelSMS,
+		Kind:          kind,
+		Recipient:     phone,
+		Text:          text,
+		Segments:      sms.Segments(text),
+		UserID:        &user.ID,
+		AppointmentID: appointmentID,
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: &now,
+	}
+	if err := tx.CreateNotification(&notification); err != nil {
+		return false, err
+	}
+	scheduler.Wake(outboxJob)
+	return true, nil
+}
+
+// validateNotificationChannel controlla il canale scelto dall'utente: per
+// sms e both serve un numero di telefono utilizzabile
+func validateNotificationChannel(user *models.User) error {
+	switch user.GetNotificationChannel() {
+	case models.NotificationChannelEmail:
+		return nil
+	case models.NotificationChannelSMS, models.NotificationChannelBoth:
+		if _, err := sms.Normalize(user.PhoneNumber); err != nil {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("notification_channel %s: %v", user.NotificationChannel, err))
+		}
+		return nil
+	default:
+		return newAPIError(http.StatusBadRequest, "notification_channel: valori ammessi email, sms, both")
+	}
+}
+
+// smsUsage - Contatori degli SMS in un mese
+type smsUsage struct {
+	Month    string  `json:"month,omitempty"`
+	Sent     int     `json:"sent"`
+	Segments int     `json:"segments"`
+	Cost     float64 `json:"cost"`
+	Failed   int     `json:"failed"` // tentativi esauriti
+}
+
+func (u *smsUsage) add(n models.Notification) {
+	if n.Status == models.NotificationStatusSent {
+		u.Sent++
+		u.Segments += n.Segments
+		u.Cost = math.Round((u.Cost+n.Cost)*10000) / 10000
+	} else {
+		u.Failed++
+	}
+}
+
+// GetSMSUsage - SMS consegnati, segmenti e costo per mese, nel periodo
+// ?from&to (formato 2006-01, default: ultimi 12 mesi). Gli SMS consegnati
+// contano nel mese della consegna, quelli falliti nel mese dell'ultimo
+// tentativo; pending sono quelli ancora in coda.
+func GetSMSUsage(c *gin.Context) {
+	current := time.Now().UTC()
+	to := time.Date(current.Year(), current.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, -11, 0)
+	if v := c.Query("from"); v != "" {
+		parsed, err := time.Parse("2006-01", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato from non valido (2006-01)"})
+			return
+		}
+		from = parsed
+	}
+	if v := c.Query("to"); v != "" {
+		parsed, err := time.Parse("2006-01", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato to non valido (2006-01)"})
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to deve essere successivo a from"})
+		return
+	}
+
+	notifications, err := database.DB.ListNotifications()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notifications"})
+		return
+	}
+
+	months := []*smsUsage{}
+	byMonth := map[string]*smsUsage{}
+	for m := from; !m.After(to); m = m.AddDate(0, 1, 0) {
+		usage := &smsUsage{Month: m.Format("2006-01")}
+		months = append(months, usage)
+		byMonth[usage.Month] = usage
+	}
+
+	total := &smsUsage{}
+	pending := 0
+	for _, n := range notifications {
+		if n.Channel != models.NotificationChannelSMS {
+			continue
+		}
+		var at *time.Time
+		switch n.Status {
+		case models.NotificationStatusPending:
+			pending++
+		case models.NotificationStatusSent:
+			at = n.SentAt
+		case models.NotificationStatusFailed:
+			at = n.LastAttemptAt
+		}
+		if at == nil {
+			continue
+		}
+		usage := byMonth[at.UTC().Format("2006-01")]
+		if usage == nil {
+			continue
+		}
+		usage.add(n)
+		total.add(n)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":             from.Format("2006-01"),
+		"to":               to.Format("2006-01"),
+		"enabled":          sms.Enabled(),
+		"cost_per_segment": sms.Settings().CostPerSegment,
+		"months":           months,
+		"total":            total,
+		"pending":          pending,
+	})
+}
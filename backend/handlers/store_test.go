@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"path/filepath"
+	"testing"
+)
+
+// useTestStore sostituisce database.DB con uno store JSON vuoto (migrato) in
+// una cartella temporanea, per la durata del test
+func useTestStore(t *testing.T) database.Store {
+	t.Helper()
+	previous := database.DB
+	db := database.ConnectJSON(filepath.Join(t.TempDir(), "data.json"))
+	db.Migrate()
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return db
+}
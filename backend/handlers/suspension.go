@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// suspensionStatus - Stato del donatore ricavato dalle sue sospensioni
+type suspensionStatus struct {
+	Suspended     bool       // una sospensione è in corso adesso
+	Permanent     bool       // esclusione definitiva già iniziata
+	PermanentFrom *time.Time // inizio della prima esclusione definitiva attiva, anche futura
+	Until         *time.Time // fine più lontana tra le sospensioni temporanee attive, anche future
+}
+
+func donorSuspensionStatus(suspensions []models.Suspension, now time.Time) suspensionStatus {
+	var status suspensionStatus
+	for _, s := range suspensions {
+		if !s.IsActive {
+			continue
+		}
+		if s.InEffect(now) {
+			status.Suspended = true
+		}
+		if s.Permanent {
+			// L'esclusione vale dalla data di inizio, non da quando è inserita
+			if status.PermanentFrom == nil || s.StartDate.Before(*status.PermanentFrom) {
+				start := s.StartDate
+				status.PermanentFrom = &start
+			}
+			if !now.Before(s.StartDate) {
+				status.Permanent = true
+			}
+			continue
+		}
+		if now.Before(s.EndDate) && (status.Until == nil || s.EndDate.After(*status.Until)) {
+			end := s.EndDate
+			status.Until = &end
+		}
+	}
+	return status
+}
+
+// setSuspensionEnd calcola la data di fine: nessuna per le permanenti, dalla
+// durata se indicata, altrimenti deve essere già impostata dopo l'inizio
+func setSuspensionEnd(suspension *models.Suspension) error {
+	if suspension.DurationMonths < 0 || suspension.DurationWeeks < 0 || suspension.DurationDays < 0 {
+		return newAPIError(http.StatusBadRequest, "La durata non può essere negativa")
+	}
+	switch {
+	case suspension.Permanent:
+		suspension.DurationMonths, suspension.DurationWeeks, suspension.DurationDays = 0, 0, 0
+		suspension.EndDate = time.Time{}
+	case suspension.HasDuration():
+		suspension.EndDate = suspension.DurationEndDate()
+	case suspension.EndDate.After(suspension.StartDate):
+	default:
+		return newAPIError(http.StatusBadRequest, "Indica la durata (duration_months, duration_weeks o duration_days), una end_date successiva all'inizio oppure permanent")
+	}
+	return nil
+}
+
+// GetSuspensions - Lista sospensioni (?pending=true solo quelle da
+// revisionare, ?donor_id= solo quelle del donatore)
+func GetSuspensions(c *gin.Context) {
+	var suspensions []models.Suspension
+	var err error
+	if donorID := c.Query("donor_id"); donorID != "" {
+		id, parseErr := strconv.ParseUint(donorID, 10, 32)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "donor_id non valido"})
+			return
+		}
+		suspensions, err = database.DB.ListSuspensionsByDonor(uint(id))
+	} else {
+		suspensions, err = database.DB.ListSuspensions()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load suspensions"})
+		return
+	}
+	if c.Query("pending") == "true" {
+		pending := []models.Suspension{}
+		for _, s := range suspensions {
+			if s.PendingReview {
+				pending = append(pending, s)
+			}
+		}
+		suspensions = pending
+	}
+	c.JSON(http.StatusOK, suspensions)
+}
+
+func CreateSuspension(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+	var suspension models.Suspension
+	if err := c.ShouldBindJSON(&suspension); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	suspension.ID = 0
+	suspension.CreatedBy = adminID.(uint)
+
+	err := database.DB.WithTx(func(tx database.Store) error {
+		return createSuspension(tx, &suspension)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create suspension")
+		return
+	}
+	c.JSON(http.StatusCreated, suspension)
+}
+
+// applyDeferralReason collega la sospensione a un motivo del catalogo: il
+// nome fa da motivo se non ne è indicato un altro, e senza durata esplicita
+// si usa quella di default del motivo
+func applyDeferralReason(tx database.Store, suspension *models.Suspension) error {
+	reason, err := tx.GetDeferralReason(*suspension.ReasonID)
+	if err != nil || !reason.IsActive {
+		return newAPIError(http.StatusBadRequest, "Motivo di sospensione non valido")
+	}
+	if suspension.Reason == "" {
+		suspension.Reason = reason.Name
+	}
+	if reason.Permanent {
+		suspension.Permanent = true
+	}
+	if !suspension.Permanent && !suspension.HasDuration() && suspension.EndDate.IsZero() {
+		suspension.DurationMonths = reason.DefaultDurationMonths
+		suspension.DurationWeeks = reason.DefaultDurationWeeks
+		suspension.DurationDays = reason.DefaultDurationDays
+	}
+	return nil
+}
+
+// createSuspension valida la sospensione, ne calcola la fine, la salva e ne
+// avvisa il donatore. Lo stato sospeso del donatore deriva dalle sospensioni attive.
+func createSuspension(tx database.Store, suspension *models.Suspension) error {
+	suspension.Reason = strings.TrimSpace(suspension.Reason)
+	if suspension.ReasonID != nil {
+		if err := applyDeferralReason(tx, suspension); err != nil {
+			return err
+		}
+	}
+	if suspension.Reason == "" {
+		return newAPIError(http.StatusBadRequest, "Indica il motivo della sospensione")
+	}
+	if _, err := tx.GetUser(suspension.DonorID); err != nil {
+		return newAPIError(http.StatusNotFound, "User not found")
+	}
+	if suspension.StartDate.IsZero() {
+		suspension.StartDate = dateOnly(time.Now())
+	}
+	if err := setSuspensionEnd(suspension); err != nil {
+		return err
+	}
+
+	suspension.IsActive = true
+	suspension.PendingReview, suspension.ReviewedAt, suspension.ReviewedBy = false, nil, nil
+	suspension.CancelledAt, suspension.CancelledBy, suspension.CancelReason = nil, nil, ""
+	suspension.CreatedAt = time.Now()
+	suspension.UpdatedAt = time.Now()
+	if err := tx.CreateSuspension(suspension); err != nil {
+		return err
+	}
+	return notifySuspension(tx, suspension)
+}
+
+// UpdateSuspension - Modifica inizio, durata, fine, tipo o motivo di una
+// sospensione attiva o in attesa di revisione (Admin)
+func UpdateSuspension(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var req struct {
+		StartDate      *string `json:"start_date"`
+		EndDate        *string `json:"end_date"`
+		DurationMonths *int    `json:"duration_months"`
+		DurationWeeks  *int    `json:"duration_weeks"`
+		DurationDays   *int    `json:"duration_days"`
+		Permanent      *bool   `json:"permanent"`
+		Reason         *string `json:"reason"`
+		ReasonID       *uint   `json:"reason_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var suspension *models.Suspension
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		suspension, err = tx.GetSuspension(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		if !suspension.IsActive && !suspension.PendingReview {
+			return newAPIError(http.StatusConflict, "Solo una sospensione attiva o da revisionare può essere modificata")
+		}
+
+		if req.StartDate != nil {
+			start, err := time.Parse("2006-01-02", *req.StartDate)
+			if err != nil {
+				return newAPIError(http.StatusBadRequest, "Formato start_date non valido")
+			}
+			suspension.StartDate = start
+		}
+		if req.Permanent != nil {
+			suspension.Permanent = *req.Permanent
+		}
+		if req.DurationMonths != nil || req.DurationWeeks != nil || req.DurationDays != nil {
+			suspension.DurationMonths, suspension.DurationWeeks, suspension.DurationDays = 0, 0, 0
+			if req.DurationMonths != nil {
+				suspension.DurationMonths = *req.DurationMonths
+			}
+			if req.DurationWeeks != nil {
+				suspension.DurationWeeks = *req.DurationWeeks
+			}
+			if req.DurationDays != nil {
+				suspension.DurationDays = *req.DurationDays
+			}
+		}
+		// Una data di fine esplicita sostituisce la durata
+		if req.EndDate != nil {
+			end, err := time.Parse("2006-01-02", *req.EndDate)
+			if err != nil {
+				return newAPIError(http.StatusBadRequest, "Formato end_date non valido")
+			}
+			suspension.EndDate = end
+			suspension.DurationMonths, suspension.DurationWeeks, suspension.DurationDays = 0, 0, 0
+		}
+		if req.Reason != nil {
+			suspension.Reason = strings.TrimSpace(*req.Reason)
+		}
+		// Cambiando voce del catalogo valgono nome, tipo e durata della voce,
+		// come alla creazione, salvo i campi indicati nella richiesta
+		if req.ReasonID != nil {
+			suspension.ReasonID = req.ReasonID
+			if req.Reason == nil {
+				suspension.Reason = ""
+			}
+			if req.Permanent == nil {
+				suspension.Permanent = false
+			}
+			if req.DurationMonths == nil && req.DurationWeeks == nil && req.DurationDays == nil && req.EndDate == nil {
+				suspension.DurationMonths, suspension.DurationWeeks, suspension.DurationDays = 0, 0, 0
+				suspension.EndDate = time.Time{}
+			}
+			if err := applyDeferralReason(tx, suspension); err != nil {
+				return err
+			}
+		}
+		if suspension.Reason == "" {
+			return newAPIError(http.StatusBadRequest, "Indica il motivo della sospensione")
+		}
+		if err := setSuspensionEnd(suspension); err != nil {
+			return err
+		}
+
+		suspension.UpdatedAt = time.Now()
+		return tx.UpdateSuspension(suspension)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to update suspension")
+		return
+	}
+
+	c.JSON(http.StatusOK, suspension)
+}
+
+// ApproveSuspension - Conferma una sospensione suggerita (es. dal
+// questionario): diventa attiva con la durata impostata, eventualmente
+// corretta prima con PUT /suspensions/:id (Admin)
+func ApproveSuspension(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	adminID, _ := c.Get("user_id")
+
+	var suspension *models.Suspension
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		suspension, err = tx.GetSuspension(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		if !suspension.PendingReview {
+			return newAPIError(http.StatusConflict, "La sospensione non è in attesa di revisione")
+		}
+		if err := setSuspensionEnd(suspension); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		reviewedBy := adminID.(uint)
+		suspension.IsActive = true
+		suspension.PendingReview = false
+		suspension.ReviewedAt = &now
+		suspension.ReviewedBy = &reviewedBy
+		suspension.UpdatedAt = now
+		if err := tx.UpdateSuspension(suspension); err != nil {
+			return err
+		}
+		return notifySuspension(tx, suspension)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to approve suspension")
+		return
+	}
+
+	c.JSON(http.StatusOK, suspension)
+}
+
+// EndSuspension - Termina in anticipo una sospensione, da adesso
+func EndSuspension(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var suspension *models.Suspension
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		suspension, err = tx.GetSuspension(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		if !suspension.IsActive {
+			return newAPIError(http.StatusConflict, "La sospensione non è attiva")
+		}
+
+		// Terminarla chiude anche l'eventuale revisione (es. sospensioni dal vecchio flag is_suspended)
+		suspension.IsActive = false
+		suspension.PendingReview = false
+		suspension.EndDate = time.Now()
+		suspension.UpdatedAt = time.Now()
+		return tx.UpdateSuspension(suspension)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to end suspension")
+		return
+	}
+
+	c.JSON(http.StatusOK, suspension)
+}
+
+// CancelSuspension - Annulla una sospensione inserita per errore, o ne
+// rifiuta una in attesa di revisione: non conta più né per lo stato del
+// donatore né per la sua prossima scadenza. 409 se la sospensione è già
+// chiusa o terminata (Admin)
+func CancelSuspension(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	adminID, _ := c.Get("user_id")
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// Il corpo è facoltativo
+	_ = c.ShouldBindJSON(&req)
+
+	var suspension *models.Suspension
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		suspension, err = tx.GetSuspension(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "Not found")
+		}
+		if suspension.CancelledAt != nil {
+			return newAPIError(http.StatusConflict, "La sospensione è già annullata")
+		}
+		// Una sospensione chiusa o già terminata resta nello storico così com'è
+		now := time.Now()
+		ended := !suspension.Permanent && !now.Before(suspension.EndDate)
+		if !suspension.PendingReview && (!suspension.IsActive || ended) {
+			return newAPIError(http.StatusConflict, "Si possono annullare solo le sospensioni attive o in attesa di revisione")
+		}
+
+		cancelledBy := adminID.(uint)
+		if suspension.PendingReview {
+			suspension.PendingReview = false
+			suspension.ReviewedAt = &now
+			suspension.ReviewedBy = &cancelledBy
+		}
+		suspension.IsActive = false
+		suspension.CancelledAt = &now
+		suspension.CancelledBy = &cancelledBy
+		suspension.CancelReason = strings.TrimSpace(req.Reason)
+		suspension.UpdatedAt = now
+		return tx.UpdateSuspension(suspension)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to cancel suspension")
+		return
+	}
+
+	c.JSON(http.StatusOK, suspension)
+}
+
+// ExpireSuspensions chiude (is_active=false) le sospensioni temporanee
+// arrivate alla data di fine. Lo stato del donatore è già corretto anche
+// prima del job, perché deriva dalle date: il job tiene pulito l'archivio.
+func ExpireSuspensions(now time.Time) (int, error) {
+	ended := 0
+	err := database.DB.WithTx(func(tx database.Store) error {
+		ended = 0
+		suspensions, err := tx.ListSuspensions()
+		if err != nil {
+			return err
+		}
+		for i := range suspensions {
+			suspension := &suspensions[i]
+			if !suspension.IsActive || suspension.Permanent || now.Before(suspension.EndDate) {
+				continue
+			}
+			suspension.IsActive = false
+			suspension.UpdatedAt = now
+			if err := tx.UpdateSuspension(suspension); err != nil {
+				return err
+			}
+			ended++
+		}
+		return nil
+	})
+	return ended, err
+}
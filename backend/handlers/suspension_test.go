@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bloodone/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sameTime confronta due date facoltative
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func TestDonorSuspensionStatus(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.AddDate(0, -1, 0), now.AddDate(0, 1, 0)
+	later := now.AddDate(0, 3, 0)
+
+	tests := []struct {
+		name        string
+		suspensions []models.Suspension
+		want        suspensionStatus
+	}{
+		{"nessuna sospensione", nil, suspensionStatus{}},
+		{"temporanea in corso",
+			[]models.Suspension{{StartDate: past, EndDate: future, IsActive: true}},
+			suspensionStatus{Suspended: true, Until: &future}},
+		{"temporanea futura: conta per la fine",
+			[]models.Suspension{{StartDate: future, EndDate: later, IsActive: true}},
+			suspensionStatus{Until: &later}},
+		{"temporanea terminata",
+			[]models.Suspension{{StartDate: past.AddDate(0, -1, 0), EndDate: past, IsActive: true}},
+			suspensionStatus{}},
+		{"permanente in corso",
+			[]models.Suspension{{StartDate: past, Permanent: true, IsActive: true}},
+			suspensionStatus{Suspended: true, Permanent: true, PermanentFrom: &past}},
+		{"permanente non ancora iniziata",
+			[]models.Suspension{{StartDate: future, Permanent: true, IsActive: true}},
+			suspensionStatus{PermanentFrom: &future}},
+		{"vale l'esclusione permanente che inizia prima",
+			[]models.Suspension{
+				{StartDate: later, Permanent: true, IsActive: true},
+				{StartDate: future, Permanent: true, IsActive: true},
+			},
+			suspensionStatus{PermanentFrom: &future}},
+		{"non attiva",
+			[]models.Suspension{{StartDate: past, Permanent: true}},
+			suspensionStatus{}},
+		{"la fine più lontana",
+			[]models.Suspension{
+				{StartDate: past, EndDate: future, IsActive: true},
+				{StartDate: future, EndDate: later, IsActive: true},
+			},
+			suspensionStatus{Suspended: true, Until: &later}},
+	}
+	for _, tt := range tests {
+		got := donorSuspensionStatus(tt.suspensions, now)
+		if got.Suspended != tt.want.Suspended || got.Permanent != tt.want.Permanent ||
+			!sameTime(got.PermanentFrom, tt.want.PermanentFrom) || !sameTime(got.Until, tt.want.Until) {
+			t.Errorf("%s: donorSuspensionStatus = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCancelSuspension(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Now()
+	cancelled := now.AddDate(0, 0, -1)
+
+	tests := []struct {
+		name       string
+		suspension models.Suspension
+		wantStatus int
+	}{
+		{"attiva", models.Suspension{StartDate: now.AddDate(0, -1, 0), EndDate: now.AddDate(0, 1, 0), IsActive: true}, http.StatusOK},
+		{"permanente attiva", models.Suspension{StartDate: now.AddDate(0, -1, 0), Permanent: true, IsActive: true}, http.StatusOK},
+		{"futura", models.Suspension{StartDate: now.AddDate(0, 1, 0), EndDate: now.AddDate(0, 2, 0), IsActive: true}, http.StatusOK},
+		{"in attesa di revisione", models.Suspension{StartDate: now, EndDate: now.AddDate(0, 1, 0), PendingReview: true}, http.StatusOK},
+		{"terminata ma non ancora chiusa dal job", models.Suspension{StartDate: now.AddDate(0, -2, 0), EndDate: now.AddDate(0, 0, -1), IsActive: true}, http.StatusConflict},
+		{"chiusa", models.Suspension{StartDate: now.AddDate(0, -2, 0), EndDate: now.AddDate(0, 1, 0)}, http.StatusConflict},
+		{"già annullata", models.Suspension{StartDate: now, EndDate: now.AddDate(0, 1, 0), CancelledAt: &cancelled}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useTestStore(t)
+			donor := createTestDonor(t, store, models.NotificationChannelEmail, "")
+			suspension := tt.suspension
+			suspension.DonorID = donor.ID
+			suspension.Reason = "Prova"
+			if err := store.CreateSuspension(&suspension); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason":"Inserita per errore"}`))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(suspension.ID))}}
+			c.Set("user_id", uint(1))
+
+			CancelSuspension(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, tt.wantStatus)
+			}
+
+			stored, err := store.GetSuspension(suspension.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				// Lo storico di una sospensione chiusa non cambia
+				if stored.IsActive != tt.suspension.IsActive || stored.CancelReason != "" ||
+					(stored.CancelledAt == nil) != (tt.suspension.CancelledAt == nil) {
+					t.Errorf("sospensione modificata: %+v", stored)
+				}
+				return
+			}
+			if stored.IsActive || stored.PendingReview || stored.CancelledAt == nil || stored.CancelReason != "Inserita per errore" {
+				t.Errorf("sospensione annullata = %+v", stored)
+			}
+		})
+	}
+}
+
+func TestUpdateSuspensionReason(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	start := dateOnly(time.Now())
+
+	tests := []struct {
+		name          string
+		suspension    models.Suspension
+		reason        models.DeferralReason
+		body          string
+		wantPermanent bool
+		wantEnd       time.Time
+	}{
+		{"da temporanea a voce permanente",
+			models.Suspension{DurationMonths: 3, IsActive: true},
+			models.DeferralReason{Code: "test_permanent", Name: "Positività HIV", Permanent: true},
+			`{"reason_id":%d}`, true, time.Time{}},
+		{"da permanente a voce temporanea",
+			models.Suspension{Permanent: true, IsActive: true},
+			models.DeferralReason{Code: "test_temporary", Name: "Tatuaggio", DefaultDurationMonths: 4},
+			`{"reason_id":%d}`, false, start.AddDate(0, 4, 0)},
+		{"durata indicata nella richiesta",
+			models.Suspension{DurationMonths: 3, IsActive: true},
+			models.DeferralReason{Code: "test_temporary", Name: "Tatuaggio", DefaultDurationMonths: 4},
+			`{"reason_id":%d,"duration_days":10}`, false, start.AddDate(0, 0, 10)},
+		{"in attesa di revisione",
+			models.Suspension{DurationMonths: 3, PendingReview: true},
+			models.DeferralReason{Code: "test_permanent", Name: "Positività HIV", Permanent: true},
+			`{"reason_id":%d}`, true, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useTestStore(t)
+			donor := createTestDonor(t, store, models.NotificationChannelEmail, "")
+			reason := tt.reason
+			reason.Category = models.DeferralCategoryMedical
+			reason.IsActive = true
+			if err := store.CreateDeferralReason(&reason); err != nil {
+				t.Fatal(err)
+			}
+			suspension := tt.suspension
+			suspension.DonorID = donor.ID
+			suspension.Reason = "Motivo iniziale"
+			suspension.StartDate = start
+			if err := setSuspensionEnd(&suspension); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.CreateSuspension(&suspension); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			body := fmt.Sprintf(tt.body, reason.ID)
+			c.Request = httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(suspension.ID))}}
+
+			UpdateSuspension(c)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d (%s)", w.Code, w.Body)
+			}
+			stored, err := store.GetSuspension(suspension.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Permanent != tt.wantPermanent || !stored.EndDate.Equal(tt.wantEnd) || stored.Reason != reason.Name {
+				t.Errorf("sospensione = permanent %v, fine %s, motivo %q; want %v, %s, %q",
+					stored.Permanent, stored.EndDate.Format("2006-01-02"), stored.Reason,
+					tt.wantPermanent, tt.wantEnd.Format("2006-01-02"), reason.Name)
+			}
+		})
+	}
+}
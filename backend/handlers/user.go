@@ -13,10 +13,19 @@ import (
 
 // GetUsers - Lista tutti gli utenti (Admin)
 func GetUsers(c *gin.Context) {
-	users := database.DB.Users
+	users, err := database.DB.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
+		return
+	}
+	// ?approaching_age_limit=true: solo i donatori vicini all'età massima
+	approaching := c.Query("approaching_age_limit") == "true"
 	var response []models.UserResponse
 	for _, user := range users {
 		userResp := buildUserResponseSimple(user)
+		if approaching && !userResp.ApproachingAgeLimit {
+			continue
+		}
 		response = append(response, userResp)
 	}
 	c.JSON(http.StatusOK, response)
@@ -26,30 +35,29 @@ func GetUsers(c *gin.Context) {
 func GetUser(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
-	for _, user := range database.DB.Users {
-		if user.ID == uint(id) {
-			userResp := buildUserResponseSimple(user)
-			c.JSON(http.StatusOK, userResp)
-			return
-		}
+	user, err := database.DB.GetUser(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	c.JSON(http.StatusOK, buildUserResponseSimple(*user))
 }
 
 // GetCurrentUser - Informazioni utente corrente
 func GetCurrentUser(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
-	for _, user := range database.DB.Users {
-		if user.ID == userID.(uint) {
-			userResp := buildUserResponseSimple(user)
-			c.JSON(http.StatusOK, userResp)
-			return
-		}
+	user, err := database.DB.GetUser(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	c.JSON(http.StatusOK, buildUserResponseSimple(*user))
 }
 
+// is_suspended non è più un campo dell'utente: lo stato deriva dalle sospensioni
+const errSuspendedField = "is_suspended non è modificabile: usa /api/admin/suspensions per sospendere il donatore (POST) o terminare la sospensione (PUT /:id/end)"
+
 // CreateUser - Crea nuovo utente (Admin)
 func CreateUser(c *gin.Context) {
 	var input map[string]interface{}
@@ -58,6 +66,11 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
+	if _, ok := input["is_suspended"]; ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errSuspendedField})
+		return
+	}
+
 	// Verifica email univoca
 	email, ok := input["email"].(string)
 	if !ok || email == "" {
@@ -65,15 +78,7 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
-	for _, u := range database.DB.Users {
-		if u.Email == email {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
-			return
-		}
-	}
-
 	user := models.User{
-		ID:          database.DB.NextUserID(),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 		Email:       email,
@@ -83,7 +88,9 @@ func CreateUser(c *gin.Context) {
 		BloodType:   getStringOrEmpty(input, "blood_type"),
 		IsAdmin:     getBoolOrDefault(input, "is_admin", false),
 		IsActive:    getBoolOrDefault(input, "is_active", true),
-		IsSuspended: getBoolOrDefault(input, "is_suspended", false),
+
+		AgeClearance:        getBoolOrDefault(input, "age_clearance", false),
+		NotificationChannel: getStringOrEmpty(input, "notification_channel"),
 	}
 
 	// Gender
@@ -98,8 +105,19 @@ func CreateUser(c *gin.Context) {
 		}
 	}
 
-	database.DB.Users = append(database.DB.Users, user)
-	database.DB.Save()
+	err := database.DB.WithTx(func(tx database.Store) error {
+		if _, err := tx.FindUserByEmail(email); err == nil {
+			return newAPIError(http.StatusBadRequest, "Email already exists")
+		}
+		if err := validateNotificationChannel(&user); err != nil {
+			return err
+		}
+		return tx.CreateUser(&user)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to create user")
+		return
+	}
 
 	c.JSON(http.StatusCreated, user)
 }
@@ -111,6 +129,28 @@ func getStringOrEmpty(m map[string]interface{}, key string) string {
 	return ""
 }
 
+// parseWeekdays converte una lista JSON di giorni della settimana (0-6); null la svuota
+func parseWeekdays(value interface{}) (models.IntList, bool) {
+	if value == nil {
+		return nil, true
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	weekdays := models.IntList{}
+	for _, item := range items {
+		n, ok := item.(float64)
+		if !ok || n < 0 || n > 6 || n != float64(int(n)) {
+			return nil, false
+		}
+		if !weekdays.Contains(int(n)) {
+			weekdays = append(weekdays, int(n))
+		}
+	}
+	return weekdays, true
+}
+
 func getBoolOrDefault(m map[string]interface{}, key string, def bool) bool {
 	if v, ok := m[key].(bool); ok {
 		return v
@@ -122,6 +162,10 @@ func getBoolOrDefault(m map[string]interface{}, key string, def bool) bool {
 func UpdateUser(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	userID, _ := c.Get("user_id")
+	if c.Param("id") == "" {
+		// PUT /api/me
+		id = uint64(userID.(uint))
+	}
 	isAdmin, _ := c.Get("is_admin")
 
 	if !isAdmin.(bool) && userID.(uint) != uint(id) {
@@ -139,150 +183,224 @@ func UpdateUser(c *gin.Context) {
 		delete(updates, "is_admin")
 		delete(updates, "is_suspended")
 	}
+	if _, ok := updates["is_suspended"]; ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errSuspendedField})
+		return
+	}
 
-	for i, user := range database.DB.Users {
-		if user.ID == uint(id) {
-			// Aggiorna campi
-			if fn, ok := updates["first_name"].(string); ok {
-				database.DB.Users[i].FirstName = fn
-			}
-			if ln, ok := updates["last_name"].(string); ok {
-				database.DB.Users[i].LastName = ln
-			}
-			if pn, ok := updates["phone_number"].(string); ok {
-				database.DB.Users[i].PhoneNumber = pn
-			}
-			if pn, ok := updates["phone"].(string); ok {
-				database.DB.Users[i].PhoneNumber = pn
-			}
-			if bt, ok := updates["blood_type"].(string); ok {
-				database.DB.Users[i].BloodType = bt
+	var user *models.User
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		user, err = tx.GetUser(uint(id))
+		if err != nil {
+			return newAPIError(http.StatusNotFound, "User not found")
+		}
+
+		// Aggiorna campi
+		if fn, ok := updates["first_name"].(string); ok {
+			user.FirstName = fn
+		}
+		if ln, ok := updates["last_name"].(string); ok {
+			user.LastName = ln
+		}
+		if pn, ok := updates["phone_number"].(string); ok {
+			user.PhoneNumber = pn
+		}
+		if pn, ok := updates["phone"].(string); ok {
+			user.PhoneNumber = pn
+		}
+		if bt, ok := updates["blood_type"].(string); ok {
+			user.BloodType = bt
+		}
+		if g, ok := updates["gender"].(string); ok {
+			user.Gender = models.Gender(g)
+		}
+		if bd, ok := updates["birth_date"].(string); ok {
+			if birthDate, err := time.Parse("2006-01-02", bd); err == nil {
+				user.BirthDate = &birthDate
 			}
-			if g, ok := updates["gender"].(string); ok {
-				database.DB.Users[i].Gender = models.Gender(g)
+		}
+		if ia, ok := updates["is_active"].(bool); ok {
+			user.IsActive = ia
+		}
+		if pw, ok := updates["preferred_weekdays"]; ok {
+			weekdays, valid := parseWeekdays(pw)
+			if !valid {
+				return newAPIError(http.StatusBadRequest, "preferred_weekdays: valori ammessi da 0 (domenica) a 6 (sabato)")
 			}
-			if bd, ok := updates["birth_date"].(string); ok {
-				if birthDate, err := time.Parse("2006-01-02", bd); err == nil {
-					database.DB.Users[i].BirthDate = &birthDate
-				}
+			user.PreferredWeekdays = weekdays
+		}
+		if nc, ok := updates["notification_channel"].(string); ok {
+			user.NotificationChannel = nc
+		}
+		if err := validateNotificationChannel(user); err != nil {
+			return err
+		}
+		if isAdmin.(bool) {
+			if iadmin, ok := updates["is_admin"].(bool); ok {
+				user.IsAdmin = iadmin
 			}
-			if ia, ok := updates["is_active"].(bool); ok {
-				database.DB.Users[i].IsActive = ia
+			if clearance, ok := updates["age_clearance"].(bool); ok {
+				user.AgeClearance = clearance
 			}
-			if isAdmin.(bool) {
-				if iadmin, ok := updates["is_admin"].(bool); ok {
-					database.DB.Users[i].IsAdmin = iadmin
-				}
-				if isusp, ok := updates["is_suspended"].(bool); ok {
-					database.DB.Users[i].IsSuspended = isusp
-				}
 
-				// Gestione data ultima donazione (solo admin)
-				if ldd, ok := updates["last_donation_date"].(string); ok && ldd != "" {
-					if donationDate, err := time.Parse("2006-01-02", ldd); err == nil {
-						// Cerca se esiste già una donazione per questo utente
-						var existingDonation *models.Donation
-						for j := range database.DB.Donations {
-							if database.DB.Donations[j].DonorID == user.ID {
-								existingDonation = &database.DB.Donations[j]
-								break
-							}
-						}
-
-						if existingDonation != nil {
-							// Aggiorna la donazione esistente
-							existingDonation.DonationDate = donationDate
-							existingDonation.UpdatedAt = time.Now()
-						} else {
-							// Crea nuova donazione
-							newDonation := models.Donation{
-								ID:           database.DB.NextDonationID(),
-								CreatedAt:    time.Now(),
-								UpdatedAt:    time.Now(),
-								DonorID:      user.ID,
-								DonationDate: donationDate,
-								Status:       models.DonationStatusCompleted,
-								Notes:        "Donazione inserita dall'amministratore",
-							}
-							database.DB.Donations = append(database.DB.Donations, newDonation)
-						}
+			// Gestione data ultima donazione (solo admin)
+			if ldd, ok := updates["last_donation_date"].(string); ok && ldd != "" {
+				if donationDate, err := time.Parse("2006-01-02", ldd); err == nil {
+					if err := setLastDonationDate(tx, user.ID, donationDate); err != nil {
+						return err
 					}
 				}
+			}
 
-				// Gestione prossimo appuntamento (solo admin)
-				if nad, ok := updates["next_appointment_date"].(string); ok {
-					if nad == "" {
-						// Se vuoto, rimuovi l'appuntamento confermato esistente
-						for j := range database.DB.Appointments {
-							if database.DB.Appointments[j].DonorID == user.ID &&
-								database.DB.Appointments[j].Status == models.AppointmentStatusConfirmed {
-								database.DB.Appointments[j].Status = models.AppointmentStatusCancelled
-								database.DB.Appointments[j].UpdatedAt = time.Now()
-								break
-							}
-						}
-					} else if appointmentDate, err := time.Parse("2006-01-02", nad); err == nil {
-						// Cerca se esiste già un appuntamento confermato per questo utente
-						var existingAppointment *models.Appointment
-						for j := range database.DB.Appointments {
-							if database.DB.Appointments[j].DonorID == user.ID &&
-								database.DB.Appointments[j].Status == models.AppointmentStatusConfirmed {
-								existingAppointment = &database.DB.Appointments[j]
-								break
-							}
-						}
-
-						if existingAppointment != nil {
-							// Aggiorna l'appuntamento esistente
-							existingAppointment.ConfirmedDate = &appointmentDate
-							existingAppointment.UpdatedAt = time.Now()
-							existingAppointment.AdminModified = true
-							adminID := userID.(uint)
-							existingAppointment.ModifiedBy = &adminID
-						} else {
-							// Crea nuovo appuntamento confermato
-							newAppointment := models.Appointment{
-								ID:            database.DB.NextAppointmentID(),
-								CreatedAt:     time.Now(),
-								UpdatedAt:     time.Now(),
-								DonorID:       user.ID,
-								ConfirmedDate: &appointmentDate,
-								Status:        models.AppointmentStatusConfirmed,
-								AdminModified: true,
-								Notes:         "Appuntamento impostato dall'amministratore",
-							}
-							adminID := userID.(uint)
-							newAppointment.ModifiedBy = &adminID
-							database.DB.Appointments = append(database.DB.Appointments, newAppointment)
-						}
-					}
+			// Gestione prossimo appuntamento (solo admin)
+			if nad, ok := updates["next_appointment_date"].(string); ok {
+				override, _ := updates["override_capacity"].(bool)
+				if err := setNextAppointmentDate(tx, user.ID, nad, userID.(uint), override); err != nil {
+					return err
 				}
 			}
-			database.DB.Users[i].UpdatedAt = time.Now()
-			database.DB.Save()
+		}
+		user.UpdatedAt = time.Now()
+		return tx.UpdateUser(user)
+	})
+	if err != nil {
+		respondError(c, err, "Failed to update user")
+		return
+	}
+
+	// Restituisci UserResponse con tutti i campi calcolati
+	c.JSON(http.StatusOK, buildUserResponseSimple(*user))
+}
+
+// setLastDonationDate aggiorna la prima donazione del donatore o ne crea una nuova
+func setLastDonationDate(tx database.Store, donorID uint, donationDate time.Time) error {
+	donations, err := tx.ListDonationsByDonor(donorID)
+	if err != nil {
+		return err
+	}
+
+	if len(donations) > 0 {
+		// Aggiorna la donazione esistente
+		existingDonation := donations[0]
+		existingDonation.DonationDate = donationDate
+		existingDonation.UpdatedAt = time.Now()
+		return tx.UpdateDonation(&existingDonation)
+	}
+
+	// Crea nuova donazione
+	newDonation := models.Donation{
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		DonorID:      donorID,
+		DonationDate: donationDate,
+		Status:       models.DonationStatusCompleted,
+		Notes:        "Donazione inserita dall'amministratore",
+	}
+	return tx.CreateDonation(&newDonation)
+}
+
+// setNextAppointmentDate imposta (o rimuove, se nad è vuoto) l'appuntamento confermato del donatore.
+// La data deve rispettare calendario e capacità, salvo override.
+func setNextAppointmentDate(tx database.Store, donorID uint, nad string, adminID uint, override bool) error {
+	appointments, err := tx.ListAppointmentsByDonor(donorID)
+	if err != nil {
+		return err
+	}
 
-			// Restituisci UserResponse con tutti i campi calcolati
-			userResp := buildUserResponseSimple(database.DB.Users[i])
-			c.JSON(http.StatusOK, userResp)
-			return
+	// Cerca se esiste già un appuntamento confermato per questo utente
+	var existingAppointment *models.Appointment
+	for i := range appointments {
+		if appointments[i].Status == models.AppointmentStatusConfirmed {
+			existingAppointment = &appointments[i]
+			break
 		}
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+
+	if nad == "" {
+		// Se vuoto, rimuovi l'appuntamento confermato esistente
+		if existingAppointment == nil {
+			return nil
+		}
+		before := *existingAppointment
+		existingAppointment.Status = models.AppointmentStatusCancelled
+		existingAppointment.CancelledBy = &adminID
+		existingAppointment.UpdatedAt = time.Now()
+		if err := tx.UpdateAppointment(existingAppointment); err != nil {
+			return err
+		}
+		return logAppointmentEvent(tx, before, existingAppointment, adminID, models.AppointmentActionCancelled, "Prossimo appuntamento rimosso dall'amministratore")
+	}
+
+	appointmentDate, err := time.Parse("2006-01-02", nad)
+	if err != nil {
+		return nil
+	}
+
+	// L'appuntamento esistente viene spostato: non occupa posto nel nuovo giorno
+	var ignoreID uint
+	if existingAppointment != nil {
+		ignoreID = existingAppointment.ID
+	}
+	cal, err := loadCapacityCalendar(tx, ignoreID)
+	if err != nil {
+		return err
+	}
+	forced, err := cal.checkBookable(appointmentDate, override)
+	if err != nil {
+		return err
+	}
+	slot := cal.firstFreeSlot(appointmentDate)
+
+	if existingAppointment != nil {
+		// Aggiorna l'appuntamento esistente
+		before := *existingAppointment
+		if forced {
+			recordOverride(existingAppointment, adminID)
+		}
+		existingAppointment.ConfirmedDate = &appointmentDate
+		existingAppointment.ConfirmedSlot = slot
+		existingAppointment.RemindersSent = nil
+		existingAppointment.UpdatedAt = time.Now()
+		existingAppointment.AdminModified = true
+		existingAppointment.ModifiedBy = &adminID
+		if err := tx.UpdateAppointment(existingAppointment); err != nil {
+			return err
+		}
+		return logAppointmentEvent(tx, before, existingAppointment, adminID, models.AppointmentActionAdminSet, "")
+	}
+
+	// Crea nuovo appuntamento confermato
+	newAppointment := models.Appointment{
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		DonorID:       donorID,
+		ConfirmedDate: &appointmentDate,
+		ConfirmedSlot: slot,
+		Status:        models.AppointmentStatusConfirmed,
+		DonationType:  models.DonationTypeWholeBlood,
+		AdminModified: true,
+		ModifiedBy:    &adminID,
+		Notes:         "Appuntamento impostato dall'amministratore",
+	}
+	if forced {
+		recordOverride(&newAppointment, adminID)
+	}
+	if err := tx.CreateAppointment(&newAppointment); err != nil {
+		return err
+	}
+	return logAppointmentEvent(tx, models.Appointment{}, &newAppointment, adminID, models.AppointmentActionAdminSet, "")
 }
 
 // DeleteUser - Elimina utente (Admin)
 func DeleteUser(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
-	for i, user := range database.DB.Users {
-		if user.ID == uint(id) {
-			database.DB.Users = append(database.DB.Users[:i], database.DB.Users[i+1:]...)
-			database.DB.Save()
-			c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
-			return
-		}
+	if err := database.DB.DeleteUser(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
 // GetDonorsExpiringSoon - Donatori in scadenza (prossimi 14 giorni) o già scaduti (esclusi sospesi e con appuntamento confermato)
@@ -292,16 +410,23 @@ func GetDonorsExpiringSoon(c *gin.Context) {
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	twoWeeksFromNow := now.AddDate(0, 0, 14)
 
-	for _, user := range database.DB.Users {
-		if !user.IsActive || user.IsSuspended {
+	users, err := database.DB.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
+		return
+	}
+
+	for _, user := range users {
+		if !user.IsActive {
 			continue
 		}
 
+		appointments, _ := database.DB.ListAppointmentsByDonor(user.ID)
+
 		// Verifica se ha già un appuntamento confermato o pending futuro
 		hasActiveAppointment := false
-		for _, apt := range database.DB.Appointments {
-			if apt.DonorID == user.ID &&
-				(apt.Status == models.AppointmentStatusConfirmed || apt.Status == models.AppointmentStatusPending) {
+		for _, apt := range appointments {
+			if apt.Status == models.AppointmentStatusConfirmed || apt.Status == models.AppointmentStatusPending {
 				// Per confermati, verifica che la data sia oggi o futura
 				if apt.Status == models.AppointmentStatusConfirmed && apt.ConfirmedDate != nil {
 					aptDate := time.Date(apt.ConfirmedDate.Year(), apt.ConfirmedDate.Month(), apt.ConfirmedDate.Day(), 0, 0, 0, 0, apt.ConfirmedDate.Location())
@@ -339,6 +464,11 @@ func GetDonorsExpiringSoon(c *gin.Context) {
 }
 
 func buildUserResponseSimple(user models.User) models.UserResponse {
+	return buildUserResponse(database.DB, user)
+}
+
+// buildUserResponse calcola i campi derivati leggendo da store (usare tx dentro una transazione)
+func buildUserResponse(store database.Store, user models.User) models.UserResponse {
 	resp := models.UserResponse{
 		ID:          user.ID,
 		Email:       user.Email,
@@ -350,56 +480,64 @@ func buildUserResponseSimple(user models.User) models.UserResponse {
 		BirthDate:   user.BirthDate,
 		IsAdmin:     user.IsAdmin,
 		IsActive:    user.IsActive,
-		IsSuspended: user.IsSuspended,
+
+		AgeClearance:      user.AgeClearance,
+		PreferredWeekdays: user.PreferredWeekdays,
+		NoShowCount:       user.NoShowCount,
+
+		NotificationChannel: user.GetNotificationChannel(),
 	}
 
 	// Conta donazioni e trova ultima
-	var lastDonation *models.Donation
-	donationCount := 0
-	for _, donation := range database.DB.Donations {
-		if donation.DonorID == user.ID && donation.Status == models.DonationStatusCompleted {
-			donationCount++
-			if lastDonation == nil || donation.DonationDate.After(lastDonation.DonationDate) {
-				donation := donation
-				lastDonation = &donation
-			}
-		}
+	record, _ := loadDonorRecord(store, user)
+	if record == nil {
+		record = &donorRecord{user: user}
 	}
-	resp.TotalDonations = donationCount
+	resp.TotalDonations = len(record.donations)
+
+	// Lo stato sospeso deriva dalle sospensioni attive
+	resp.IsSuspended = record.suspension.Suspended
+	resp.PermanentlyDeferred = record.suspension.Permanent
+	resp.SuspendedUntil = record.suspension.Until
 
-	if lastDonation != nil {
-		resp.LastDonationDate = &lastDonation.DonationDate
-		daysSince := int(time.Since(lastDonation.DonationDate).Hours() / 24)
+	if n := len(record.donations); n > 0 {
+		resp.LastDonationDate = &record.donations[n-1].DonationDate
+		daysSince := int(time.Since(record.donations[n-1].DonationDate).Hours() / 24)
 		resp.DaysSinceLastDonation = daysSince
+	}
 
-		interval := user.GetDonationInterval()
+	// Età e limite massimo, con la segnalazione di chi vi si avvicina
+	if user.BirthDate != nil {
+		age := ageOn(*user.BirthDate, time.Now())
+		resp.Age = &age
+		resp.AgeLimitDate = record.ageLimitDate()
+		resp.ApproachingAgeLimit = record.approachingAgeLimit(time.Now())
+	}
 
-		// Controlla sospensioni attive
-		var activeSuspension *models.Suspension
-		for _, susp := range database.DB.Suspensions {
-			if susp.DonorID == user.ID && susp.IsActive && time.Now().Before(susp.EndDate) {
-				susp := susp
-				activeSuspension = &susp
-				break
-			}
+	// Scadenza per ogni tipo di donazione: la prima data in cui tutte le
+	// regole di idoneità sono soddisfatte (vedi eligibility.go).
+	// Escluso in modo permanente o oltre l'età massima: nessuna scadenza.
+	for _, donationType := range models.DonationTypes {
+		due := record.nextDue(donationType)
+		if due == nil {
+			continue
 		}
-
-		var nextDue time.Time
-		if activeSuspension != nil {
-			nextDue = activeSuspension.EndDate
-		} else {
-			nextDue = lastDonation.DonationDate.AddDate(0, interval, 0)
+		if resp.NextDueByType == nil {
+			resp.NextDueByType = map[models.DonationType]time.Time{}
 		}
-		resp.NextDueDate = &nextDue
+		resp.NextDueByType[donationType] = *due
+	}
+	// next_due_date si riferisce al sangue intero
+	if due, ok := resp.NextDueByType[models.DonationTypeWholeBlood]; ok {
+		resp.NextDueDate = &due
 	}
 
 	// Trova prossimo appuntamento confermato
+	appointments, _ := store.ListAppointmentsByDonor(user.ID)
 	now := time.Now()
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	for _, apt := range database.DB.Appointments {
-		if apt.DonorID == user.ID &&
-			apt.Status == models.AppointmentStatusConfirmed &&
-			apt.ConfirmedDate != nil {
+	for _, apt := range appointments {
+		if apt.Status == models.AppointmentStatusConfirmed && apt.ConfirmedDate != nil {
 			// Includi se la data è oggi o nel futuro
 			aptDate := time.Date(apt.ConfirmedDate.Year(), apt.ConfirmedDate.Month(), apt.ConfirmedDate.Day(), 0, 0, 0, 0, apt.ConfirmedDate.Location())
 			if !aptDate.Before(today) {
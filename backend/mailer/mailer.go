@@ -0,0 +1,198 @@
+// Package mailer invia le email ai donatori: un Sender SMTP (provabile con
+// un server locale tipo MailHog) e i modelli HTML/testo dei messaggi. Senza
+// SMTP_HOST le email vengono solo scritte nel log.
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Mittente di default se SMTP_FROM non è impostato
+const defaultFrom = "BloodOne <noreply@bloodone.local>"
+
+// Tempo massimo per connessione e dialogo con il server SMTP
+const smtpTimeout = 15 * time.Second
+
+// Message - Email pronta da inviare, con versione testo e HTML
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender - Consegna un messaggio; l'errore indica che non è stato accettato
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTPSender - Invio tramite server SMTP. STARTTLS viene usato se il server
+// lo offre; l'autenticazione solo se è indicato Username.
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// logSender - Usato senza SMTP_HOST: scrive il messaggio nel log
+type logSender struct{}
+
+func (logSender) Send(msg Message) error {
+	log.Printf("Email (SMTP non configurato) a %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+var sender Sender = logSender{}
+
+// Configure imposta il Sender dalle variabili SMTP_HOST, SMTP_PORT,
+// SMTP_USERNAME, SMTP_PASSWORD e SMTP_FROM
+func Configure() {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Println("SMTP_HOST non impostato: le email verranno scritte nel log")
+		sender = logSender{}
+		return
+	}
+	port := 25
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			port = n
+		}
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = defaultFrom
+	}
+	sender = SMTPSender{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
+
+// SetSender sostituisce il Sender configurato, ad esempio nelle prove; nil
+// torna a scrivere le email nel log
+func SetSender(s Sender) {
+	if s == nil {
+		s = logSender{}
+	}
+	sender = s
+}
+
+// Send consegna il messaggio con il Sender configurato
+func Send(msg Message) error {
+	return sender.Send(msg)
+}
+
+func (s SMTPSender) Send(msg Message) error {
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("SMTP_FROM non valido: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("destinatario non valido: %w", err)
+	}
+	body, err := buildMessage(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	conn, err := net.DialTimeout("tcp", addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage compone l'email multipart/alternative (testo e HTML) in UTF-8
+func buildMessage(from, to *mail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+
+	header := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%q\r\n\r\n",
+		from.String(), to.String(), mime.QEncoding.Encode("utf-8", msg.Subject),
+		time.Now().Format(time.RFC1123Z), parts.Boundary())
+	var out bytes.Buffer
+	out.WriteString(header)
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
@@ -0,0 +1,210 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// readMessage interpreta l'email composta da buildMessage e ne restituisce
+// l'oggetto decodificato e le parti per Content-Type
+func readMessage(t *testing.T, data []byte) (*mail.Message, string, map[string]string) {
+	t.Helper()
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("oggetto: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+	parts := map[string]string{}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		// multipart decodifica da sé il quoted-printable e toglie l'intestazione;
+		// le righe, inviate con CRLF come richiede MIME, tornano con il solo \n
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("parte: %v", err)
+		}
+		parts[part.Header.Get("Content-Type")] = strings.ReplaceAll(string(content), "\r\n", "\n")
+	}
+	return parsed, subject, parts
+}
+
+func TestBuildMessage(t *testing.T) {
+	from, _ := mail.ParseAddress(defaultFrom)
+	to, _ := mail.ParseAddress("Marco Rossi <marco@example.com>")
+	msg := Message{
+		Subject: "Aggiornamento sulla tua idoneità alla donazione",
+		Text:    "Ciao Marco,\n\nla tua idoneità è sospesa fino a lunedì 2 novembre 2026.\n",
+		HTML:    "<p>Ciao Marco,</p>\n<p>la tua idoneità è sospesa.</p>",
+	}
+	data, err := buildMessage(from, to, msg)
+	if err != nil {
+		t.Fatalf("buildMessage: %v", err)
+	}
+
+	// L'oggetto con caratteri accentati è codificato secondo RFC 2047
+	raw := string(data)
+	header := raw[:strings.Index(raw, "\r\n\r\n")]
+	if !strings.Contains(header, "Subject: =?utf-8?q?") || strings.Contains(header, "idoneità") {
+		t.Errorf("oggetto non codificato:\n%s", header)
+	}
+	parsed, subject, parts := readMessage(t, data)
+	if subject != msg.Subject {
+		t.Errorf("oggetto = %q, want %q", subject, msg.Subject)
+	}
+	if parsed.Header.Get("From") != from.String() || parsed.Header.Get("To") != to.String() {
+		t.Errorf("From %q, To %q", parsed.Header.Get("From"), parsed.Header.Get("To"))
+	}
+	if parts["text/plain; charset=utf-8"] != msg.Text || parts["text/html; charset=utf-8"] != msg.HTML {
+		t.Errorf("parti = %q", parts)
+	}
+}
+
+// Un oggetto ASCII resta leggibile
+func TestBuildMessagePlainSubject(t *testing.T) {
+	from, _ := mail.ParseAddress(defaultFrom)
+	to, _ := mail.ParseAddress("marco@example.com")
+	data, err := buildMessage(from, to, Message{Subject: "Appuntamento annullato", Text: "Ciao"})
+	if err != nil {
+		t.Fatalf("buildMessage: %v", err)
+	}
+	if !strings.Contains(string(data), "\r\nSubject: Appuntamento annullato\r\n") {
+		t.Errorf("oggetto:\n%s", data)
+	}
+	// Senza HTML c'è solo la parte di testo
+	if _, _, parts := readMessage(t, data); len(parts) != 1 || parts["text/plain; charset=utf-8"] != "Ciao" {
+		t.Errorf("parti = %q", parts)
+	}
+}
+
+// smtpServer - Server SMTP minimo, senza STARTTLS né autenticazione: accetta
+// un solo messaggio e ne restituisce mittente, destinatario e contenuto
+func smtpServer(t *testing.T) (int, <-chan [3]string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan [3]string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		var envelope [3]string
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL":
+				envelope[0] = line
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				envelope[1] = line
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Fine con <CRLF>.<CRLF>")
+				body, err := io.ReadAll(text.DotReader())
+				if err != nil {
+					return
+				}
+				envelope[2] = string(body)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				received <- envelope
+				return
+			default:
+				text.PrintfLine("502 Comando non supportato")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, received
+}
+
+func TestSMTPSender(t *testing.T) {
+	port, received := smtpServer(t)
+	sender := SMTPSender{Host: "127.0.0.1", Port: port, From: defaultFrom}
+	msg := Message{To: "marco@example.com", Subject: "Donazione confermata per lunedì 2 novembre 2026", Text: "Ciao Marco", HTML: "<p>Ciao Marco</p>"}
+	if err := sender.Send(msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	envelope := <-received
+	if envelope[0] != "MAIL FROM:<noreply@bloodone.local>" || envelope[1] != "RCPT TO:<marco@example.com>" {
+		t.Errorf("busta = %q, %q", envelope[0], envelope[1])
+	}
+	// DotReader restituisce le righe con il solo \n: si ricompone il CRLF
+	data := strings.ReplaceAll(envelope[2], "\n", "\r\n")
+	_, subject, parts := readMessage(t, []byte(data))
+	if subject != msg.Subject || parts["text/plain; charset=utf-8"] != msg.Text {
+		t.Errorf("messaggio ricevuto: oggetto %q, parti %q", subject, parts)
+	}
+}
+
+func TestSMTPSenderInvalidAddress(t *testing.T) {
+	sender := SMTPSender{Host: "127.0.0.1", Port: 1, From: defaultFrom}
+	if err := sender.Send(Message{To: "non un indirizzo"}); err == nil || !strings.Contains(err.Error(), "destinatario") {
+		t.Errorf("Send = %v, want errore sul destinatario", err)
+	}
+	sender.From = "@"
+	if err := sender.Send(Message{To: "marco@example.com"}); err == nil || !strings.Contains(err.Error(), "SMTP_FROM") {
+		t.Errorf("Send = %v, want errore sul mittente", err)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	previous := sender
+	t.Cleanup(func() { sender = previous })
+
+	t.Setenv("SMTP_HOST", "")
+	Configure()
+	if _, ok := sender.(logSender); !ok {
+		t.Errorf("senza SMTP_HOST sender = %T, want logSender", sender)
+	}
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_PORT", "587")
+	t.Setenv("SMTP_FROM", "")
+	Configure()
+	want := SMTPSender{Host: "smtp.example.com", Port: 587, From: defaultFrom}
+	if got, ok := sender.(SMTPSender); !ok || got != want {
+		t.Errorf("sender = %+v, want %+v", sender, want)
+	}
+
+	t.Setenv("SMTP_PORT", "porta")
+	Configure()
+	if got := sender.(SMTPSender); got.Port != 25 {
+		t.Errorf("SMTP_PORT non valido: porta %d, want 25", got.Port)
+	}
+}
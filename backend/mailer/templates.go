@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Ogni tipo di messaggio ha due modelli in templates/: <tipo>.txt, che
+// definisce anche "subject", e <tipo>.html, inserito nel layout comune.
+//
+//go:embed templates/*
+var templates embed.FS
+
+var weekdays = []string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"}
+
+var months = []string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio",
+	"agosto", "settembre", "ottobre", "novembre", "dicembre"}
+
+// italianDate - "lunedì 2 novembre 2026"
+func italianDate(t time.Time) string {
+	return fmt.Sprintf("%s %d %s %d", weekdays[t.Weekday()], t.Day(), months[t.Month()-1], t.Year())
+}
+
+var funcs = map[string]any{
+	"date": italianDate,
+}
+
+// Render compone il messaggio di tipo kind per il destinatario to; data è
+// passato ai modelli
+func Render(kind, to string, data any) (Message, error) {
+	text, err := texttemplate.New(kind+".txt").Funcs(funcs).ParseFS(templates, "templates/"+kind+".txt")
+	if err != nil {
+		return Message{}, fmt.Errorf("modello %s: %w", kind, err)
+	}
+	html, err := htmltemplate.New("layout.html").Funcs(funcs).ParseFS(templates, "templates/layout.html", "templates/"+kind+".html")
+	if err != nil {
+		return Message{}, fmt.Errorf("modello %s: %w", kind, err)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("oggetto %s: %w", kind, err)
+	}
+	if err := text.Execute(&textBody, data); err != nil {
+		return Message{}, fmt.Errorf("testo %s: %w", kind, err)
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Message{}, fmt.Errorf("HTML %s: %w", kind, err)
+	}
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(textBody.String()) + "\n",
+		HTML:    htmlBody.String(),
+	}, nil
+}
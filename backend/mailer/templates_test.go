@@ -0,0 +1,140 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testData - Stessi campi di messageData in handlers, che passa i dati ai modelli
+type testData struct {
+	FirstName string
+	URL       string
+	Dates     []time.Time
+	Deadline  *time.Time
+	Date      *time.Time
+	Slot      string
+	Reason    string
+	Until     *time.Time
+	Permanent bool
+	DaysLeft  int
+	Apheresis bool
+	FollowUp  bool
+}
+
+func TestItalianDate(t *testing.T) {
+	got := italianDate(time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC))
+	if got != "lunedì 2 novembre 2026" {
+		t.Errorf("italianDate = %q", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	date := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	deadline := date.AddDate(0, 0, -7)
+
+	tests := []struct {
+		kind        string
+		data        testData
+		wantSubject string
+		wantText    []string
+	}{
+		{"appointment_proposed",
+			testData{Dates: []time.Time{date, date.AddDate(0, 0, 1)}, Deadline: &deadline},
+			"Nuove date per la tua donazione",
+			[]string{"- lunedì 2 novembre 2026\n- martedì 3 novembre 2026", "entro lunedì 26 ottobre 2026"}},
+		{"appointment_confirmed",
+			testData{Date: &date, Slot: "08:30"},
+			"Donazione confermata per lunedì 2 novembre 2026",
+			[]string{"confermato per lunedì 2 novembre 2026 alle 08:30."}},
+		{"appointment_cancelled",
+			testData{Date: &date, Reason: "Centro chiuso"},
+			"Appuntamento annullato",
+			[]string{"donazione di lunedì 2 novembre 2026 è stato annullato.\nMotivo: Centro chiuso"}},
+		{"appointment_reminder",
+			testData{Date: &date, DaysLeft: 1, Apheresis: true},
+			"Domani la tua donazione",
+			[]string{"di lunedì 2 novembre 2026.", "aferesi"}},
+		{"appointment_reminder",
+			testData{Date: &date},
+			"Oggi la tua donazione",
+			[]string{"donazione di oggi."}},
+		{"registration_approved", testData{}, "Benvenuto in BloodOne", []string{"è stata approvata"}},
+		{"registration_rejected",
+			testData{Reason: "Documenti mancanti"},
+			"Richiesta di registrazione non accolta",
+			[]string{"Nota: Documenti mancanti"}},
+		{"suspension",
+			testData{Until: &date},
+			"Aggiornamento sulla tua idoneità alla donazione",
+			[]string{"sospesa fino a lunedì 2 novembre 2026"}},
+		{"suspension",
+			testData{Permanent: true},
+			"Aggiornamento sulla tua idoneità alla donazione",
+			[]string{"non potrai più donare"}},
+		{"recall",
+			testData{Date: &date, FollowUp: true},
+			"Ti aspettiamo per la prossima donazione",
+			[]string{"dal lunedì 2 novembre 2026 puoi di nuovo donare e non hai ancora un appuntamento"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			data := tt.data
+			data.FirstName = "Marco"
+			data.URL = "https://bloodone.example.com"
+			msg, err := Render(tt.kind, "marco@example.com", data)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if msg.To != "marco@example.com" || msg.Subject != tt.wantSubject {
+				t.Errorf("destinatario %q, oggetto %q; want %q", msg.To, msg.Subject, tt.wantSubject)
+			}
+			if !strings.HasPrefix(msg.Text, "Ciao Marco,\n\n") || !strings.HasSuffix(msg.Text, "\n") || strings.HasSuffix(msg.Text, "\n\n") {
+				t.Errorf("testo = %q", msg.Text)
+			}
+			for _, want := range tt.wantText {
+				if !strings.Contains(msg.Text, want) {
+					t.Errorf("il testo non contiene %q:\n%s", want, msg.Text)
+				}
+			}
+			if !strings.Contains(msg.HTML, "<p>Ciao Marco,</p>") || !strings.Contains(msg.HTML, `href="https://bloodone.example.com"`) {
+				t.Errorf("HTML senza layout:\n%s", msg.HTML)
+			}
+		})
+	}
+}
+
+// Un appuntamento ancora da confermare non ha una data: l'avviso di
+// annullamento non la riporta
+func TestRenderCancelledWithoutDate(t *testing.T) {
+	msg, err := Render("appointment_cancelled", "marco@example.com", testData{FirstName: "Marco"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(msg.Text, "il tuo appuntamento per la donazione è stato annullato.\n\n") {
+		t.Errorf("testo = %q", msg.Text)
+	}
+	if strings.Contains(msg.Text, "Motivo") || strings.Contains(msg.HTML, "<strong>") {
+		t.Errorf("data o motivo vuoti riportati:\n%s\n%s", msg.Text, msg.HTML)
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	msg, err := Render("registration_rejected", "marco@example.com", testData{FirstName: "<Marco>", Reason: "a < b & c"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(msg.HTML, "Ciao &lt;Marco&gt;,") || !strings.Contains(msg.HTML, "Nota: a &lt; b &amp; c") {
+		t.Errorf("HTML non escapato:\n%s", msg.HTML)
+	}
+	// Il testo semplice resta com'è
+	if !strings.Contains(msg.Text, "Nota: a < b & c") {
+		t.Errorf("testo = %q", msg.Text)
+	}
+}
+
+func TestRenderUnknownKind(t *testing.T) {
+	if _, err := Render("unknown", "marco@example.com", testData{}); err == nil {
+		t.Error("Render di un tipo senza modelli deve fallire")
+	}
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"bloodone/database"
 	"bloodone/handlers"
+	"bloodone/mailer"
 	"bloodone/middleware"
-	"bloodone/models"
+	"bloodone/scheduler"
+	"bloodone/sms"
 	"log"
-	"net/http"
+	"os"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -19,13 +21,33 @@ func main() {
 		log.Println("Nessun file .env trovato, uso variabili d'ambiente di sistema")
 	}
 
-	// Connetti al database JSON (nessun CGO richiesto - funziona su Windows)
+	// Connetti al database configurato (DB_DRIVER: json di default, nessun CGO richiesto)
 	database.Connect()
+
+	// DB_MIGRATE_DRY_RUN=true mostra le migrazioni in sospeso ed esce senza avviare il server
+	if os.Getenv("DB_MIGRATE_DRY_RUN") == "true" {
+		database.MigrateDryRun()
+		return
+	}
 	database.Migrate()
 
 	// Inizializza OAuth
 	handlers.InitOAuth()
 
+	// Invio email (SMTP_HOST vuoto: le email vengono solo scritte nel log)
+	mailer.Configure()
+
+	// Invio SMS (SMS_PROVIDER vuoto: canale SMS disattivato, si usa l'email)
+	if err := sms.Configure(); err != nil {
+		log.Fatal("Configurazione SMS non valida: ", err)
+	}
+
+	// Job periodici (no-show, scadenza proposte, ...)
+	if err := handlers.RegisterJobs(); err != nil {
+		log.Fatal("Configurazione dei job non valida: ", err)
+	}
+	scheduler.Start()
+
 	// Setup router
 	router := gin.Default()
 
@@ -62,19 +84,18 @@ func main() {
 		})
 
 		// Appuntamenti dell'utente corrente
-		protected.GET("/me/appointments", func(c *gin.Context) {
-			userID, _ := c.Get("user_id")
-			var appointments []models.Appointment
-			for _, a := range database.DB.Appointments {
-				if a.DonorID == userID.(uint) {
-					appointments = append(appointments, a)
-				}
-			}
-			c.JSON(http.StatusOK, appointments)
-		})
+		protected.GET("/me/eligibility", handlers.GetMyEligibility)
+		protected.GET("/me/appointments", handlers.GetMyAppointments)
+		protected.GET("/me/appointments/:id/history", handlers.GetMyAppointmentHistory)
+		protected.POST("/me/appointments/:id/cancel", handlers.CancelMyAppointment)
+		protected.POST("/me/appointments/:id/reschedule", handlers.RescheduleMyAppointment)
+		protected.GET("/me/appointments/:id/questionnaire", handlers.GetMyQuestionnaire)
+		protected.POST("/me/appointments/:id/questionnaire", handlers.SubmitQuestionnaire)
+		protected.GET("/questionnaire", handlers.GetCurrentQuestionnaire)
 
 		// Conferma appuntamento
 		protected.POST("/appointments/:id/confirm", handlers.ConfirmAppointment)
+		protected.GET("/availability/slots", handlers.GetDaySlots)
 	}
 
 	// Routes admin
@@ -97,6 +118,9 @@ func main() {
 		admin.PUT("/donations/:id", handlers.UpdateDonation)
 		admin.DELETE("/donations/:id", handlers.DeleteDonation)
 		admin.GET("/donors/:id/donations", handlers.GetDonorHistory)
+		admin.GET("/donation-intervals", handlers.GetDonationIntervals)
+		admin.PUT("/donation-intervals", handlers.SetDonationInterval)
+		admin.DELETE("/donation-intervals/:id", handlers.DeleteDonationInterval)
 
 		// Gestione appuntamenti
 		admin.GET("/appointments", handlers.GetAppointments)
@@ -106,22 +130,59 @@ func main() {
 		admin.PUT("/appointments/:id", handlers.UpdateAppointment)
 		admin.DELETE("/appointments/:id", handlers.DeleteAppointment)
 		admin.POST("/appointments/:id/cancel", handlers.CancelAppointment)
+		admin.POST("/appointments/:id/complete", handlers.CompleteAppointment)
+		admin.GET("/appointments/:id/history", handlers.GetAppointmentHistory)
+		admin.GET("/appointments/:id/questionnaire", handlers.GetAppointmentQuestionnaire)
+		admin.GET("/stats/no-shows", handlers.GetNoShowStats)
 		admin.GET("/donors/:id/appointments", handlers.GetDonorAppointments)
+		admin.GET("/donors/:id/suggested-dates", handlers.GetSuggestedDates)
+		admin.GET("/donors/:id/eligibility", handlers.GetDonorEligibility)
+		admin.GET("/recalls", handlers.GetRecalls)
+		admin.POST("/recalls/:id/stop", handlers.StopRecall)
 
 		// Gestione schedule
 		admin.GET("/schedule", handlers.GetSchedule)
 		admin.PUT("/schedule", handlers.UpdateSchedule)
+		admin.GET("/availability", handlers.GetAvailability)
 		admin.GET("/excluded-dates", handlers.GetExcludedDates)
 		admin.POST("/excluded-dates", handlers.AddExcludedDate)
 		admin.DELETE("/excluded-dates/:id", handlers.DeleteExcludedDate)
 		admin.GET("/special-capacities", handlers.GetSpecialCapacities)
 		admin.POST("/special-capacities", handlers.SetSpecialCapacity)
 		admin.DELETE("/special-capacities/:id", handlers.DeleteSpecialCapacity)
+		admin.GET("/time-slots", handlers.GetTimeSlotRules)
+		admin.PUT("/time-slots", handlers.SetTimeSlotRule)
+		admin.DELETE("/time-slots/:id", handlers.DeleteTimeSlotRule)
 
 		// Gestione sospensioni
 		admin.GET("/suspensions", handlers.GetSuspensions)
 		admin.POST("/suspensions", handlers.CreateSuspension)
+		admin.PUT("/suspensions/:id", handlers.UpdateSuspension)
 		admin.PUT("/suspensions/:id/end", handlers.EndSuspension)
+		admin.POST("/suspensions/:id/cancel", handlers.CancelSuspension)
+		admin.POST("/suspensions/:id/approve", handlers.ApproveSuspension)
+		admin.GET("/deferral-reasons", handlers.GetDeferralReasons)
+		admin.POST("/deferral-reasons", handlers.CreateDeferralReason)
+		admin.PUT("/deferral-reasons/:id", handlers.UpdateDeferralReason)
+		admin.DELETE("/deferral-reasons/:id", handlers.DeleteDeferralReason)
+		admin.GET("/reports/deferrals", handlers.GetDeferralReport)
+		admin.GET("/questionnaires", handlers.GetQuestionnaires)
+		admin.POST("/questionnaires", handlers.CreateQuestionnaire)
+		admin.PUT("/questionnaires/:id", handlers.UpdateQuestionnaire)
+		admin.POST("/questionnaires/:id/publish", handlers.PublishQuestionnaire)
+
+		// Job pianificati
+		admin.GET("/jobs", handlers.GetJobs)
+		admin.POST("/jobs/:name/run", handlers.RunJob)
+
+		// Notifiche per gli admin
+		admin.GET("/notifications", handlers.GetAdminNotifications)
+		admin.PUT("/notifications/:id/read", handlers.MarkAdminNotificationRead)
+		admin.GET("/donor-notifications", handlers.GetDonorNotifications)
+		admin.GET("/donor-notifications/:id", handlers.GetDonorNotification)
+		admin.POST("/donor-notifications/:id/retry", handlers.RetryDonorNotification)
+		admin.POST("/donor-notifications/:id/discard", handlers.DiscardDonorNotification)
+		admin.GET("/sms-usage", handlers.GetSMSUsage)
 
 		// Gestione richieste di registrazione
 		admin.GET("/registration-requests", handlers.GetRegistrationRequests)
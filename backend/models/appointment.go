@@ -12,6 +12,9 @@ const (
 	AppointmentStatusConfirmed AppointmentStatus = "confirmed"  // Confermato dal donatore
 	AppointmentStatusCompleted AppointmentStatus = "completed"  // Donazione completata
 	AppointmentStatusCancelled AppointmentStatus = "cancelled"  // Annullato
+	AppointmentStatusDeferred  AppointmentStatus = "deferred"   // Donatore sospeso il giorno della donazione
+	AppointmentStatusNoShow    AppointmentStatus = "no_show"    // Il donatore non si è presentato
+	AppointmentStatusExpired   AppointmentStatus = "expired"    // Proposta senza risposta scaduta
 )
 
 type Appointment struct {
@@ -31,17 +34,71 @@ type Appointment struct {
 	
 	// Data confermata
 	ConfirmedDate *time.Time    `json:"confirmed_date,omitempty"`
+	ConfirmedSlot string        `gorm:"type:varchar(5)" json:"confirmed_slot,omitempty"` // orario di arrivo "07:45", se il giorno ha fasce orarie
 	
 	// Stato
 	Status        AppointmentStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
 	
+	// Tipo di donazione prevista
+	DonationType  DonationType  `gorm:"type:varchar(20);default:'whole_blood'" json:"donation_type"`
+	
 	// Flag per modifiche amministrative
 	AdminModified bool          `gorm:"default:false" json:"admin_modified"`
 	ModifiedBy    *uint         `json:"modified_by,omitempty"` // ID dell'admin che ha modificato
 	
+	// Prenotazione forzata da un admin oltre i limiti di calendario/capacità
+	CapacityOverride   bool     `gorm:"default:false" json:"capacity_override"`
+	CapacityOverrideBy *uint    `json:"capacity_override_by,omitempty"`
+	
 	// Note
 	Notes         string        `json:"notes"`
 	
 	// Notifica inviata
 	NotificationSent bool       `gorm:"default:false" json:"notification_sent"`
+	
+	// Promemoria già inviati per la data confermata, in giorni di anticipo
+	// (vedi APPOINTMENT_REMINDER_DAYS); si azzera se la data cambia
+	RemindersSent IntList       `gorm:"type:text" json:"reminders_sent,omitempty"`
+	
+	// Annullamento
+	CancelReason        string  `json:"cancel_reason,omitempty"`
+	CancelledBy         *uint   `json:"cancelled_by,omitempty"`
+	RescheduleRequested bool    `gorm:"default:false" json:"reschedule_requested"` // il donatore chiede nuove date
+	
+	// Esito: donazione registrata o sospensione decisa il giorno dell'appuntamento
+	DonationID   *uint          `json:"donation_id,omitempty"`
+	SuspensionID *uint          `json:"suspension_id,omitempty"`
+}
+
+// Azioni registrate nello storico degli appuntamenti
+const (
+	AppointmentActionProposed            = "proposed"
+	AppointmentActionConfirmed           = "confirmed"
+	AppointmentActionCancelled           = "cancelled"
+	AppointmentActionRescheduled         = "rescheduled"
+	AppointmentActionRescheduleRequested = "reschedule_requested"
+	AppointmentActionAdminSet            = "admin_set"
+	AppointmentActionCompleted           = "completed"
+	AppointmentActionDeferred            = "deferred"
+	AppointmentActionNoShow              = "no_show"
+	AppointmentActionExpired             = "expired"
+	AppointmentActionQuestionnaire       = "questionnaire_submitted"
+)
+
+// AppointmentEvent - Modifica registrata nello storico di un appuntamento
+type AppointmentEvent struct {
+	ID        uint              `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	
+	AppointmentID uint          `gorm:"not null;index" json:"appointment_id"`
+	ChangedBy     uint          `json:"changed_by"` // ID dell'utente, 0 = sistema
+	Action        string        `gorm:"type:varchar(30)" json:"action"`
+	
+	FromStatus AppointmentStatus `gorm:"type:varchar(20)" json:"from_status,omitempty"`
+	ToStatus   AppointmentStatus `gorm:"type:varchar(20)" json:"to_status"`
+	FromDate   *time.Time        `json:"from_date,omitempty"`
+	ToDate     *time.Time        `json:"to_date,omitempty"`
+	FromSlot   string            `gorm:"type:varchar(5)" json:"from_slot,omitempty"`
+	ToSlot     string            `gorm:"type:varchar(5)" json:"to_slot,omitempty"`
+	Note       string            `json:"note,omitempty"`
 }
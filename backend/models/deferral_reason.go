@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// Categorie dei motivi di sospensione, usate nei report
+const (
+	DeferralCategoryMedical   = "medical"   // condizioni cliniche, esami, interventi
+	DeferralCategoryTravel    = "travel"    // viaggi in zone a rischio
+	DeferralCategoryLifestyle = "lifestyle" // tatuaggi, piercing, comportamenti a rischio
+	DeferralCategoryOther     = "other"
+)
+
+// DeferralCategories elenca le categorie ammesse
+var DeferralCategories = []string{
+	DeferralCategoryMedical,
+	DeferralCategoryTravel,
+	DeferralCategoryLifestyle,
+	DeferralCategoryOther,
+}
+
+// DeferralReason - Voce del catalogo dei motivi di sospensione, con la durata
+// proposta di default quando viene scelta per una nuova sospensione
+type DeferralReason struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Code     string `gorm:"type:varchar(50);uniqueIndex" json:"code"`
+	Name     string `gorm:"not null" json:"name"`
+	Category string `gorm:"type:varchar(20);index" json:"category"`
+
+	// Durata di default (ignorata se Permanent)
+	DefaultDurationMonths int  `json:"default_duration_months"`
+	DefaultDurationWeeks  int  `json:"default_duration_weeks"`
+	DefaultDurationDays   int  `json:"default_duration_days"`
+	Permanent             bool `gorm:"default:false" json:"permanent"`
+
+	// I motivi non più usati vengono disattivati, non eliminati, per lo storico
+	IsActive bool `json:"is_active"`
+}
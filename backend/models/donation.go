@@ -12,6 +12,36 @@ const (
 	DonationStatusCancelled DonationStatus = "cancelled"
 )
 
+// DonationType - Tipo di donazione
+type DonationType string
+
+const (
+	DonationTypeWholeBlood DonationType = "whole_blood" // Sangue intero
+	DonationTypePlasma     DonationType = "plasma"      // Plasmaferesi
+	DonationTypePlatelets  DonationType = "platelets"   // Piastrinoaferesi
+)
+
+// DonationTypes elenca i tipi ammessi
+var DonationTypes = []DonationType{DonationTypeWholeBlood, DonationTypePlasma, DonationTypePlatelets}
+
+// Valid indica se il tipo è tra quelli ammessi
+func (t DonationType) Valid() bool {
+	for _, v := range DonationTypes {
+		if t == v {
+			return true
+		}
+	}
+	return false
+}
+
+// OrDefault restituisce il tipo, o sangue intero se non indicato (record precedenti ai tipi)
+func (t DonationType) OrDefault() DonationType {
+	if t == "" {
+		return DonationTypeWholeBlood
+	}
+	return t
+}
+
 type Donation struct {
 	ID         uint           `gorm:"primarykey" json:"id"`
 	CreatedAt  time.Time      `json:"created_at"`
@@ -21,9 +51,25 @@ type Donation struct {
 	// Riferimenti
 	DonorID    uint           `gorm:"not null;index" json:"donor_id"`
 	Donor      User           `gorm:"foreignKey:DonorID" json:"donor,omitempty"`
+	AppointmentID *uint       `gorm:"index" json:"appointment_id,omitempty"` // appuntamento da cui è stata registrata
 	
 	// Dettagli donazione
 	DonationDate time.Time     `gorm:"not null;index" json:"donation_date"`
+	DonationType DonationType  `gorm:"type:varchar(20);default:'whole_blood'" json:"donation_type"`
 	Status       DonationStatus `gorm:"type:varchar(20);default:'completed'" json:"status"`
 	Notes        string         `json:"notes"`
 }
+
+// DonationInterval - Intervallo minimo in giorni tra una donazione di tipo
+// PreviousType e la successiva di tipo NextType. Gender vuoto vale per tutti;
+// una riga per un sesso specifico ha la precedenza.
+type DonationInterval struct {
+	ID        uint           `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	
+	PreviousType DonationType `gorm:"type:varchar(20);not null" json:"previous_type"`
+	NextType     DonationType `gorm:"type:varchar(20);not null" json:"next_type"`
+	Gender       Gender       `gorm:"type:varchar(1)" json:"gender,omitempty"`
+	IntervalDays int          `gorm:"not null" json:"interval_days"`
+}
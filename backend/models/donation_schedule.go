@@ -52,3 +52,73 @@ type SpecialCapacity struct {
 	Date      time.Time      `gorm:"uniqueIndex;not null" json:"date"`
 	Capacity  int            `gorm:"not null" json:"capacity"`
 }
+
+// IsOpen indica se nel giorno della settimana si effettuano donazioni
+func (s *DonationSchedule) IsOpen(day time.Weekday) bool {
+	switch day {
+	case time.Monday:
+		return s.Monday
+	case time.Tuesday:
+		return s.Tuesday
+	case time.Wednesday:
+		return s.Wednesday
+	case time.Thursday:
+		return s.Thursday
+	case time.Friday:
+		return s.Friday
+	case time.Saturday:
+		return s.Saturday
+	default:
+		return s.Sunday
+	}
+}
+
+// CapacityFor restituisce la capacità massima del giorno della settimana
+func (s *DonationSchedule) CapacityFor(day time.Weekday) int {
+	switch day {
+	case time.Monday:
+		return s.MondayCapacity
+	case time.Tuesday:
+		return s.TuesdayCapacity
+	case time.Wednesday:
+		return s.WednesdayCapacity
+	case time.Thursday:
+		return s.ThursdayCapacity
+	case time.Friday:
+		return s.FridayCapacity
+	case time.Saturday:
+		return s.SaturdayCapacity
+	default:
+		return s.SundayCapacity
+	}
+}
+
+// TimeSlotRule - Fasce orarie di un giorno della settimana: dalle StartTime
+// alle EndTime, slot da SlotMinutes minuti con SlotCapacity posti ciascuno
+type TimeSlotRule struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	
+	Weekday      int    `gorm:"uniqueIndex;not null" json:"weekday"` // 0=Domenica, ..., 6=Sabato
+	StartTime    string `gorm:"type:varchar(5);not null" json:"start_time"` // "07:30"
+	EndTime      string `gorm:"type:varchar(5);not null" json:"end_time"`   // "11:00"
+	SlotMinutes  int    `gorm:"default:15" json:"slot_minutes"`
+	SlotCapacity int    `gorm:"default:1" json:"slot_capacity"`
+}
+
+// Slots restituisce l'orario di inizio ("15:04") di ogni slot della fascia
+func (r *TimeSlotRule) Slots() []string {
+	start, err1 := time.Parse("15:04", r.StartTime)
+	end, err2 := time.Parse("15:04", r.EndTime)
+	if err1 != nil || err2 != nil || r.SlotMinutes <= 0 {
+		return nil
+	}
+	step := time.Duration(r.SlotMinutes) * time.Minute
+	var slots []string
+	for t := start; !t.Add(step).After(end); t = t.Add(step) {
+		slots = append(slots, t.Format("15:04"))
+	}
+	return slots
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// Esito dell'ultima esecuzione di un job
+const (
+	JobStatusRunning = "running"
+	JobStatusSuccess = "success"
+	JobStatusFailed  = "failed"
+)
+
+// Origine dell'esecuzione
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// JobRun - Ultima esecuzione di un job pianificato, una riga per job.
+// LockedBy/LockedUntil fanno da lock: finché non scade, nessun'altra istanza
+// del server può avviare lo stesso job.
+type JobRun struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name string `gorm:"type:varchar(50);uniqueIndex" json:"name"`
+
+	LastStartedAt   *time.Time `json:"last_started_at,omitempty"`
+	LastFinishedAt  *time.Time `json:"last_finished_at,omitempty"`
+	LastDurationMs  int64      `json:"last_duration_ms"`
+	LastStatus      string     `gorm:"type:varchar(20)" json:"last_status,omitempty"`
+	LastResult      string     `json:"last_result,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastTrigger     string     `gorm:"type:varchar(20)" json:"last_trigger,omitempty"`
+	LastTriggeredBy *uint      `json:"last_triggered_by,omitempty"` // admin che l'ha avviato a mano
+
+	RunCount     int `json:"run_count"`
+	FailureCount int `json:"failure_count"`
+
+	LockedBy    string     `json:"locked_by,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+)
+
+// Tipi di notifica per gli amministratori
+const (
+	AdminNotificationProposalExpired      = "proposal_expired"
+	AdminNotificationQuestionnaireFlagged = "questionnaire_flagged"
+	AdminNotificationDeliveryFailed       = "notification_failed"
+	AdminNotificationRecallExhausted      = "recall_exhausted"
+)
+
+// AdminNotification - Avviso per gli amministratori generato dal sistema
+type AdminNotification struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Kind    string `gorm:"type:varchar(30);index" json:"kind"`
+	Message string `json:"message"`
+
+	// Riferimenti facoltativi
+	DonorID       *uint `json:"donor_id,omitempty"`
+	AppointmentID *uint `json:"appointment_id,omitempty"`
+
+	// Letta da un admin
+	ReadAt *time.Time `json:"read_at,omitempty"`
+	ReadBy *uint      `json:"read_by,omitempty"`
+}
+
+// Canali, tipi e stati delle notifiche ai donatori
+const (
+	NotificationChannelEmail = "email"
+	NotificationChannelSMS   = "sms"
+	NotificationChannelBoth  = "both" // solo come preferenza del donatore: email e SMS
+
+	NotificationKindAppointmentProposed  = "appointment_proposed"
+	NotificationKindAppointmentConfirmed = "appointment_confirmed"
+	NotificationKindAppointmentCancelled = "appointment_cancelled"
+	NotificationKindAppointmentReminder  = "appointment_reminder"
+	NotificationKindRegistrationApproved = "registration_approved"
+	NotificationKindRegistrationRejected = "registration_rejected"
+	NotificationKindSuspension           = "suspension"
+	NotificationKindRecall               = "recall"
+
+	NotificationStatusPending   = "pending"   // da consegnare, anche dopo un tentativo fallito
+	NotificationStatusSent      = "sent"      // consegnata
+	NotificationStatusFailed    = "failed"    // tentativi esauriti: attende un admin (retry o discard)
+	NotificationStatusDiscarded = "discarded" // scartata da un admin
+)
+
+// Notification - Messaggio per un donatore (o per chi ha chiesto la
+// registrazione) nell'outbox: viene salvato insieme all'operazione che lo
+// genera e consegnato dal job outbox, che ne registra l'esito
+type Notification struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Channel   string `gorm:"type:varchar(10)" json:"channel"`
+	Kind      string `gorm:"type:varchar(30);index" json:"kind"`
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Text      string `gorm:"type:text" json:"text"`
+	HTML      string `gorm:"type:text" json:"html"`
+
+	// Solo SMS: segmenti del messaggio e costo, registrato alla consegna
+	Segments int     `json:"segments,omitempty"`
+	Cost     float64 `json:"cost,omitempty"`
+
+	// Riferimenti facoltativi
+	UserID        *uint `gorm:"index" json:"user_id,omitempty"`
+	AppointmentID *uint `gorm:"index" json:"appointment_id,omitempty"`
+
+	// Consegna: Error è l'errore dell'ultimo tentativo fallito
+	Status        string     `gorm:"type:varchar(10);index" json:"status"`
+	Attempts      int        `json:"attempts"`
+	Error         string     `json:"error,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+
+	// Scartata da un admin
+	DiscardedAt *time.Time `json:"discarded_at,omitempty"`
+	DiscardedBy *uint      `json:"discarded_by,omitempty"`
+}
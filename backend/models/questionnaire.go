@@ -0,0 +1,129 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Tipi di risposta delle domande del questionario
+const (
+	AnswerTypeYesNo  = "yes_no" // "yes" o "no"
+	AnswerTypeChoice = "choice" // una delle options
+	AnswerTypeNumber = "number"
+	AnswerTypeText   = "text"
+)
+
+// AnswerTypes elenca i tipi di risposta ammessi
+var AnswerTypes = []string{AnswerTypeYesNo, AnswerTypeChoice, AnswerTypeNumber, AnswerTypeText}
+
+// Question - Domanda del questionario anamnestico
+type Question struct {
+	Code       string   `json:"code"`
+	Text       string   `json:"text"`
+	AnswerType string   `json:"answer_type"`
+	Options    []string `json:"options,omitempty"` // solo per choice
+	Required   bool     `json:"required"`
+
+	// Risposte (yes_no o choice) che suggeriscono una sospensione, con
+	// l'eventuale motivo del catalogo da proporre
+	DisqualifyingAnswers []string `json:"disqualifying_answers,omitempty"`
+	DeferralReasonID     *uint    `json:"deferral_reason_id,omitempty"`
+}
+
+// Disqualifies indica se la risposta suggerisce una sospensione
+func (q Question) Disqualifies(value string) bool {
+	for _, answer := range q.DisqualifyingAnswers {
+		if answer == value {
+			return true
+		}
+	}
+	return false
+}
+
+// QuestionList - Domande salvate come JSON in una colonna di testo
+type QuestionList []Question
+
+func (l QuestionList) Value() (driver.Value, error) {
+	return jsonValue(l)
+}
+
+func (l *QuestionList) Scan(src interface{}) error {
+	return jsonScan(src, l)
+}
+
+// Questionnaire - Versione del questionario anamnestico. Una versione
+// pubblicata non si modifica più: le risposte già date restano legate alle
+// domande a cui si riferiscono. Vale l'ultima versione pubblicata.
+type Questionnaire struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Version   int          `gorm:"uniqueIndex" json:"version"`
+	Title     string       `json:"title"`
+	Questions QuestionList `gorm:"type:text" json:"questions"`
+	CreatedBy uint         `json:"created_by"`
+
+	// Nil finché la versione è una bozza
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	PublishedBy *uint      `json:"published_by,omitempty"`
+}
+
+// Answer - Risposta a una domanda
+type Answer struct {
+	Code          string `json:"code"`
+	Value         string `json:"value"`
+	Disqualifying bool   `json:"disqualifying"`
+}
+
+// AnswerList - Risposte salvate come JSON in una colonna di testo
+type AnswerList []Answer
+
+func (l AnswerList) Value() (driver.Value, error) {
+	return jsonValue(l)
+}
+
+func (l *AnswerList) Scan(src interface{}) error {
+	return jsonScan(src, l)
+}
+
+// QuestionnaireResponse - Questionario compilato dal donatore per un
+// appuntamento confermato
+type QuestionnaireResponse struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	AppointmentID   uint       `gorm:"uniqueIndex" json:"appointment_id"`
+	DonorID         uint       `gorm:"index" json:"donor_id"`
+	QuestionnaireID uint       `json:"questionnaire_id"`
+	Version         int        `json:"version"`
+	Answers         AnswerList `gorm:"type:text" json:"answers"`
+
+	// Almeno una risposta suggerisce una sospensione: SuspensionID è la
+	// sospensione in attesa di revisione creata per l'admin/medico
+	Disqualified bool  `json:"disqualified"`
+	SuspensionID *uint `json:"suspension_id,omitempty"`
+}
+
+func jsonValue(v any) (driver.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func jsonScan(src interface{}, dest any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	case []byte:
+		return json.Unmarshal(v, dest)
+	default:
+		return fmt.Errorf("%T: tipo non supportato %T", dest, src)
+	}
+}
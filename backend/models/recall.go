@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Stati di un richiamo
+const (
+	RecallStatusActive    = "active"    // in corso: invito inviato, solleciti in programma
+	RecallStatusBooked    = "booked"    // il donatore ha un appuntamento confermato o ha donato
+	RecallStatusStopped   = "stopped"   // non più idoneo, disattivato o fermato da un admin
+	RecallStatusExhausted = "exhausted" // nessuna risposta dopo tutti i solleciti
+)
+
+// Recall - Richiamo di un donatore tornato idoneo: alla scadenza riceve un
+// invito a prenotare (o una proposta di date) e poi i solleciti previsti,
+// finché non prenota o una condizione di stop chiude il richiamo. Per ogni
+// scadenza (DueDate) il donatore viene richiamato una sola volta.
+type Recall struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	DonorID uint      `gorm:"index" json:"donor_id"`
+	DueDate time.Time `json:"due_date"` // next_due_date che ha aperto il richiamo
+	Status  string    `gorm:"type:varchar(12);index" json:"status"`
+
+	// Messaggi inviati (invito e solleciti) e prossimo sollecito
+	Messages   int        `json:"messages"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	NextSendAt *time.Time `json:"next_send_at,omitempty"`
+
+	// Ultima proposta creata dal richiamo, o appuntamento prenotato
+	AppointmentID *uint `json:"appointment_id,omitempty"`
+
+	// Chiusura
+	ClosedAt    *time.Time `json:"closed_at,omitempty"`
+	CloseReason string     `json:"close_reason,omitempty"`
+	StoppedBy   *uint      `json:"stopped_by,omitempty"`
+}
@@ -18,12 +18,48 @@ type Suspension struct {
 	// Dettagli sospensione
 	StartDate      time.Time  `gorm:"not null" json:"start_date"`
 	DurationMonths int        `gorm:"not null" json:"duration_months"`
-	EndDate        time.Time  `gorm:"not null" json:"end_date"`
+	DurationWeeks  int        `gorm:"default:0" json:"duration_weeks"`
+	DurationDays   int        `gorm:"default:0" json:"duration_days"`
+	EndDate        time.Time  `gorm:"not null" json:"end_date"` // vuota per le sospensioni permanenti
 	Reason         string     `gorm:"not null" json:"reason"`
+	ReasonID       *uint      `gorm:"index" json:"reason_id,omitempty"` // voce del catalogo dei motivi, se scelta
 	
-	// Stato
-	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	// Esclusione definitiva: nessuna data di fine
+	Permanent      bool       `gorm:"default:false" json:"permanent"`
+	
+	// Stato: false quando termina (a fine periodo o prima, da admin) o viene annullata
+	IsActive       bool       `json:"is_active"`
+	
+	// Sospensione suggerita (es. dal questionario) in attesa di revisione:
+	// non è attiva finché un admin/medico non la approva
+	PendingReview  bool       `gorm:"default:false" json:"pending_review"`
+	ReviewedAt     *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy     *uint      `json:"reviewed_by,omitempty"`
 	
 	// Chi ha creato la sospensione
 	CreatedBy      uint       `json:"created_by"`
+	
+	// Annullamento di una sospensione inserita per errore
+	CancelledAt    *time.Time `json:"cancelled_at,omitempty"`
+	CancelledBy    *uint      `json:"cancelled_by,omitempty"`
+	CancelReason   string     `json:"cancel_reason,omitempty"`
+}
+
+// HasDuration indica se è stata specificata una durata in mesi, settimane o giorni
+func (s *Suspension) HasDuration() bool {
+	return s.DurationMonths > 0 || s.DurationWeeks > 0 || s.DurationDays > 0
+}
+
+// DurationEndDate - Fine calcolata dalla data di inizio e dalla durata
+func (s *Suspension) DurationEndDate() time.Time {
+	return s.StartDate.AddDate(0, s.DurationMonths, s.DurationWeeks*7+s.DurationDays)
+}
+
+// InEffect indica se la sospensione blocca il donatore all'istante now:
+// attiva, già iniziata e non ancora finita (o permanente)
+func (s *Suspension) InEffect(now time.Time) bool {
+	if !s.IsActive || now.Before(s.StartDate) {
+		return false
+	}
+	return s.Permanent || now.Before(s.EndDate)
 }
@@ -32,13 +32,26 @@ type User struct {
 	// Ruolo
 	IsAdmin bool `gorm:"default:false" json:"is_admin"`
 
-	// Stato donatore
-	IsActive    bool `gorm:"default:true" json:"is_active"`
-	IsSuspended bool `gorm:"default:false" json:"is_suspended"`
+	// Stato donatore (la sospensione deriva dalle sospensioni attive, vedi UserResponse).
+	// Nessun default nello schema: alla creazione GORM sostituirebbe il false
+	// con il default della colonna, e chi crea il record imposta sempre il valore.
+	IsActive bool `json:"is_active"`
 
 	// Data prossimo appuntamento confermato
 	NextAppointmentDate *time.Time `json:"next_appointment_date,omitempty"`
 
+	// Appuntamenti confermati a cui il donatore non si è presentato
+	NoShowCount int `gorm:"default:0" json:"no_show_count"`
+
+	// Idoneità medica a donare oltre l'età massima ordinaria (vedi README, Idoneità)
+	AgeClearance bool `gorm:"default:false" json:"age_clearance"`
+
+	// Giorni della settimana preferiti per le proposte (0=Domenica, ..., 6=Sabato)
+	PreferredWeekdays IntList `gorm:"type:text" json:"preferred_weekdays,omitempty"`
+
+	// Canale per le notifiche: email (default, anche se vuoto), sms o both
+	NotificationChannel string `gorm:"type:varchar(10);default:'email'" json:"notification_channel"`
+
 	// Relazioni
 	Donations    []Donation    `gorm:"foreignKey:DonorID" json:"donations,omitempty"`
 	Appointments []Appointment `gorm:"foreignKey:DonorID" json:"appointments,omitempty"`
@@ -46,28 +59,48 @@ type User struct {
 }
 
 type UserResponse struct {
-	ID                    uint       `json:"id"`
-	Email                 string     `json:"email"`
-	FirstName             string     `json:"first_name"`
-	LastName              string     `json:"last_name"`
-	PhoneNumber           string     `json:"phone_number"`
-	Gender                Gender     `json:"gender"`
-	BloodType             string     `json:"blood_type"`
-	BirthDate             *time.Time `json:"birth_date,omitempty"`
-	IsAdmin               bool       `json:"is_admin"`
-	IsActive              bool       `json:"is_active"`
-	IsSuspended           bool       `json:"is_suspended"`
-	TotalDonations        int        `json:"total_donations"`
-	LastDonationDate      *time.Time `json:"last_donation_date,omitempty"`
-	NextDueDate           *time.Time `json:"next_due_date,omitempty"`
-	NextAppointmentDate   *time.Time `json:"next_appointment_date,omitempty"`
-	DaysSinceLastDonation int        `json:"days_since_last_donation"`
+	ID                    uint                       `json:"id"`
+	Email                 string                     `json:"email"`
+	FirstName             string                     `json:"first_name"`
+	LastName              string                     `json:"last_name"`
+	PhoneNumber           string                     `json:"phone_number"`
+	Gender                Gender                     `json:"gender"`
+	BloodType             string                     `json:"blood_type"`
+	BirthDate             *time.Time                 `json:"birth_date,omitempty"`
+	Age                   *int                       `json:"age,omitempty"`
+	AgeClearance          bool                       `json:"age_clearance"`
+	AgeLimitDate          *time.Time                 `json:"age_limit_date,omitempty"` // primo giorno oltre l'età massima
+	ApproachingAgeLimit   bool                       `json:"approaching_age_limit"`
+	IsAdmin               bool                       `json:"is_admin"`
+	IsActive              bool                       `json:"is_active"`
+	IsSuspended           bool                       `json:"is_suspended"`
+	PermanentlyDeferred   bool                       `json:"permanently_deferred"`
+	SuspendedUntil        *time.Time                 `json:"suspended_until,omitempty"`
+	TotalDonations        int                        `json:"total_donations"`
+	LastDonationDate      *time.Time                 `json:"last_donation_date,omitempty"`
+	NextDueDate           *time.Time                 `json:"next_due_date,omitempty"` // per il sangue intero
+	NextDueByType         map[DonationType]time.Time `json:"next_due_by_type,omitempty"`
+	NextAppointmentDate   *time.Time                 `json:"next_appointment_date,omitempty"`
+	DaysSinceLastDonation int                        `json:"days_since_last_donation"`
+	PreferredWeekdays     IntList                    `json:"preferred_weekdays,omitempty"`
+	NoShowCount           int                        `json:"no_show_count"`
+	NotificationChannel   string                     `json:"notification_channel"`
 }
 
-// GetDonationInterval restituisce l'intervallo in mesi tra donazioni in base al sesso
+// GetDonationInterval restituisce l'intervallo in mesi tra donazioni in base al sesso,
+// usato quando la matrice degli intervalli non prevede la combinazione di tipi
 func (u *User) GetDonationInterval() int {
 	if u.Gender == GenderMale {
 		return 3 // 3 mesi per uomini
 	}
 	return 6 // 6 mesi per donne
 }
+
+// GetNotificationChannel restituisce il canale preferito per le notifiche
+// (email se non impostato)
+func (u *User) GetNotificationChannel() string {
+	if u.NotificationChannel == "" {
+		return NotificationChannelEmail
+	}
+	return u.NotificationChannel
+}
@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule - Calcola il prossimo istante di esecuzione di un job
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// cronSchedule - Espressione cron a 5 campi (minuto ora giorno mese giorno-settimana)
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Se giorno del mese e giorno della settimana sono entrambi ristretti
+	// basta che uno dei due corrisponda, come nel cron classico
+	domStar, dowStar bool
+}
+
+// everySchedule - Intervallo fisso ("@every 15m")
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (s everySchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+var cronAliases = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minuto", 0, 59},
+	{"ora", 0, 23},
+	{"giorno del mese", 1, 31},
+	{"mese", 1, 12},
+	{"giorno della settimana", 0, 6},
+}
+
+// Parse interpreta un'espressione cron a 5 campi (con *, liste, intervalli
+// a-b e passi /n), un alias (@hourly, @daily, @weekly, @monthly) oppure un
+// intervallo fisso "@every <durata>" (es. "@every 30m").
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil || interval < time.Minute {
+			return nil, fmt.Errorf("intervallo non valido in %q (minimo 1m)", expr)
+		}
+		return everySchedule{interval: interval}, nil
+	}
+	if alias, ok := cronAliases[expr]; ok {
+		expr = alias
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("espressione cron %q: servono 5 campi (minuto ora giorno mese giorno-settimana)", expr)
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("espressione cron %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+	// 7 è accettato come domenica
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	schedule := &cronSchedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("espressione cron %q: nessuna data corrispondente", expr)
+	}
+	return schedule, nil
+}
+
+func parseCronField(value string, field cronField) (uint64, error) {
+	max := field.max
+	if field.max == 6 {
+		max = 7
+	}
+
+	var bits uint64
+	for _, item := range strings.Split(value, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("passo non valido nel campo %s: %q", field.name, item)
+			}
+			rangePart, step = item[:i], n
+		}
+
+		lo, hi := field.min, field.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			a, errA := strconv.Atoi(bounds[0])
+			b, errB := strconv.Atoi(bounds[1])
+			if errA != nil || errB != nil || a > b {
+				return 0, fmt.Errorf("intervallo non valido nel campo %s: %q", field.name, item)
+			}
+			lo, hi = a, b
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("valore non valido nel campo %s: %q", field.name, item)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = field.max
+			}
+		}
+		if lo < field.min || hi > max {
+			return 0, fmt.Errorf("valore fuori intervallo nel campo %s: %q (%d-%d)", field.name, item, field.min, field.max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next restituisce il primo minuto successivo ad after che soddisfa
+// l'espressione, nel fuso orario di after
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Oltre cinque anni l'espressione non corrisponde a nessuna data (es. 30 febbraio)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// Giovedì 1 ottobre 2026, 10:00 UTC
+var cronBase = time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+
+func TestParseNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  []time.Time // esecuzioni successive a partire da after
+	}{
+		{"ogni minuto", "* * * * *", cronBase,
+			[]time.Time{cronBase.Add(time.Minute), cronBase.Add(2 * time.Minute)}},
+		{"ora fissa", "30 9 * * *", cronBase, []time.Time{
+			time.Date(2026, 10, 2, 9, 30, 0, 0, time.UTC),
+			time.Date(2026, 10, 3, 9, 30, 0, 0, time.UTC),
+		}},
+		{"stesso minuto escluso", "0 10 * * *", cronBase,
+			[]time.Time{time.Date(2026, 10, 2, 10, 0, 0, 0, time.UTC)}},
+		{"passo sui minuti", "*/20 * * * *", cronBase, []time.Time{
+			time.Date(2026, 10, 1, 10, 20, 0, 0, time.UTC),
+			time.Date(2026, 10, 1, 10, 40, 0, 0, time.UTC),
+			time.Date(2026, 10, 1, 11, 0, 0, 0, time.UTC),
+		}},
+		{"valore con passo", "5/30 * * * *", cronBase, []time.Time{
+			time.Date(2026, 10, 1, 10, 5, 0, 0, time.UTC),
+			time.Date(2026, 10, 1, 10, 35, 0, 0, time.UTC),
+		}},
+		{"intervallo con passo", "0 8-18/5 * * *", cronBase, []time.Time{
+			time.Date(2026, 10, 1, 13, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 1, 18, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 2, 8, 0, 0, 0, time.UTC),
+		}},
+		{"lista", "0 9,17 * * *", cronBase, []time.Time{
+			time.Date(2026, 10, 1, 17, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 2, 9, 0, 0, 0, time.UTC),
+		}},
+		{"solo giorno del mese", "0 0 15 * *", cronBase, []time.Time{
+			time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 11, 15, 0, 0, 0, 0, time.UTC),
+		}},
+		{"solo giorno della settimana", "0 0 * * 1", cronBase, []time.Time{
+			time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+		}},
+		// Entrambi ristretti: basta uno dei due (giorno 15 oppure lunedì)
+		{"giorno del mese o della settimana", "0 0 15 * 1", cronBase, []time.Time{
+			time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		}},
+		{"7 è domenica", "0 12 * * 7", cronBase,
+			[]time.Time{time.Date(2026, 10, 4, 12, 0, 0, 0, time.UTC)}},
+		{"intervallo fino a domenica", "0 12 * * 6-7", cronBase, []time.Time{
+			time.Date(2026, 10, 3, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 4, 12, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC),
+		}},
+		{"mese", "0 0 1 2 *", cronBase,
+			[]time.Time{time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC)}},
+		{"29 febbraio", "0 0 29 2 *", cronBase,
+			[]time.Time{time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)}},
+		{"31 del mese salta i mesi corti", "0 0 31 * *", cronBase, []time.Time{
+			time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+		}},
+		{"alias", "@weekly", cronBase,
+			[]time.Time{time.Date(2026, 10, 4, 0, 0, 0, 0, time.UTC)}},
+		{"every", "@every 5m", cronBase,
+			[]time.Time{cronBase.Add(5 * time.Minute), cronBase.Add(10 * time.Minute)}},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("%s: Parse(%q): %v", tt.name, tt.expr, err)
+			continue
+		}
+		at := tt.after
+		for i, want := range tt.want {
+			at = schedule.Next(at)
+			if !at.Equal(want) {
+				t.Errorf("%s: esecuzione %d di %q = %s, want %s", tt.name, i+1, tt.expr, at, want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"0 0 30 2 *", // nessuna data corrispondente
+		"@yearly",
+		"@every 30s", // sotto il minuto
+		"@every tra poco",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): want errore", expr)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+// Package scheduler esegue nel processo del server i job periodici (no-show,
+// scadenza proposte, ...). Ogni job ha un nome, un'espressione cron
+// configurabile e un record in job_runs con l'esito dell'ultima esecuzione.
+package scheduler
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Durata del lock su un job in esecuzione: scaduto questo tempo un'altra
+// istanza considera il job interrotto e può avviarlo di nuovo.
+const lockLease = 30 * time.Minute
+
+// Ogni quanto lo scheduler controlla se ci sono job da avviare
+const tickInterval = 30 * time.Second
+
+var (
+	ErrUnknownJob = errors.New("job sconosciuto")
+	ErrJobRunning = errors.New("job già in esecuzione")
+)
+
+// Job - Attività periodica. Run riceve l'istante di avvio e restituisce un
+// breve riepilogo dell'esito (es. "3 appuntamenti segnati come no_show").
+type Job struct {
+	Name            string
+	Description     string
+	DefaultSchedule string
+	Run             func(now time.Time) (string, error)
+}
+
+// JobInfo - Stato di un job registrato, come mostrato agli admin
+type JobInfo struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Schedule    string         `json:"schedule"`
+	Enabled     bool           `json:"enabled"`
+	Running     bool           `json:"running"`
+	NextRunAt   *time.Time     `json:"next_run_at,omitempty"`
+	LastRun     *models.JobRun `json:"last_run,omitempty"`
+}
+
+type entry struct {
+	job      Job
+	expr     string
+	schedule Schedule // nil se il job è disattivato
+
+	// Un solo esecutore per job all'interno del processo
+	running sync.Mutex
+	next    time.Time
+}
+
+var (
+	mu       sync.Mutex
+	entries  []*entry
+	started  bool
+	hostname = host()
+	instance = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+)
+
+func host() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+// reclaimableLock indica se il lock lockedBy ("<host>-<pid>") si può
+// riprendere prima della scadenza: è di questo processo, oppure di un
+// processo dello stesso host che non è più in esecuzione (es. un crash prima
+// del rilascio). Un altro processo vivo, anche sullo stesso host, va atteso.
+func reclaimableLock(lockedBy string) bool {
+	if lockedBy == instance {
+		return true
+	}
+	i := strings.LastIndex(lockedBy, "-")
+	if hostname == "" || i < 0 || lockedBy[:i] != hostname {
+		return false
+	}
+	pid, err := strconv.Atoi(lockedBy[i+1:])
+	return err == nil && pid > 0 && !processAlive(pid)
+}
+
+// processAlive indica se sull'host esiste ancora il processo pid
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Il segnale 0 non viene consegnato: verifica solo che il processo esista
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// scheduleEnv - Variabile che sovrascrive l'espressione del job, es. JOB_NO_SHOW_SCHEDULE
+func scheduleEnv(name string) string {
+	return "JOB_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_SCHEDULE"
+}
+
+// Register aggiunge un job. L'espressione è letta da JOB_<NOME>_SCHEDULE se
+// impostata, altrimenti è DefaultSchedule; "off" disattiva il job.
+func Register(job Job) error {
+	expr := job.DefaultSchedule
+	if v := os.Getenv(scheduleEnv(job.Name)); v != "" {
+		expr = v
+	}
+
+	e := &entry{job: job, expr: expr}
+	if expr != "off" {
+		schedule, err := Parse(expr)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		e.schedule = schedule
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, existing := range entries {
+		if existing.job.Name == job.Name {
+			return fmt.Errorf("job %s già registrato", job.Name)
+		}
+	}
+	entries = append(entries, e)
+	return nil
+}
+
+// Start avvia il ciclo dello scheduler. Un job mai eseguito, o la cui
+// esecuzione è stata saltata mentre il server era fermo, parte subito.
+func Start() {
+	mu.Lock()
+	if started {
+		mu.Unlock()
+		return
+	}
+	started = true
+	now := time.Now()
+	for _, e := range entries {
+		if e.schedule == nil {
+			log.Printf("Job %s disattivato", e.job.Name)
+			continue
+		}
+		e.next = now
+		if run, err := database.DB.FindJobRunByName(e.job.Name); err == nil && run.LastStartedAt != nil {
+			e.next = e.schedule.Next(*run.LastStartedAt)
+		}
+	}
+	mu.Unlock()
+
+	go func() {
+		for {
+			tick(time.Now())
+			time.Sleep(tickInterval)
+		}
+	}()
+}
+
+func tick(now time.Time) {
+	mu.Lock()
+	var due []*entry
+	for _, e := range entries {
+		if e.schedule != nil && !e.next.IsZero() && !now.Before(e.next) {
+			due = append(due, e)
+			e.next = e.schedule.Next(now)
+		}
+	}
+	mu.Unlock()
+
+	for _, e := range due {
+		go func(e *entry) {
+			if _, err := run(e, models.JobTriggerSchedule, nil); err != nil && !errors.Is(err, ErrJobRunning) {
+				log.Printf("Job %s: %v", e.job.Name, err)
+			}
+		}(e)
+	}
+}
+
+func find(name string) *entry {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, e := range entries {
+		if e.job.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Wake anticipa alla prossima verifica dello scheduler (entro tickInterval)
+// l'esecuzione di un job attivo, es. l'outbox appena c'è un messaggio da inviare
+func Wake(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !started {
+		return
+	}
+	now := time.Now()
+	for _, e := range entries {
+		if e.job.Name == name && e.schedule != nil && e.next.After(now) {
+			e.next = now
+		}
+	}
+}
+
+// RunNow esegue subito il job indicato, anche se disattivato, e restituisce
+// il record aggiornato. ErrJobRunning se è già in esecuzione.
+func RunNow(name string, triggeredBy uint) (*models.JobRun, error) {
+	e := find(name)
+	if e == nil {
+		return nil, ErrUnknownJob
+	}
+	return run(e, models.JobTriggerManual, &triggeredBy)
+}
+
+// List restituisce i job registrati con l'ultima esecuzione, in ordine di nome
+func List() ([]JobInfo, error) {
+	runs, err := database.DB.ListJobRuns()
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]models.JobRun{}
+	for _, r := range runs {
+		byName[r.Name] = r
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	infos := []JobInfo{}
+	for _, e := range entries {
+		info := JobInfo{
+			Name:        e.job.Name,
+			Description: e.job.Description,
+			Schedule:    e.expr,
+			Enabled:     e.schedule != nil,
+		}
+		if info.Enabled && !e.next.IsZero() {
+			next := e.next
+			info.NextRunAt = &next
+		}
+		if r, ok := byName[e.job.Name]; ok {
+			info.LastRun = &r
+			info.Running = r.LastStatus == models.JobStatusRunning
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// run esegue il job se nessun altro lo sta eseguendo: il mutex esclude
+// esecuzioni concorrenti nel processo, il lock su job_runs quelle di altre
+// istanze che condividono lo stesso database.
+func run(e *entry, trigger string, triggeredBy *uint) (*models.JobRun, error) {
+	if !e.running.TryLock() {
+		return nil, ErrJobRunning
+	}
+	defer e.running.Unlock()
+
+	started := time.Now()
+	if err := acquire(e.job.Name, started, trigger, triggeredBy); err != nil {
+		return nil, err
+	}
+
+	result, runErr := safeRun(e.job, started)
+	if runErr != nil {
+		log.Printf("Job %s fallito: %v", e.job.Name, runErr)
+	} else if result != "" {
+		log.Printf("Job %s: %s", e.job.Name, result)
+	}
+
+	return release(e.job.Name, started, result, runErr)
+}
+
+// safeRun esegue il job trasformando un panic in errore, così un job
+// difettoso non ferma il server né lascia il lock acquisito
+func safeRun(job Job, now time.Time) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(now)
+}
+
+func acquire(name string, now time.Time, trigger string, triggeredBy *uint) error {
+	return database.DB.WithTx(func(tx database.Store) error {
+		run, err := tx.FindJobRunByName(name)
+		if errors.Is(err, database.ErrNotFound) {
+			run = &models.JobRun{Name: name, CreatedAt: now}
+		} else if err != nil {
+			return err
+		}
+
+		if run.LockedUntil != nil && now.Before(*run.LockedUntil) && !reclaimableLock(run.LockedBy) {
+			return ErrJobRunning
+		}
+		if run.LockedBy != "" && run.LockedBy != instance {
+			log.Printf("Job %s: lock di %s ripreso da %s", name, run.LockedBy, instance)
+		}
+
+		lockedUntil := now.Add(lockLease)
+		run.LockedBy = instance
+		run.LockedUntil = &lockedUntil
+		run.LastStartedAt = &now
+		run.LastFinishedAt = nil
+		run.LastStatus = models.JobStatusRunning
+		run.LastTrigger = trigger
+		run.LastTriggeredBy = triggeredBy
+		run.UpdatedAt = now
+		if run.ID == 0 {
+			return tx.CreateJobRun(run)
+		}
+		return tx.UpdateJobRun(run)
+	})
+}
+
+func release(name string, started time.Time, result string, runErr error) (*models.JobRun, error) {
+	var run *models.JobRun
+	err := database.DB.WithTx(func(tx database.Store) error {
+		var err error
+		run, err = tx.FindJobRunByName(name)
+		if err != nil {
+			return err
+		}
+
+		finished := time.Now()
+		run.LastFinishedAt = &finished
+		run.LastDurationMs = finished.Sub(started).Milliseconds()
+		run.LastResult = result
+		run.LastError = ""
+		run.LastStatus = models.JobStatusSuccess
+		if runErr != nil {
+			run.LastError = runErr.Error()
+			run.LastStatus = models.JobStatusFailed
+			run.FailureCount++
+		}
+		run.RunCount++
+		run.LockedBy = ""
+		run.LockedUntil = nil
+		run.UpdatedAt = finished
+		return tx.UpdateJobRun(run)
+	})
+	return run, err
+}
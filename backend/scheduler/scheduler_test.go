@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"bloodone/database"
+	"bloodone/models"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func useTestStore(t *testing.T) {
+	t.Helper()
+	previous := database.DB
+	db := database.ConnectJSON(filepath.Join(t.TempDir(), "data.json"))
+	db.Migrate()
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+}
+
+// lockJob simula un lock lasciato su name da lockedBy, valido fino a until
+func lockJob(t *testing.T, name, lockedBy string, until time.Time) {
+	t.Helper()
+	now := time.Now()
+	err := database.DB.CreateJobRun(&models.JobRun{
+		Name:        name,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		LastStatus:  models.JobStatusRunning,
+		LockedBy:    lockedBy,
+		LockedUntil: &until,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// exitedPID avvia e attende un processo, restituendo il PID ormai libero
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestAcquireLease(t *testing.T) {
+	now := time.Now()
+	exited := fmt.Sprintf("%s-%d", hostname, exitedPID(t))
+	alive := fmt.Sprintf("%s-%d", hostname, os.Getppid())
+	tests := []struct {
+		name     string
+		lockedBy string
+		until    time.Time
+		wantErr  error
+	}{
+		{"altra istanza, lock valido", "altro-host-1234", now.Add(time.Minute), ErrJobRunning},
+		{"altra istanza, lock scaduto", "altro-host-1234", now.Add(-time.Minute), nil},
+		{"processo terminato sullo stesso host", exited, now.Add(time.Minute), nil},
+		{"altro processo vivo sullo stesso host", alive, now.Add(time.Minute), ErrJobRunning},
+		{"stesso processo", instance, now.Add(time.Minute), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestStore(t)
+			lockJob(t, "test", tt.lockedBy, tt.until)
+
+			err := acquire("test", now, models.JobTriggerSchedule, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("acquire = %v, want %v", err, tt.wantErr)
+			}
+			run, _ := database.DB.FindJobRunByName("test")
+			if tt.wantErr == nil && run.LockedBy != instance {
+				t.Errorf("LockedBy = %q, want %q", run.LockedBy, instance)
+			}
+			if tt.wantErr != nil && run.LockedBy != tt.lockedBy {
+				t.Errorf("il lock di un'altra istanza non deve cambiare: %q", run.LockedBy)
+			}
+		})
+	}
+}
+
+func TestReclaimableLock(t *testing.T) {
+	pid := exitedPID(t)
+	exited := fmt.Sprintf("%s-%d", hostname, pid)
+	tests := []struct {
+		lockedBy string
+		want     bool
+	}{
+		{instance, true},
+		{exited, true},
+		{fmt.Sprintf("%s-%d", hostname, os.Getppid()), false}, // vivo
+		{fmt.Sprintf("altro%s-%d", hostname, pid), false},
+		{fmt.Sprintf("%sx-%d", hostname, pid), false},
+		{hostname + "-pid", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := reclaimableLock(tt.lockedBy); got != tt.want {
+			t.Errorf("reclaimableLock(%q) = %v, want %v", tt.lockedBy, got, tt.want)
+		}
+	}
+
+	// Senza nome host nessun lock altrui è riconoscibile come proprio
+	previous := hostname
+	hostname = ""
+	defer func() { hostname = previous }()
+	if reclaimableLock(fmt.Sprintf("-%d", pid)) {
+		t.Errorf("reclaimableLock senza hostname = true, want false")
+	}
+}
@@ -0,0 +1,50 @@
+package sms
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizeE164 converte un numero di telefono inserito a mano in formato
+// E.164 ("+393331234567"): spazi, trattini, punti, barre e parentesi vengono
+// ignorati, il prefisso 00 diventa +, e ai numeri senza prefisso
+// internazionale viene aggiunto countryCode
+func NormalizeE164(raw, countryCode string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", fmt.Errorf("numero di telefono mancante")
+	}
+
+	var digits strings.Builder
+	plus := false
+	for i, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			plus = true
+		case strings.ContainsRune(" -./()", r):
+		default:
+			return "", fmt.Errorf("numero di telefono %q non valido", raw)
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case plus:
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	default:
+		number = countryCode + number
+	}
+	// E.164: prefisso che non inizia con 0, al massimo 15 cifre in tutto
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", fmt.Errorf("numero di telefono %q non valido", raw)
+	}
+	return "+" + number, nil
+}
+
+// Normalize - NormalizeE164 con il prefisso di SMS_DEFAULT_COUNTRY_CODE
+func Normalize(raw string) (string, error) {
+	return NormalizeE164(raw, config.CountryCode)
+}
@@ -0,0 +1,57 @@
+package sms
+
+import "testing"
+
+func TestNormalizeE164(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"3331234567", "+393331234567"},
+		{"333 123 4567", "+393331234567"},
+		{"+39 (333) 123-4567", "+393331234567"},
+		{"0039 333.123.4567", "+393331234567"},
+		{"+1 415 555 0100", "+14155550100"},
+		{"00 44 20/7946 0018", "+442079460018"},
+		{"06 1234 5678", "+390612345678"}, // fisso italiano: lo 0 iniziale resta
+		{"  +393331234567  ", "+393331234567"},
+		{"123456", "+39123456"},                  // 8 cifre con il prefisso: minimo ammesso
+		{"+123456789012345", "+123456789012345"}, // 15 cifre: massimo ammesso
+	}
+	for _, tt := range tests {
+		got, err := NormalizeE164(tt.raw, "39")
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeE164(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeE164Invalid(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"   ",
+		"abc",
+		"333 123 456x",
+		"+39+333",           // + solo all'inizio
+		"39 +333",           // + non iniziale
+		"+0391234567",       // il prefisso internazionale non inizia con 0
+		"000391234567",      // 00 seguito da 0
+		"+1234567",          // 7 cifre
+		"12345",             // 7 cifre con il prefisso
+		"+1234567890123456", // 16 cifre
+	} {
+		if got, err := NormalizeE164(raw, "39"); err == nil {
+			t.Errorf("NormalizeE164(%q) = %q, want error", raw, got)
+		}
+	}
+}
+
+func TestNormalizeUsesDefaultCountryCode(t *testing.T) {
+	previous := config
+	defer func() { config = previous }()
+
+	config.CountryCode = "41"
+	if got, err := Normalize("79 123 45 67"); err != nil || got != "+41791234567" {
+		t.Errorf("Normalize = %q, %v; want +41791234567", got, err)
+	}
+}
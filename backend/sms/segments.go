@@ -0,0 +1,76 @@
+package sms
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Codifiche di un SMS
+const (
+	EncodingGSM7 = "gsm7" // alfabeto GSM 03.38: 160 caratteri per SMS singolo
+	EncodingUCS2 = "ucs2" // qualsiasi altro carattere: 70 per SMS singolo
+)
+
+// Alfabeto GSM 03.38: i caratteri dell'estensione occupano due posizioni
+const (
+	gsmBasic     = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsmExtension = "\f^{}\\[~]|€"
+)
+
+// Lunghezze in caratteri (o unità UTF-16) di un SMS singolo e di ogni
+// segmento di un SMS concatenato, che riserva spazio all'intestazione
+var segmentLimits = map[string][2]int{
+	EncodingGSM7: {160, 153},
+	EncodingUCS2: {70, 67},
+}
+
+// Encoding - Codifica necessaria per il testo e la sua lunghezza in unità
+// della codifica
+func Encoding(text string) (string, int) {
+	septets := 0
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(gsmBasic, r):
+			septets++
+		case strings.ContainsRune(gsmExtension, r):
+			septets += 2
+		default:
+			return EncodingUCS2, len(utf16.Encode([]rune(text)))
+		}
+	}
+	return EncodingGSM7, septets
+}
+
+// Segments - Numero di SMS addebitati per il testo
+func Segments(text string) int {
+	encoding, length := Encoding(text)
+	limits := segmentLimits[encoding]
+	if length <= limits[0] {
+		return 1
+	}
+	return (length + limits[1] - 1) / limits[1]
+}
+
+// Fit accorcia il testo a parole intere, terminandolo con "...", perché stia
+// in maxSegments segmenti; il testo accorciato ha le parole separate da spazi
+func Fit(text string, maxSegments int) string {
+	if Segments(text) <= maxSegments {
+		return text
+	}
+	words := strings.Fields(text)
+	for n := len(words) - 1; n > 0; n-- {
+		candidate := strings.Join(words[:n], " ") + "..."
+		if Segments(candidate) <= maxSegments {
+			return candidate
+		}
+	}
+	// Prima parola troppo lunga: viene troncata
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if candidate := string(runes) + "..."; Segments(candidate) <= maxSegments {
+			return candidate
+		}
+	}
+	return ""
+}
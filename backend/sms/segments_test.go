@@ -0,0 +1,87 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		encoding string
+		length   int
+	}{
+		{"vuoto", "", EncodingGSM7, 0},
+		{"ascii", "Ciao Marco", EncodingGSM7, 10},
+		{"accenti GSM", "è là più ciò", EncodingGSM7, 12},
+		{"estensione conta 2", "costo 5€", EncodingGSM7, 9},
+		{"tutta l'estensione", "^{}\\[~]|€\f", EncodingGSM7, 20},
+		{"maiuscola accentata", "È confermata", EncodingUCS2, 12},
+		{"emoji in UTF-16", "ok 👍", EncodingUCS2, 5},
+	}
+	for _, tt := range tests {
+		encoding, length := Encoding(tt.text)
+		if encoding != tt.encoding || length != tt.length {
+			t.Errorf("%s: Encoding(%q) = %s, %d; want %s, %d", tt.name, tt.text, encoding, length, tt.encoding, tt.length)
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"vuoto", "", 1},
+		{"GSM singolo pieno", strings.Repeat("a", 160), 1},
+		{"GSM oltre il singolo", strings.Repeat("a", 161), 2},
+		{"GSM due segmenti pieni", strings.Repeat("a", 306), 2},
+		{"GSM terzo segmento", strings.Repeat("a", 307), 3},
+		{"estensione al limite", strings.Repeat("€", 80), 1},
+		{"estensione oltre il limite", strings.Repeat("€", 81), 2},
+		{"UCS-2 singolo pieno", strings.Repeat("È", 70), 1},
+		{"UCS-2 oltre il singolo", strings.Repeat("È", 71), 2},
+		{"UCS-2 due segmenti pieni", strings.Repeat("È", 134), 2},
+		{"UCS-2 terzo segmento", strings.Repeat("È", 135), 3},
+		{"un carattere UCS-2 cambia il limite", strings.Repeat("a", 100) + "È", 2},
+	}
+	for _, tt := range tests {
+		if got := Segments(tt.text); got != tt.want {
+			t.Errorf("%s: Segments = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	short := "Ciao Marco, a domani"
+	if got := Fit(short, 1); got != short {
+		t.Errorf("Fit non deve cambiare un testo che sta nei segmenti: %q", got)
+	}
+
+	long := strings.Repeat("parola ", 100)
+	got := Fit(long, 2)
+	if Segments(got) > 2 {
+		t.Errorf("Fit(_, 2) = %d segmenti", Segments(got))
+	}
+	if !strings.HasSuffix(got, "parola...") {
+		t.Errorf("Fit deve tagliare a parole intere: %q", got[len(got)-20:])
+	}
+	if len(got) < 300 {
+		t.Errorf("Fit accorcia troppo: %d caratteri", len(got))
+	}
+
+	// Prima parola più lunga di tutti i segmenti: viene troncata
+	word := strings.Repeat("x", 200)
+	got = Fit(word+" fine", 1)
+	if got != strings.Repeat("x", 157)+"..." {
+		t.Errorf("Fit con prima parola troppo lunga = %q (%d caratteri)", got, len(got))
+	}
+
+	// In UCS-2 il limite è 70
+	got = Fit(strings.Repeat("È ", 50), 1)
+	if encoding, length := Encoding(got); encoding != EncodingUCS2 || length > 70 {
+		t.Errorf("Fit UCS-2 = %s, %d", encoding, length)
+	}
+}
@@ -0,0 +1,205 @@
+// Package sms invia gli SMS ai donatori tramite un Sender intercambiabile:
+// un gateway HTTP (SMS_PROVIDER=http) o un Fake locale che tiene i messaggi
+// in memoria (SMS_PROVIDER=fake, per prove e sviluppo). Contiene anche la
+// normalizzazione dei numeri in E.164, il calcolo dei segmenti e i modelli
+// dei messaggi. Senza SMS_PROVIDER il canale SMS è disattivato.
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Valori di default della configurazione
+const (
+	defaultSenderID    = "BloodOne"
+	defaultCountryCode = "39"
+	defaultMaxSegments = 3
+)
+
+// Tempo massimo per una richiesta al gateway
+const httpTimeout = 15 * time.Second
+
+// ErrDisabled - Restituito da Send se nessun provider è configurato
+var ErrDisabled = errors.New("canale SMS non configurato (SMS_PROVIDER)")
+
+// Message - SMS pronto da inviare; To è in formato E.164
+type Message struct {
+	To   string
+	Text string
+}
+
+// Sender - Consegna un messaggio; l'errore indica che non è stato accettato
+type Sender interface {
+	Send(msg Message) error
+}
+
+// HTTPSender - Invio tramite gateway HTTP: POST su URL di un JSON
+// {"from", "to", "text"} con il token come Bearer; qualsiasi risposta 2xx
+// vale come messaggio accettato
+type HTTPSender struct {
+	URL   string
+	Token string
+	From  string
+
+	Client *http.Client
+}
+
+func (s HTTPSender) Send(msg Message) error {
+	body, err := json.Marshal(map[string]string{"from": s.From, "to": msg.To, "text": msg.Text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("gateway SMS: %s %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// Fake - Sender locale: scrive i messaggi nel log e li tiene in memoria.
+// Con Err impostato ogni invio fallisce con quell'errore.
+type Fake struct {
+	mu   sync.Mutex
+	sent []Message
+	Err  error
+}
+
+func (f *Fake) Send(msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	log.Printf("SMS (fake) a %s: %s", msg.To, msg.Text)
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// Sent - Messaggi accettati finora, dal primo
+func (f *Fake) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
+
+// Config - Impostazioni del canale SMS
+type Config struct {
+	CountryCode    string  // prefisso per i numeri senza prefisso internazionale
+	MaxSegments    int     // segmenti massimi per messaggio: oltre il testo viene accorciato
+	CostPerSegment float64 // costo di un segmento, per il conteggio mensile
+}
+
+var (
+	sender Sender
+	config = Config{CountryCode: defaultCountryCode, MaxSegments: defaultMaxSegments}
+)
+
+// Configure imposta il Sender e la configurazione dalle variabili
+// SMS_PROVIDER (http, fake; vuoto disattiva gli SMS), SMS_GATEWAY_URL,
+// SMS_GATEWAY_TOKEN, SMS_SENDER_ID, SMS_DEFAULT_COUNTRY_CODE,
+// SMS_MAX_SEGMENTS e SMS_COST_PER_SEGMENT
+func Configure() error {
+	cfg := Config{CountryCode: defaultCountryCode, MaxSegments: defaultMaxSegments}
+	if v := os.Getenv("SMS_DEFAULT_COUNTRY_CODE"); v != "" {
+		if _, err := strconv.ParseUint(v, 10, 16); err != nil || v[0] == '0' || len(v) > 3 {
+			return fmt.Errorf("SMS_DEFAULT_COUNTRY_CODE: %q non valido (es. 39)", v)
+		}
+		cfg.CountryCode = v
+	}
+	if v := os.Getenv("SMS_MAX_SEGMENTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("SMS_MAX_SEGMENTS: %q non valido", v)
+		}
+		cfg.MaxSegments = n
+	}
+	if v := os.Getenv("SMS_COST_PER_SEGMENT"); v != "" {
+		cost, err := strconv.ParseFloat(v, 64)
+		if err != nil || cost < 0 {
+			return fmt.Errorf("SMS_COST_PER_SEGMENT: %q non valido", v)
+		}
+		cfg.CostPerSegment = cost
+	}
+
+	var s Sender
+	switch provider := os.Getenv("SMS_PROVIDER"); provider {
+	case "":
+		log.Println("SMS_PROVIDER non impostato: canale SMS disattivato")
+	case "fake":
+		log.Println("SMS_PROVIDER=fake: gli SMS verranno solo scritti nel log")
+		s = &Fake{}
+	case "http":
+		url := os.Getenv("SMS_GATEWAY_URL")
+		if url == "" {
+			return errors.New("SMS_PROVIDER=http richiede SMS_GATEWAY_URL")
+		}
+		from := os.Getenv("SMS_SENDER_ID")
+		if from == "" {
+			from = defaultSenderID
+		}
+		s = HTTPSender{URL: url, Token: os.Getenv("SMS_GATEWAY_TOKEN"), From: from}
+	default:
+		return fmt.Errorf("SMS_PROVIDER: %q non valido (http, fake)", provider)
+	}
+
+	sender = s
+	config = cfg
+	return nil
+}
+
+// SetSender sostituisce il Sender configurato (nil disattiva gli SMS), ad
+// esempio con un Fake nelle prove
+func SetSender(s Sender) {
+	sender = s
+}
+
+// Enabled - Indica se è configurato un provider
+func Enabled() bool {
+	return sender != nil
+}
+
+// Settings - Configurazione corrente del canale
+func Settings() Config {
+	return config
+}
+
+// Send consegna il messaggio con il Sender configurato
+func Send(msg Message) error {
+	if sender == nil {
+		return ErrDisabled
+	}
+	return sender.Send(msg)
+}
+
+// Cost - Costo di un messaggio di segments segmenti
+func Cost(segments int) float64 {
+	return float64(segments) * config.CostPerSegment
+}
@@ -0,0 +1,91 @@
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPSender(t *testing.T) {
+	var got map[string]string
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sender := HTTPSender{URL: server.URL, Token: "secret", From: "BloodOne"}
+	if err := sender.Send(Message{To: "+393331234567", Text: "Ciao"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	want := map[string]string{"from": "BloodOne", "to": "+393331234567", "text": "Ciao"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestHTTPSenderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota esaurita", http.StatusPaymentRequired)
+	}))
+	defer server.Close()
+
+	err := HTTPSender{URL: server.URL}.Send(Message{To: "+393331234567", Text: "Ciao"})
+	if err == nil || !strings.Contains(err.Error(), "402") || !strings.Contains(err.Error(), "quota esaurita") {
+		t.Errorf("Send = %v, want errore con stato e dettaglio del gateway", err)
+	}
+}
+
+func TestSendWithFake(t *testing.T) {
+	defer SetSender(sender)
+
+	SetSender(nil)
+	if err := Send(Message{To: "+393331234567", Text: "Ciao"}); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Send senza provider = %v, want ErrDisabled", err)
+	}
+
+	fake := &Fake{}
+	SetSender(fake)
+	if !Enabled() {
+		t.Fatal("Enabled = false con un Fake")
+	}
+	if err := Send(Message{To: "+393331234567", Text: "Ciao"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	fake.Err = errors.New("gateway giù")
+	if err := Send(Message{To: "+393331234567", Text: "Di nuovo"}); err == nil {
+		t.Error("Send con Fake.Err deve fallire")
+	}
+	if sent := fake.Sent(); len(sent) != 1 || sent[0].Text != "Ciao" {
+		t.Errorf("Sent = %v", sent)
+	}
+}
+
+func TestRenderFitsSegments(t *testing.T) {
+	previous := config
+	defer func() { config = previous }()
+
+	config.MaxSegments = 1
+	text, err := Render("appointment_cancelled", map[string]any{
+		"Reason": strings.Repeat("motivo molto lungo ", 20),
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if Segments(text) != 1 || !strings.HasSuffix(text, "...") || strings.Contains(text, "\n") {
+		t.Errorf("Render = %q", text)
+	}
+	if Supports("registration_approved") || !Supports("recall") {
+		t.Error("Supports non riflette i modelli presenti")
+	}
+}
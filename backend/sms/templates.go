@@ -0,0 +1,49 @@
+package sms
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Ogni tipo di messaggio ha un modello templates/<tipo>.txt. I tipi senza
+// modello (es. esito della registrazione) restano solo email.
+//
+//go:embed templates/*
+var templates embed.FS
+
+var weekdays = []string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"}
+
+// shortDate - "lun 2/11": i messaggi restano brevi e nell'alfabeto GSM
+func shortDate(t time.Time) string {
+	return fmt.Sprintf("%s %d/%d", weekdays[t.Weekday()], t.Day(), int(t.Month()))
+}
+
+var funcs = template.FuncMap{
+	"date": shortDate,
+}
+
+// Supports - Indica se esiste un modello SMS per il tipo kind
+func Supports(kind string) bool {
+	_, err := fs.Stat(templates, "templates/"+kind+".txt")
+	return err == nil
+}
+
+// Render compone il testo del messaggio di tipo kind su una sola riga,
+// accorciato a SMS_MAX_SEGMENTS segmenti; data è passato al modello
+func Render(kind string, data any) (string, error) {
+	tmpl, err := template.New(kind+".txt").Funcs(funcs).ParseFS(templates, "templates/"+kind+".txt")
+	if err != nil {
+		return "", fmt.Errorf("modello SMS %s: %w", kind, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("testo SMS %s: %w", kind, err)
+	}
+	text := strings.Join(strings.Fields(buf.String()), " ")
+	return Fit(text, config.MaxSegments), nil
+}
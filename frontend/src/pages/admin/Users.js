@@ -1,6 +1,6 @@
 import React, { useState, useEffect } from 'react';
 import { useSearchParams } from 'react-router-dom';
-import { adminUserAPI } from '../../api/api';
+import { adminUserAPI, adminSuspensionAPI } from '../../api/api';
 import { toast } from 'react-toastify';
 import './Users.css';
 
@@ -147,14 +147,36 @@ function AdminUsers() {
     }
   };
 
+  // La sospensione non è un campo dell'utente: si crea o si termina dalle sospensioni
   const handleToggleSuspension = async (user) => {
     try {
-      await adminUserAPI.updateUser(user.id, { is_suspended: !user.is_suspended });
-      toast.success(user.is_suspended ? 'Utente riattivato' : 'Utente sospeso');
+      if (user.is_suspended) {
+        if (!window.confirm(`Terminare le sospensioni in corso di ${user.first_name} ${user.last_name}?`)) return;
+        const response = await adminSuspensionAPI.getSuspensions(user.id);
+        const now = new Date();
+        const current = (response.data || []).filter(s =>
+          s.is_active && new Date(s.start_date) <= now &&
+          (s.permanent || new Date(s.end_date) > now)
+        );
+        for (const suspension of current) {
+          await adminSuspensionAPI.endSuspension(suspension.id);
+        }
+        toast.success('Utente riattivato');
+      } else {
+        const reason = window.prompt('Motivo della sospensione');
+        if (!reason) return;
+        const months = parseInt(window.prompt('Durata in mesi', '3'), 10);
+        if (!months || months < 1) {
+          toast.error('Durata non valida');
+          return;
+        }
+        await adminSuspensionAPI.createSuspension({ donor_id: user.id, reason, duration_months: months });
+        toast.success('Utente sospeso');
+      }
       loadUsers();
     } catch (error) {
       console.error('Error toggling suspension:', error);
-      toast.error('Errore nell\'operazione');
+      toast.error(error.response?.data?.error || 'Errore nell\'operazione');
     }
   };
 